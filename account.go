@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+const (
+	// deleteAccountCommand deletes the account of the connecting player.
+	deleteAccountCommand = "delete-my-account"
+	// deleteConfirmation is what players type to confirm the deletion.
+	deleteConfirmation = "delete"
+	// deleteTimeout is how long players have to confirm.
+	deleteTimeout = time.Minute
+	// deletedPlayer takes the place of the name of deleted players in
+	// their finished games.
+	deletedPlayer = "deleted player"
+)
+
+var (
+	errDeleteNeedsKey  = errors.New("only players who log in with a key have an account, there is nothing to delete")
+	errDeleteCancelled = errors.New("your account was not deleted")
+)
+
+// accountMiddleware handles `ssh host delete-my-account`, which deletes the
+// settings and the results of the connecting player after they confirmed it.
+func accountMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			cmd := s.Command()
+			if len(cmd) == 0 || cmd[0] != deleteAccountCommand {
+				sh(s)
+				return
+			}
+			key := sessionKey(s)
+			if key == "" {
+				wish.Fatalln(s, errDeleteNeedsKey)
+				return
+			}
+			fmt.Fprintf(s, "This deletes the account of your key %s:\r\n", key)
+			fmt.Fprintf(s, "your settings, your results and your place on the leaderboard. Your finished\r\n")
+			fmt.Fprintf(s, "games are kept as played by a %s.\r\n", deletedPlayer)
+			fmt.Fprintf(s, "Type %s and press enter to confirm: ", deleteConfirmation)
+			_, _, echo := s.Pty()
+			if !confirmed(s, echo) {
+				wish.Fatalln(s, "\r\n"+errDeleteCancelled.Error())
+				return
+			}
+			if err := deleteAccount(key); err != nil {
+				log.Printf("Could not delete the account of %s: %v", key, err)
+				wish.Fatalln(s, fmt.Errorf("could not delete your account: %w", err))
+				return
+			}
+			log.Printf("Deleted the account of %s", key)
+			fmt.Fprintf(s, "Your account has been deleted.\r\n")
+			_ = s.Exit(0)
+		}
+	}
+}
+
+// confirmed reads a line from s and reports whether it is the confirmation.
+// The typed characters are sent back if the terminal doesn't show them.
+func confirmed(s ssh.Session, echo bool) bool {
+	line := make(chan string, 1)
+	go func() {
+		line <- readLine(s, echo)
+	}()
+	select {
+	case l := <-line:
+		return strings.TrimSpace(l) == deleteConfirmation
+	case <-time.After(deleteTimeout):
+	case <-s.Context().Done():
+	}
+	return false
+}
+
+// readLine reads from rw up to the end of the line, echoing it if echo is
+// set. Lines longer than the confirmation are cut off.
+func readLine(rw io.ReadWriter, echo bool) string {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if n, err := rw.Read(b); err != nil || n == 0 {
+			return string(line)
+		}
+		switch b[0] {
+		case '\r', '\n':
+			if echo {
+				_, _ = io.WriteString(rw, "\r\n")
+			}
+			return string(line)
+		case 3, 4:
+			// ctrl+c and ctrl+d
+			return ""
+		case 8, 127:
+			// backspace
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				if echo {
+					_, _ = io.WriteString(rw, "\b \b")
+				}
+			}
+			continue
+		}
+		if len(line) < 2*len(deleteConfirmation) {
+			line = append(line, b[0])
+			if echo {
+				_, _ = rw.Write(b)
+			}
+		}
+	}
+}
+
+// deleteAccount removes the settings and the results of the player with
+// key and replaces their name in the games they played.
+func deleteAccount(key string) error {
+	if err := prefs.update(key, func(p *userPrefs) { *p = userPrefs{} }); err != nil {
+		return err
+	}
+	if players.remove(key) {
+		if err := savePlayers(playersFile); err != nil {
+			return err
+		}
+	}
+	for _, g := range games.all() {
+		g.anonymize(key)
+	}
+	return anonymizeArchive(key)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// broadcastMsg is a message from the operator to all players.
+type broadcastMsg string
+
+// isAdmin reports whether the key with fingerprint is one of the configured
+// admin keys. Guests have no fingerprint.
+func isAdmin(fingerprint string) bool {
+	if fingerprint == "" {
+		return false
+	}
+	for _, line := range currentConfig().AdminKeys {
+		ak, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			continue
+		}
+		if gossh.FingerprintSHA256(ak) == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// adminMiddleware handles `ssh host admin <command>` for admin keys instead
+// of starting the game.
+func adminMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			cmd := s.Command()
+			if len(cmd) == 0 || cmd[0] != "admin" {
+				sh(s)
+				return
+			}
+			if !isAdmin(sessionKey(s)) {
+				wish.Fatalln(s, "permission denied")
+				return
+			}
+			if len(cmd) == 1 || cmd[1] == "dashboard" {
+				// the dashboard is a TUI, run by the bubbletea middleware
+				if _, _, active := s.Pty(); !active {
+					wish.Fatalln(s, "the dashboard needs a terminal, try ssh -t")
+					return
+				}
+				sh(s)
+				return
+			}
+			if err := runAdminCommand(s, cmd[1:]); err != nil {
+				wish.Fatalln(s, err)
+				return
+			}
+			_ = s.Exit(0)
+		}
+	}
+}
+
+const adminUsage = `usage:
+  admin [dashboard]           show the live dashboard (needs ssh -t)
+  admin sessions              list connected sessions
+  admin kick <id> [message]   disconnect a session
+  admin broadcast <message>   show a message to all players
+  admin restart-in <duration> [message]
+                              show the time until a restart, e.g. 10m,
+                              in all sessions, off takes it back
+  admin drain [message]       refuse new sessions with message and stop
+                              once the last session has ended
+  admin feature               list feature flags
+  admin feature <name> on|off toggle a feature until the next reload`
+
+var errAdminUsage = errors.New(adminUsage)
+
+func runAdminCommand(w io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errAdminUsage
+	}
+	switch args[0] {
+	case "sessions":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tUSER\tADDRESS\tCONNECTED\tSENT\tRENDERS\tMEMORY\tFLAGGED")
+		for _, sess := range sessions.all() {
+			st := sess.statsSnapshot()
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%s\t%t\n",
+				shortID(sess.id),
+				sess.user,
+				sess.addr,
+				time.Since(sess.started).Round(time.Second),
+				formatBytes(st.bytesSent),
+				st.renders,
+				formatBytes(st.memory),
+				st.flagged,
+			)
+		}
+		return tw.Flush()
+	case "kick":
+		if len(args) < 2 {
+			return errAdminUsage
+		}
+		sess, err := sessions.find(args[1])
+		if err != nil {
+			return err
+		}
+		sess.kick(strings.Join(args[2:], " "))
+		fmt.Fprintf(w, "kicked %s\n", shortID(sess.id))
+		return nil
+	case "broadcast":
+		msg := strings.Join(args[1:], " ")
+		if msg == "" {
+			return errAdminUsage
+		}
+		sessions.broadcast(broadcastMsg(msg))
+		fmt.Fprintf(w, "sent to %d sessions\n", sessions.len())
+		return nil
+	case "restart-in":
+		if len(args) < 2 {
+			return errAdminUsage
+		}
+		if args[1] == "off" {
+			announceMaintenance(maintenance{})
+			fmt.Fprintln(w, "restart taken back")
+			return nil
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid duration %q, try e.g. 10m", args[1])
+		}
+		announceMaintenance(maintenance{at: time.Now().Add(d), text: strings.Join(args[2:], " ")})
+		fmt.Fprintf(w, "announced a restart in %s to %d sessions\n", d, sessions.len())
+		return nil
+	case "drain":
+		if !drain(strings.Join(args[1:], " ")) {
+			return errors.New("the server is already draining or shutting down")
+		}
+		fmt.Fprintf(w, "draining, %d sessions are left\n", sessions.len())
+		return nil
+	case "feature":
+		switch len(args) {
+		case 1:
+			for _, name := range sortedFeatures() {
+				state := "on"
+				if !featureEnabled(name) {
+					state = "off"
+				}
+				fmt.Fprintf(w, "%-12s %s\n", name, state)
+			}
+			return nil
+		case 3:
+			if args[2] != "on" && args[2] != "off" {
+				return errAdminUsage
+			}
+			if err := setFeature(args[1], args[2] == "on"); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s is %s\n", args[1], args[2])
+			return nil
+		default:
+			return errAdminUsage
+		}
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], adminUsage)
+	}
+}
+
+// shortID shortens a session id for display. Sessions can be referred to by
+// any unique prefix of their id.
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
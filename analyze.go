@@ -0,0 +1,217 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var errPosition = errors.New("a position has rows of the same length separated by /, with o or 1 for an object and . or 0 for none, e.g. ..o../.ooo./ooooo")
+
+// parsePosition reads a position written row by row, separated by /, with
+// o or 1 for an object and . or 0 for an empty cell. The second form is the
+// one external engines are sent.
+func parsePosition(s string) ([][]bool, error) {
+	rows := strings.Split(strings.TrimSpace(s), "/")
+	field := make([][]bool, len(rows))
+	for r, row := range rows {
+		if row == "" || len(row) != len(rows[0]) {
+			return nil, errPosition
+		}
+		field[r] = make([]bool, len(row))
+		for c, ch := range row {
+			switch ch {
+			case 'o', '1':
+				field[r][c] = true
+			case '.', '0':
+			default:
+				return nil, errPosition
+			}
+		}
+	}
+	return field, nil
+}
+
+// formatPosition writes field like parsePosition reads it.
+func formatPosition(field [][]bool) string {
+	rows := make([]string, len(field))
+	for r, row := range field {
+		var b strings.Builder
+		for _, avail := range row {
+			if avail {
+				b.WriteByte('o')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		rows[r] = b.String()
+	}
+	return strings.Join(rows, "/")
+}
+
+// analyze prints what the solver knows about a position of the classic
+// game, to learn the strategy or to check the solver.
+func analyze(args []string) {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: nimm analyze <position>, e.g. nimm analyze ..o../.ooo./ooooo")
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	field, err := parsePosition(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := printAnalysis(os.Stdout, field); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// printAnalysis writes the heaps and the nim-sum of field, whether the
+// player to move wins with perfect play and the moves that keep the win.
+func printAnalysis(w io.Writer, field [][]bool) error {
+	hs := heaps(field)
+	sizes := make([]string, len(hs))
+	for i, h := range hs {
+		sizes[i] = fmt.Sprint(h)
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Position:\t%s\n", formatPosition(field))
+	fmt.Fprintf(tw, "Heaps:\t%s\n", strings.Join(sizes, " "))
+	fmt.Fprintf(tw, "Nim-sum:\t%d\n", nimSum(field))
+	moves := winningMoves(field)
+	switch _, winning, ok := solve(field); {
+	case !ok:
+		fmt.Fprintln(tw, "Result:\tgame over, the player to move lost")
+	case winning:
+		fmt.Fprintln(tw, "Result:\tthe player to move wins")
+	default:
+		fmt.Fprintln(tw, "Result:\tthe player to move loses against perfect play")
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(moves) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "\nWinning moves:")
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, mv := range moves {
+		fmt.Fprintf(tw, "  %s\ttake %d from row %d\n", cellRange(mv.row, mv.from, mv.to), mv.count(field), mv.row+1)
+	}
+	return tw.Flush()
+}
+
+// solveHeaps prints the best move in a game of Nim on a list of heaps, to
+// use nimm as a quick solver. Unlike the game on the board, any number of
+// objects can be taken from a heap. The player who takes the last object
+// wins, or loses with --misere.
+func solveHeaps(args []string) {
+	fs := flag.NewFlagSet("solve", flag.ExitOnError)
+	misere := fs.Bool("misere", false, "the player who takes the last object loses")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: nimm solve <heaps> [--misere], e.g. nimm solve 1,3,5,7 --misere")
+		fs.PrintDefaults()
+	}
+	// the flag may come after the heaps
+	var lists []string
+	for {
+		_ = fs.Parse(args)
+		if fs.NArg() == 0 {
+			break
+		}
+		lists = append(lists, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	hs, err := parseHeaps(strings.Join(lists, ","))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fs.Usage()
+		os.Exit(2)
+	}
+	i, take, winning := nimMove(hs, *misere)
+	switch {
+	case i < 0:
+		fmt.Println("The game is over, there is nothing left to take.")
+	case winning:
+		fmt.Printf("Take %d from the heap of %d.\n", take, hs[i])
+	default:
+		fmt.Printf("There is no winning move. Take %d from the heap of %d and hope for a mistake.\n", take, hs[i])
+	}
+}
+
+var errHeaps = errors.New("heaps are numbers separated by commas, e.g. 1,3,5,7")
+
+// parseHeaps reads heap sizes separated by commas.
+func parseHeaps(s string) ([]int, error) {
+	var hs []int
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return nil, errHeaps
+		}
+		hs = append(hs, n)
+	}
+	if len(hs) == 0 {
+		return nil, errHeaps
+	}
+	return hs, nil
+}
+
+// nimMove returns the best move in Nim on the heaps hs: the index of the
+// heap, how many to take from it, and whether the player to move wins with
+// it. In lost positions it takes a single object from the largest heap to
+// make the game last. The index is -1 if all heaps are empty.
+func nimMove(hs []int, misere bool) (heap, take int, winning bool) {
+	largest, sum, big := -1, 0, 0
+	for i, h := range hs {
+		sum ^= h
+		if h > 1 {
+			big++
+		}
+		if h > 0 && (largest < 0 || h > hs[largest]) {
+			largest = i
+		}
+	}
+	if largest < 0 {
+		return -1, 0, false
+	}
+	if misere && big <= 1 {
+		// play to leave an odd number of heaps of one
+		ones := 0
+		for _, h := range hs {
+			if h == 1 {
+				ones++
+			}
+		}
+		if big == 0 {
+			return largest, 1, ones%2 == 0
+		}
+		if ones%2 == 0 {
+			return largest, hs[largest] - 1, true
+		}
+		return largest, hs[largest], true
+	}
+	if sum == 0 {
+		return largest, 1, false
+	}
+	for i, h := range hs {
+		if h^sum < h {
+			return i, h - (h ^ sum), true
+		}
+	}
+	return largest, 1, false
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// leaderboardSize is the default and maximum number of players on the
+	// leaderboard.
+	leaderboardSize = 100
+)
+
+// apiGame is a game as returned by the API.
+type apiGame struct {
+	ID       string    `json:"id"`
+	Field    [][]bool  `json:"field"`
+	Player   int       `json:"player"`
+	Moves    int       `json:"moves"`
+	Players  [2]string `json:"players"`
+	Finished bool      `json:"finished"`
+	Winner   int       `json:"winner,omitempty"`
+}
+
+// apiMove is a move together with the game after it.
+type apiMove struct {
+	Game apiGame    `json:"game"`
+	Move moveRecord `json:"move"`
+}
+
+func newAPIGame(state gameState) apiGame {
+	return apiGame{
+		ID:       state.id,
+		Field:    state.field,
+		Player:   state.player,
+		Moves:    state.moves,
+		Players:  state.players,
+		Finished: state.finished(),
+		Winner:   state.winner(),
+	}
+}
+
+// apiHandler serves the read-only JSON API:
+//
+//	GET /api/leaderboard[?n=10]
+//	GET /api/players/{name}
+//	GET /api/games/{id}
+//	GET /api/games/{id}/board.png
+//	GET /api/games/{id}/board.svg
+//	GET /api/games/{id}/replay.cast
+//	GET /api/games/{id}/events
+//	GET /api/challenges
+//
+// and, with a token, the endpoints in serveAPIWrite.
+func apiHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/leaderboard", func(w http.ResponseWriter, r *http.Request) {
+		n := leaderboardSize
+		if s := r.URL.Query().Get("n"); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 1 {
+				writeAPIError(w, http.StatusBadRequest, "n must be a positive number")
+				return
+			}
+			if v < n {
+				n = v
+			}
+		}
+		writeJSON(w, players.leaderboard(n))
+	})
+	mux.HandleFunc("/api/players/", func(w http.ResponseWriter, r *http.Request) {
+		p, err := players.named(strings.TrimPrefix(r.URL.Path, "/api/players/"))
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSON(w, p)
+	})
+	mux.HandleFunc("/api/challenges", func(w http.ResponseWriter, r *http.Request) {
+		open := []apiGame{}
+		for _, g := range games.all() {
+			if g.isOpen() {
+				open = append(open, newAPIGame(g.state()))
+			}
+		}
+		writeJSON(w, append(open, peerChallenges(r)...))
+	})
+	mux.HandleFunc("/api/games/", func(w http.ResponseWriter, r *http.Request) {
+		id, view := strings.TrimPrefix(r.URL.Path, "/api/games/"), ""
+		if i := strings.IndexByte(id, '/'); i >= 0 {
+			id, view = id[:i], id[i+1:]
+		}
+		g, err := games.get(id)
+		if err != nil {
+			writeAPIError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		switch view {
+		case "":
+			writeJSON(w, newAPIGame(g.state()))
+		case "board.png":
+			b, err := renderBoardPNG(g.state())
+			if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			_, _ = w.Write(b)
+		case "board.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			_, _ = w.Write(renderBoardSVG(g.state()))
+		case "replay.cast":
+			b, err := renderCast(g.state())
+			if err != nil {
+				writeAPIError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			w.Header().Set("Content-Type", "application/x-asciicast")
+			w.Header().Set("Content-Disposition", `attachment; filename="nimm-`+id+`.cast"`)
+			_, _ = w.Write(b)
+		case "events":
+			serveGameEvents(w, r, g)
+		default:
+			writeAPIError(w, http.StatusNotFound, "not found")
+		}
+	})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if forwardGame(w, r) {
+			// the instance hosting the game answers with its own headers
+			return
+		}
+		// the API is meant to be embedded in other sites
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			mux.ServeHTTP(w, r)
+		case http.MethodPost:
+			serveAPIWrite(w, r)
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			writeAPIError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Could not write API response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{msg})
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jheuel/nimm/variant"
+)
+
+// apiRequestLimit bounds the bodies of write requests.
+const apiRequestLimit = 4 << 10
+
+var errUnauthorized = errors.New("missing or unknown token")
+
+// apiToken lets a program play over the HTTP API as the player Name.
+type apiToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+}
+
+// apiNewGame is the body of POST /api/games.
+type apiNewGame struct {
+	// Opponent is the engine to play against. Without one, the game is a
+	// challenge that another player can join.
+	Opponent string `json:"opponent"`
+	// Seat is 2 to move second.
+	Seat    int    `json:"seat"`
+	Variant string `json:"variant"`
+}
+
+// apiPlayer returns the player whose token authorizes r.
+func apiPlayer(r *http.Request) (string, bool) {
+	return tokenPlayer(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+}
+
+// tokenPlayer returns the player that token belongs to.
+func tokenPlayer(token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	for _, t := range currentConfig().APITokens {
+		if t.Name != "" && t.Token != "" && subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+			return t.Name, true
+		}
+	}
+	return "", false
+}
+
+// serveAPIWrite serves the endpoints that need a token, sent as
+// "Authorization: Bearer <token>":
+//
+//	POST /api/games              {"opponent":"computer","seat":1,"variant":"classic"}
+//	POST /api/games/{id}/join
+//	POST /api/games/{id}/moves   {"row":3,"from":0,"to":2}
+//
+// They answer with the game, after the engine's reply if it is played
+// against one. Bots in open games poll GET /api/games/{id} for the moves of
+// the other player.
+func serveAPIWrite(w http.ResponseWriter, r *http.Request) {
+	name, ok := apiPlayer(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeAPIError(w, http.StatusUnauthorized, errUnauthorized.Error())
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, apiRequestLimit)
+
+	if r.URL.Path == "/api/games" {
+		createAPIGame(w, r, name)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, "/api/games/") {
+		writeAPIError(w, http.StatusNotFound, "not found")
+		return
+	}
+	id, action := strings.TrimPrefix(r.URL.Path, "/api/games/"), ""
+	if i := strings.IndexByte(id, '/'); i >= 0 {
+		id, action = id[:i], id[i+1:]
+	}
+	g, err := games.get(id)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	switch action {
+	case "join":
+		state, err := g.claimSeat(name, tokenOwner(name))
+		if err != nil {
+			writeGameError(w, err)
+			return
+		}
+		fireEvent(hookGameStarted, newAPIGame(state))
+		writeJSON(w, newAPIGame(state))
+	case "moves":
+		var mv botMove
+		if err := json.NewDecoder(r.Body).Decode(&mv); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		if mv.Row == nil || mv.From == nil || mv.To == nil {
+			writeAPIError(w, http.StatusBadRequest, `a move needs "row", "from" and "to"`)
+			return
+		}
+		state, err := g.takeFor(tokenOwner(name), *mv.Row, *mv.From, *mv.To)
+		if err == nil {
+			state, err = apiEngineMove(g, state)
+		}
+		if err != nil {
+			writeGameError(w, err)
+			return
+		}
+		writeJSON(w, newAPIGame(state))
+	default:
+		writeAPIError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func createAPIGame(w http.ResponseWriter, r *http.Request, name string) {
+	var ng apiNewGame
+	if err := json.NewDecoder(r.Body).Decode(&ng); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+		return
+	}
+	v, err := checkAPINewGame(&ng)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	g, state, err := startAPIGame(name, v, ng)
+	if err != nil {
+		writeGameError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/api/games/"+g.id)
+	w.WriteHeader(http.StatusCreated)
+	writeJSON(w, newAPIGame(state))
+}
+
+// checkAPINewGame fills in the defaults of ng and returns its variant, or
+// why the game can't be created.
+func checkAPINewGame(ng *apiNewGame) (variant.Variant, error) {
+	if ng.Seat == 0 {
+		ng.Seat = 1
+	}
+	if ng.Variant == "" {
+		ng.Variant = variant.Default
+	}
+	v, err := variant.Get(ng.Variant)
+	if err == nil && ng.Variant != variant.Default && !featureEnabled(featureVariants) {
+		err = errVariantsDisabled
+	}
+	if err == nil && ng.Opponent != "" && ng.Variant != variant.Default {
+		err = errEngineVariant
+	}
+	if err == nil && ng.Seat != 1 && ng.Seat != 2 {
+		err = errors.New("seat must be 1 or 2")
+	}
+	if err == nil && ng.Opponent != "" {
+		// make sure that the engine exists
+		var eng engine
+		if eng, err = newEngine(ng.Opponent); err == nil {
+			eng.close()
+		}
+	}
+	return v, err
+}
+
+// startAPIGame creates the game ng with name in its seat, and lets the
+// engine move if it starts.
+func startAPIGame(name string, v variant.Variant, ng apiNewGame) (*game, gameState, error) {
+	g := games.create(v)
+	g.seat(ng.Seat, name, tokenOwner(name))
+	g.challenge(ng.Opponent)
+	state := g.state()
+	if ng.Opponent != "" {
+		fireEvent(hookGameStarted, newAPIGame(state))
+		var err error
+		if state, err = apiEngineMove(g, state); err != nil {
+			return g, state, err
+		}
+	}
+	return g, state, nil
+}
+
+// apiEngineMove lets the engine of g move if it is its turn.
+func apiEngineMove(g *game, state gameState) (gameState, error) {
+	name := g.opponentEngine()
+	if name == "" || state.finished() || state.players[state.player-1] != "" {
+		return state, nil
+	}
+	eng, err := newEngine(name)
+	if err != nil {
+		return state, err
+	}
+	defer eng.close()
+	return playEngineMove(g, eng, nil)
+}
+
+func writeGameError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errInvalidMove), errors.Is(err, errLastObject):
+		code = http.StatusBadRequest
+	case errors.Is(err, errNotYourTurn), errors.Is(err, errNotOpen), errors.Is(err, errVariantsDisabled), errors.Is(err, errNoOpponent), errors.Is(err, errGameFinished):
+		code = http.StatusConflict
+	}
+	writeAPIError(w, code, err.Error())
+}
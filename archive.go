@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/jheuel/nimm/variant"
+)
+
+// archiveDir is where finished games with known players are kept, so that
+// they can be replayed later.
+const archiveDir = "archive"
+
+// archiveGame saves a finished game if any of its players is known.
+func archiveGame(state gameState) {
+	if state.players[0] == "" && state.players[1] == "" {
+		return
+	}
+	sg := savedGame{
+		ID:      state.id,
+		Variant: state.variant.Name(),
+		Field:   state.field,
+		Player:  state.player,
+		Moves:   state.moves,
+		Players: state.players,
+		Owners:  state.owners,
+		Start:   state.start,
+		History: state.history,
+	}
+	if len(sg.History) > 0 {
+		sg.SavedAt = sg.History[len(sg.History)-1].At
+	}
+	if err := saveGame(archiveDir, sg); err != nil {
+		log.Printf("Could not archive game %s: %v", state.id, err)
+	}
+}
+
+// archivedGame loads the finished game id from the archive.
+func archivedGame(id string) (savedGame, error) {
+	var sg savedGame
+	if id == "" || filepath.Base(id) != id {
+		return sg, errNoGame
+	}
+	b, err := os.ReadFile(filepath.Join(archiveDir, id+".json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return sg, errNoGame
+	}
+	if err != nil {
+		return sg, err
+	}
+	err = json.Unmarshal(b, &sg)
+	return sg, err
+}
+
+// archivedGames returns the finished games of the player with key, oldest
+// first.
+func archivedGames(key string) ([]savedGame, error) {
+	paths, err := filepath.Glob(filepath.Join(archiveDir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var all []savedGame
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var sg savedGame
+		if err := json.Unmarshal(b, &sg); err != nil {
+			log.Printf("Skipping archived game %s: %v", path, err)
+			continue
+		}
+		if sg.Owners[0] == key || sg.Owners[1] == key {
+			all = append(all, sg)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].SavedAt.Before(all[j].SavedAt) })
+	return all, nil
+}
+
+// anonymizeArchive replaces the name in the seats owned by key in all
+// archived games.
+func anonymizeArchive(key string) error {
+	all, err := archivedGames(key)
+	if err != nil {
+		return err
+	}
+	for _, sg := range all {
+		for i, owner := range sg.Owners {
+			if owner == key {
+				sg.Players[i] = deletedPlayer
+				sg.Owners[i] = ""
+			}
+		}
+		if err := saveGame(archiveDir, sg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// state returns the saved game as a game state.
+func (sg savedGame) state() (gameState, error) {
+	name := sg.Variant
+	if name == "" {
+		name = variant.Default
+	}
+	v, err := variant.Get(name)
+	if err != nil {
+		return gameState{}, err
+	}
+	if len(sg.Field) == 0 {
+		return gameState{}, fmt.Errorf("invalid saved game %q", sg.ID)
+	}
+	return gameState{
+		id:      sg.ID,
+		variant: v,
+		field:   sg.Field,
+		rows:    len(sg.Field),
+		cols:    len(sg.Field[0]),
+		player:  sg.Player,
+		moves:   sg.Moves,
+		players: sg.Players,
+		owners:  sg.Owners,
+		start:   sg.Start,
+		history: sg.History,
+	}, nil
+}
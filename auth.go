@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/charmbracelet/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// keyExtension is the permission extension that holds the fingerprint of
+// the key a connection authenticated with.
+const keyExtension = "nimm-key"
+
+// publicKeyAuth accepts any key so that we know who is connecting. Clients
+// may offer keys they can't sign with, but the last key checked is the one
+// the connection authenticates with, so it replaces the ones before.
+func publicKeyAuth(ctx ssh.Context, key ssh.PublicKey) bool {
+	setAuthenticatedKey(ctx, gossh.FingerprintSHA256(key))
+	return true
+}
+
+// keyboardInteractiveAuth lets guests without a key in, if guests are
+// allowed. Keys the client offered before don't count for them.
+func keyboardInteractiveAuth(ctx ssh.Context, _ gossh.KeyboardInteractiveChallenge) bool {
+	if !featureEnabled(featureGuests) {
+		return false
+	}
+	setAuthenticatedKey(ctx, "")
+	return true
+}
+
+func setAuthenticatedKey(ctx ssh.Context, fingerprint string) {
+	perms := ctx.Permissions()
+	if perms.Extensions == nil {
+		perms.Extensions = map[string]string{}
+	}
+	if fingerprint == "" {
+		delete(perms.Extensions, keyExtension)
+		return
+	}
+	perms.Extensions[keyExtension] = fingerprint
+}
+
+// sessionKey returns the fingerprint of the key s authenticated with, empty
+// for guests. Unlike s.PublicKey, it is never a key the client only offered.
+func sessionKey(s ssh.Session) string {
+	return s.Permissions().Extensions[keyExtension]
+}
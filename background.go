@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// queryBackground asks the terminal for its background color (OSC 11).
+	// Terminals that don't know it ignore it.
+	queryBackground = "\x1b]11;?\x07"
+	// backgroundReplyTimeout is how long we look for the reply in the input.
+	backgroundReplyTimeout = 2 * time.Second
+	// maxBackgroundReply bounds the length of a reply.
+	maxBackgroundReply = 64
+)
+
+var backgroundReply = []byte("\x1b]11;")
+
+// backgroundMsg tells a session whether the terminal has a light background.
+type backgroundMsg bool
+
+// parseBackground reads the color in a reply like rgb:ffff/ffff/ffff and
+// reports whether it is light.
+func parseBackground(color string) (light, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(color, "rgb:"), "/")
+	if len(parts) != 3 || !strings.HasPrefix(color, "rgb:") {
+		return false, false
+	}
+	var lum float64
+	for i, weight := range []float64{0.2126, 0.7152, 0.0722} {
+		p := parts[i]
+		if p == "" || len(p) > 4 {
+			return false, false
+		}
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil {
+			return false, false
+		}
+		lum += weight * float64(v) / float64(uint64(1)<<(4*len(p))-1)
+	}
+	return lum > 0.5, true
+}
+
+// colorFGBG reads the background from the COLORFGBG variable that some
+// terminals set, e.g. "15;0" for white on black.
+func colorFGBG(environ []string) (light, ok bool) {
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, "COLORFGBG=") {
+			continue
+		}
+		fields := strings.Split(strings.TrimPrefix(kv, "COLORFGBG="), ";")
+		bg, err := strconv.Atoi(fields[len(fields)-1])
+		if err != nil {
+			return false, false
+		}
+		return bg == 7 || bg >= 9 && bg <= 15, true
+	}
+	return false, false
+}
+
+// backgroundFilter takes the reply to queryBackground out of the input of a
+// session, so the program doesn't see it as keys, and reports what it says.
+// It stops looking after the first reply or backgroundReplyTimeout.
+type backgroundFilter struct {
+	r        io.Reader
+	found    func(light bool)
+	deadline time.Time
+	done     bool
+	// ready is filtered input, pending the start of a reply that isn't
+	// complete yet
+	ready   []byte
+	pending []byte
+	// err is returned once ready is read
+	err error
+}
+
+func newBackgroundFilter(r io.Reader, found func(light bool)) *backgroundFilter {
+	return &backgroundFilter{r: r, found: found, deadline: time.Now().Add(backgroundReplyTimeout)}
+}
+
+func (f *backgroundFilter) Read(p []byte) (int, error) {
+	for {
+		if len(f.ready) > 0 {
+			n := copy(p, f.ready)
+			f.ready = f.ready[n:]
+			return n, nil
+		}
+		if f.err != nil {
+			return 0, f.err
+		}
+		if f.done || time.Now().After(f.deadline) {
+			f.done = true
+			if len(f.pending) > 0 {
+				f.ready, f.pending = f.pending, nil
+				continue
+			}
+			return f.r.Read(p)
+		}
+		// read whole chunks, the caller's buffer might be tiny
+		buf := make([]byte, 256)
+		n, err := f.r.Read(buf)
+		f.ready, f.pending = f.filter(append(f.pending, buf[:n]...))
+		if err != nil {
+			f.ready = append(f.ready, f.pending...)
+			f.pending, f.err = nil, err
+		}
+	}
+}
+
+// filter removes a reply from b. The start of a reply at the end of b is
+// returned as rest, to be completed by the next read.
+func (f *backgroundFilter) filter(b []byte) (out, rest []byte) {
+	i := bytes.Index(b, backgroundReply)
+	if i < 0 {
+		// keep a cut off "\x1b]11", but not a lone escape key
+		for n := len(backgroundReply) - 1; n >= 2; n-- {
+			if bytes.HasSuffix(b, backgroundReply[:n]) {
+				return b[:len(b)-n], b[len(b)-n:]
+			}
+		}
+		return b, nil
+	}
+	body := b[i+len(backgroundReply):]
+	end, skip := bytes.IndexByte(body, '\a'), 1
+	if st := bytes.Index(body, []byte("\x1b\\")); st >= 0 && (end < 0 || st < end) {
+		end, skip = st, 2
+	}
+	if end < 0 {
+		if len(body) > maxBackgroundReply {
+			f.done = true
+			return b, nil
+		}
+		return b[:i], b[i:]
+	}
+	f.done = true
+	if light, ok := parseBackground(string(body[:end])); ok {
+		f.found(light)
+	}
+	return append(b[:i:i], body[end+skip:]...), nil
+}
@@ -0,0 +1,611 @@
+// Package board is a Bubble Tea component that shows a Nim board and lets
+// the player pick a move with the keyboard or the mouse. Programs keep a
+// Model in their own model, pass it their messages and render its View
+// wherever they like. Mouse messages must be moved to the top left corner of
+// the view first. Submitted moves are handed to OnMove; the board itself
+// never changes until the program calls SetBoard.
+package board
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/variant"
+)
+
+// KeyMap are the keys the board reacts to.
+type KeyMap struct {
+	Up     key.Binding
+	Down   key.Binding
+	Left   key.Binding
+	Right  key.Binding
+	Select key.Binding
+	Submit key.Binding
+	// Home and End jump to the first and last column, Top and Bottom to the
+	// first and last row and NextRow to the next row with objects.
+	Home    key.Binding
+	End     key.Binding
+	Top     key.Binding
+	Bottom  key.Binding
+	NextRow key.Binding
+}
+
+// DefaultKeyMap uses the arrow keys or hjkl, space and enter.
+var DefaultKeyMap = KeyMap{
+	Up: key.NewBinding(
+		key.WithKeys("up", "k"),
+		key.WithHelp("↑/k", "move up"),
+	),
+	Down: key.NewBinding(
+		key.WithKeys("down", "j"),
+		key.WithHelp("↓/j", "move down"),
+	),
+	Left: key.NewBinding(
+		key.WithKeys("left", "h"),
+		key.WithHelp("←/h", "move left"),
+	),
+	Right: key.NewBinding(
+		key.WithKeys("right", "l"),
+		key.WithHelp("→/l", "move right"),
+	),
+	Select: key.NewBinding(
+		key.WithKeys(" "),
+		key.WithHelp("SPACE", "select"),
+	),
+	Submit: key.NewBinding(
+		key.WithKeys("enter"),
+		key.WithHelp("ENTER", "submit"),
+	),
+	Home: key.NewBinding(
+		key.WithKeys("home"),
+		key.WithHelp("home", "first column"),
+	),
+	End: key.NewBinding(
+		key.WithKeys("end"),
+		key.WithHelp("end", "last column"),
+	),
+	Top: key.NewBinding(
+		key.WithKeys("g"),
+		key.WithHelp("g", "first row"),
+	),
+	Bottom: key.NewBinding(
+		key.WithKeys("G"),
+		key.WithHelp("G", "last row"),
+	),
+	NextRow: key.NewBinding(
+		key.WithKeys("tab"),
+		key.WithHelp("TAB", "next row"),
+	),
+}
+
+// Styles are applied to the cells of the board. The cursor and selection
+// styles are laid over the cell style.
+type Styles struct {
+	Cell     lipgloss.Style
+	Cursor   lipgloss.Style
+	Selected lipgloss.Style
+	// Taken is for objects that were just taken, see Flash.
+	Taken lipgloss.Style
+	// Label is for the coordinates around the board, see Labels.
+	Label lipgloss.Style
+	// Remote is for the cursor of the other player, see SetRemote.
+	Remote lipgloss.Style
+}
+
+// DefaultStyles highlights the cursor in purple, the selection in magenta
+// and the cursor of the other player in teal.
+var DefaultStyles = Styles{
+	Cell:     lipgloss.NewStyle(),
+	Cursor:   lipgloss.NewStyle().Bold(true).Background(lipgloss.Color("#7D56F4")),
+	Selected: lipgloss.NewStyle().Foreground(lipgloss.Color("5")),
+	Taken:    lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")),
+	Label:    lipgloss.NewStyle().Faint(true),
+	Remote:   lipgloss.NewStyle().Background(lipgloss.Color("#00A6B2")),
+}
+
+const (
+	// flashFrames is how often the objects of a move are redrawn before
+	// they are gone, flashInterval how long each frame lasts.
+	flashFrames   = 5
+	flashInterval = 150 * time.Millisecond
+)
+
+// stick is how an object is drawn if Sticks is set, one string per line.
+var stick = []string{" o ", " | ", " | "}
+
+const stickWidth = 3
+
+// flashMsg shows the next frame of the flash with id.
+type flashMsg struct{ id, frame int }
+
+// Model is the state of a board component.
+type Model struct {
+	KeyMap KeyMap
+	Styles Styles
+	// OnMove is called with the selection when it is submitted. Update
+	// returns the command it returns.
+	OnMove func(variant.Move) tea.Cmd
+	// Quick submits the object under the cursor when nothing is selected,
+	// so that taking a single object needs only one key.
+	Quick bool
+	// Markers shows the cursor with > and the selection with * in front of
+	// the objects, for terminals that can't show styles.
+	Markers bool
+	// Glyph is drawn for objects instead of the mark of the variant, if
+	// set. It may be a double-width character.
+	Glyph string
+	// Sticks draws every object as a matchstick over several lines instead
+	// of a single mark, for large screens.
+	Sticks bool
+	// Labels shows the columns as letters above the board and the rows as
+	// numbers to the left, so that players can name cells like C2.
+	Labels bool
+	// Counts shows how many objects are left at the end of each row, so
+	// that players don't have to count them.
+	Counts bool
+
+	board variant.Board
+	hints variant.Hints
+
+	row, col int
+	// sel is the selected range of a row, if selected is set
+	selected bool
+	sel      variant.Move
+	// dragging is set while the mouse button is held after selecting the
+	// object in column anchor
+	dragging bool
+	anchor   int
+	// flash is the move that was just taken and is shown for flashFrames,
+	// if flashing is set. flashID tells newer flashes from older ones.
+	// taken tells which cells of flash held objects.
+	flashing bool
+	flash    variant.Move
+	taken    []bool
+	frame    int
+	flashID  int
+	// remoteRow and remoteCol are the cursor of the other player, if remote
+	// is set
+	remote               bool
+	remoteRow, remoteCol int
+}
+
+// New returns a board component showing b with the marks in hints.
+func New(b variant.Board, hints variant.Hints) Model {
+	return Model{
+		KeyMap: DefaultKeyMap,
+		Styles: DefaultStyles,
+		board:  b,
+		hints:  hints,
+	}
+}
+
+// SetBoard shows b instead of the current board, e.g. after a move.
+func (m *Model) SetBoard(b variant.Board) {
+	m.board = b
+	if m.row >= len(b) {
+		m.row = len(b) - 1
+	}
+	if m.row >= 0 && m.col >= len(b[m.row]) {
+		m.col = len(b[m.row]) - 1
+	}
+}
+
+// Reset clears the selection and moves the cursor to the top left.
+func (m *Model) Reset() {
+	m.row, m.col = 0, 0
+	m.selected, m.dragging = false, false
+}
+
+// object is the mark of an object.
+func (m Model) object() string {
+	if m.Glyph != "" {
+		return m.Glyph
+	}
+	return m.hints.Object
+}
+
+// cellWidth is the width of the widest mark, the others are padded to it.
+func (m Model) cellWidth() int {
+	width := lipgloss.Width(m.object())
+	if w := lipgloss.Width(m.hints.Empty); w > width {
+		width = w
+	}
+	return width
+}
+
+// Flash shows the objects taken by mv flashing for a moment and fading
+// away, so that players see what was taken. The returned command drives the
+// frames, their messages have to be passed to Update. It has to be called
+// before SetBoard shows the board after mv, so that the empty cells mv
+// skipped don't flash.
+func (m *Model) Flash(mv variant.Move) tea.Cmd {
+	m.flashID++
+	m.flashing, m.flash, m.frame = true, mv, 0
+	m.taken = make([]bool, mv.To-mv.From+1)
+	if mv.Row >= 0 && mv.Row < len(m.board) {
+		for c := mv.From; c <= mv.To && c < len(m.board[mv.Row]); c++ {
+			m.taken[c-mv.From] = c >= 0 && m.board[mv.Row][c]
+		}
+	}
+	return m.nextFrame()
+}
+
+func (m Model) nextFrame() tea.Cmd {
+	id, frame := m.flashID, m.frame+1
+	return tea.Tick(flashInterval, func(time.Time) tea.Msg {
+		return flashMsg{id, frame}
+	})
+}
+
+// flashed returns whether an object that was just taken is shown in the
+// current frame and its style. It blinks twice and then fades.
+func (m Model) flashed(r, c int) (bool, lipgloss.Style, bool) {
+	if !m.flashing || r != m.flash.Row || c < m.flash.From || c > m.flash.To || !m.taken[c-m.flash.From] {
+		return false, lipgloss.Style{}, false
+	}
+	switch {
+	case m.frame == flashFrames-1:
+		return true, m.Styles.Taken.Copy().Faint(true), true
+	case m.frame%2 == 0:
+		return true, m.Styles.Taken.Copy(), true
+	}
+	return false, m.Styles.Cell.Copy(), true
+}
+
+// SetRemote shows the cursor of the other player in row and col, e.g. of
+// a player on another terminal while it is their turn.
+func (m *Model) SetRemote(row, col int) {
+	m.remote, m.remoteRow, m.remoteCol = true, row, col
+}
+
+// HideRemote hides the cursor of the other player.
+func (m *Model) HideRemote() {
+	m.remote = false
+}
+
+// SetCursor moves the cursor to row and col, or as close as the board
+// allows.
+func (m *Model) SetCursor(row, col int) {
+	if row >= len(m.board) {
+		row = len(m.board) - 1
+	}
+	if row < 0 {
+		row = 0
+	}
+	if len(m.board) > 0 && col >= len(m.board[row]) {
+		col = len(m.board[row]) - 1
+	}
+	if col < 0 {
+		col = 0
+	}
+	m.row, m.col = row, col
+}
+
+// Cursor returns the row and column under the cursor.
+func (m Model) Cursor() (row, col int) {
+	return m.row, m.col
+}
+
+// Selection returns the selected move, if any.
+func (m Model) Selection() (variant.Move, bool) {
+	return m.sel, m.selected
+}
+
+// CellAt returns the row and column of the object at x, y of the view.
+func (m Model) CellAt(x, y int) (row, col int, ok bool) {
+	if m.Labels {
+		x, y = x-m.labelWidth(), y-1
+	}
+	if x < 0 || y < 0 {
+		return 0, 0, false
+	}
+	width := m.cellWidth()
+	if m.Sticks {
+		// the rows are separated by an empty line
+		if y%(len(stick)+1) == len(stick) {
+			return 0, 0, false
+		}
+		y, width = y/(len(stick)+1), stickWidth
+	}
+	if x < 0 || y < 0 || y >= len(m.board) {
+		return 0, 0, false
+	}
+	col = x / (2 + width)
+	if col >= len(m.board[y]) {
+		return 0, 0, false
+	}
+	return y, col, true
+}
+
+// Submit returns the command of OnMove for the selection, like the submit
+// key does.
+func (m Model) Submit() tea.Cmd {
+	if m.OnMove == nil {
+		return nil
+	}
+	if m.selected {
+		return m.OnMove(m.sel)
+	}
+	if m.Quick && m.board[m.row][m.col] {
+		return m.OnMove(variant.Move{Row: m.row, From: m.col, To: m.col})
+	}
+	return nil
+}
+
+// mouse moves the cursor to the object that is clicked and selects it like
+// the select key does. Dragging selects the objects on the way.
+func (m Model) mouse(msg tea.MouseMsg) Model {
+	switch msg.Type {
+	case tea.MouseRelease:
+		m.dragging = false
+	case tea.MouseLeft:
+		row, col, ok := m.CellAt(msg.X, msg.Y)
+		switch {
+		case !ok:
+		case m.dragging && row == m.sel.Row:
+			m.col = col
+			m.sel.From, m.sel.To = m.anchor, col
+			if col < m.anchor {
+				m.sel.From, m.sel.To = col, m.anchor
+			}
+		case !m.dragging:
+			m.row, m.col = row, col
+			m.toggle()
+			m.dragging, m.anchor = m.selected, col
+		}
+	}
+	return m
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	if fm, ok := msg.(flashMsg); ok {
+		// a newer flash took over
+		if !m.flashing || fm.id != m.flashID {
+			return m, nil
+		}
+		m.frame = fm.frame
+		if m.frame >= flashFrames {
+			m.flashing = false
+			return m, nil
+		}
+		return m, m.nextFrame()
+	}
+	if len(m.board) == 0 {
+		return m, nil
+	}
+	if mm, ok := msg.(tea.MouseMsg); ok {
+		return m.mouse(mm), nil
+	}
+	km, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch {
+	case key.Matches(km, m.KeyMap.Up):
+		if m.row > 0 {
+			m.row--
+		}
+	case key.Matches(km, m.KeyMap.Down):
+		if m.row < len(m.board)-1 {
+			m.row++
+		}
+	case key.Matches(km, m.KeyMap.Left):
+		if m.col > 0 {
+			m.col--
+		}
+	case key.Matches(km, m.KeyMap.Right):
+		if m.col < len(m.board[m.row])-1 {
+			m.col++
+		}
+	case key.Matches(km, m.KeyMap.Home):
+		m.col = 0
+	case key.Matches(km, m.KeyMap.End):
+		m.col = len(m.board[m.row]) - 1
+	case key.Matches(km, m.KeyMap.Top):
+		m.SetCursor(0, m.col)
+	case key.Matches(km, m.KeyMap.Bottom):
+		m.SetCursor(len(m.board)-1, m.col)
+	case key.Matches(km, m.KeyMap.NextRow):
+		m.nextRow()
+	case key.Matches(km, m.KeyMap.Select):
+		m.toggle()
+	case key.Matches(km, m.KeyMap.Submit):
+		return m, m.Submit()
+	}
+	return m, nil
+}
+
+// nextRow moves the cursor to the first object of the next row that has
+// any, starting over at the top after the last row.
+func (m *Model) nextRow() {
+	for i := 1; i <= len(m.board); i++ {
+		r := (m.row + i) % len(m.board)
+		for c, avail := range m.board[r] {
+			if avail {
+				m.row, m.col = r, c
+				return
+			}
+		}
+	}
+}
+
+// toggle selects the object under the cursor, or all objects between it and
+// the selection in the same row. Selecting a selected object clears the
+// selection.
+func (m *Model) toggle() {
+	if !m.board[m.row][m.col] {
+		return
+	}
+	switch {
+	case !m.selected || m.sel.Row != m.row:
+		m.selected = true
+		m.sel = variant.Move{Row: m.row, From: m.col, To: m.col}
+	case m.col >= m.sel.From && m.col <= m.sel.To:
+		m.selected = false
+	case m.col < m.sel.From:
+		m.sel.From = m.col
+	default:
+		m.sel.To = m.col
+	}
+}
+
+func markers(cursor, selected bool) string {
+	b := []byte("  ")
+	if cursor {
+		b[0] = '>'
+	}
+	if selected {
+		b[1] = '*'
+	}
+	return string(b)
+}
+
+func (m Model) View() string {
+	if m.Sticks {
+		return m.sticksView()
+	}
+	var b strings.Builder
+	width := m.cellWidth()
+	b.WriteString(m.columnLabels(width))
+	for r, row := range m.board {
+		b.WriteString(m.rowLabel(r, true))
+		for c := range row {
+			shown, style, gap := m.cell(r, c)
+			mark := m.hints.Empty
+			if shown {
+				mark = m.object()
+			}
+			if w := lipgloss.Width(mark); w < width {
+				mark += strings.Repeat(" ", width-w)
+			}
+			b.WriteString(gap + style.Render(mark))
+		}
+		b.WriteString(m.rowCount(r) + "\n")
+	}
+	return b.String()
+}
+
+// sticksView draws the objects as matchsticks with an empty line between
+// the rows.
+func (m Model) sticksView() string {
+	var b strings.Builder
+	b.WriteString(m.columnLabels(stickWidth))
+	for r, row := range m.board {
+		if r > 0 {
+			b.WriteString("\n")
+		}
+		for i, line := range stick {
+			b.WriteString(m.rowLabel(r, i == 0))
+			for c := range row {
+				shown, style, gap := m.cell(r, c)
+				if i > 0 {
+					// the markers are only in front of the heads
+					gap = "  "
+				}
+				part := line
+				if !shown {
+					part = strings.Repeat(" ", stickWidth)
+				}
+				b.WriteString(gap + style.Render(part))
+			}
+			if i == 0 {
+				b.WriteString(m.rowCount(r))
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// rowCount is the number of objects left in row r behind the row, empty
+// without Counts.
+func (m Model) rowCount(r int) string {
+	if !m.Counts {
+		return ""
+	}
+	n := 0
+	for _, avail := range m.board[r] {
+		if avail {
+			n++
+		}
+	}
+	return "  " + m.Styles.Label.Render(strconv.Itoa(n))
+}
+
+// ColumnName returns the letter of column c, counted from 0, like in a
+// spreadsheet: A to Z, then AA and so on.
+func ColumnName(c int) string {
+	name := ""
+	for c++; c > 0; c = (c - 1) / 26 {
+		name = string(rune('A'+(c-1)%26)) + name
+	}
+	return name
+}
+
+// labelWidth is the width of the row numbers, 0 without Labels.
+func (m Model) labelWidth() int {
+	if !m.Labels {
+		return 0
+	}
+	return len(strconv.Itoa(len(m.board))) + 1
+}
+
+// columnLabels is the line of column letters over cells that are width
+// wide, empty without Labels. The letters are over the middle of the cells.
+func (m Model) columnLabels(width int) string {
+	if !m.Labels || len(m.board) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(strings.Repeat(" ", m.labelWidth()))
+	for c := range m.board[0] {
+		name := ColumnName(c)
+		// right aligned up to the middle of the cell
+		mid := 2 + (width+1)/2
+		label := fmt.Sprintf("%*s", mid, name)
+		b.WriteString(m.Styles.Label.Render(label) + strings.Repeat(" ", 2+width-lipgloss.Width(label)))
+	}
+	return strings.TrimRight(b.String(), " ") + "\n"
+}
+
+// rowLabel is the number of row r if show is set, or as many spaces.
+func (m Model) rowLabel(r int, show bool) string {
+	w := m.labelWidth()
+	if w == 0 {
+		return ""
+	}
+	if !show {
+		return strings.Repeat(" ", w)
+	}
+	return m.Styles.Label.Render(fmt.Sprintf("%*d", w-1, r+1)) + " "
+}
+
+// cell returns whether the object in row r and column c is shown, the style
+// it is drawn with and the gap in front of it.
+func (m Model) cell(r, c int) (shown bool, style lipgloss.Style, gap string) {
+	style = m.Styles.Cell.Copy()
+	shown = m.board[r][c]
+	if fs, fstyle, ok := m.flashed(r, c); ok {
+		shown, style = fs, fstyle.Inherit(style)
+	}
+	cursor := r == m.row && c == m.col
+	selected := m.selected && r == m.sel.Row && c >= m.sel.From && c <= m.sel.To
+	if cursor {
+		style = m.Styles.Cursor.Copy().Inherit(style)
+	} else if m.remote && r == m.remoteRow && c == m.remoteCol {
+		style = m.Styles.Remote.Copy().Inherit(style)
+	}
+	if selected {
+		style = m.Styles.Selected.Copy().Inherit(style)
+	}
+	gap = "  "
+	if m.Markers {
+		gap = markers(cursor, selected)
+	}
+	return shown, style, gap
+}
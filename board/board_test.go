@@ -0,0 +1,248 @@
+package board
+
+import (
+	"fmt"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jheuel/nimm/variant"
+)
+
+var testHints = variant.Hints{Object: "X", Empty: "."}
+
+// keys passes the keys to m one by one and returns the command of the
+// last one.
+func keys(m Model, names ...string) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+	for _, name := range names {
+		var msg tea.KeyMsg
+		switch name {
+		case "up":
+			msg.Type = tea.KeyUp
+		case "down":
+			msg.Type = tea.KeyDown
+		case "left":
+			msg.Type = tea.KeyLeft
+		case "right":
+			msg.Type = tea.KeyRight
+		case "home":
+			msg.Type = tea.KeyHome
+		case "end":
+			msg.Type = tea.KeyEnd
+		case "tab":
+			msg.Type = tea.KeyTab
+		case "enter":
+			msg.Type = tea.KeyEnter
+		case " ":
+			msg.Type = tea.KeySpace
+			msg.Runes = []rune(" ")
+		default:
+			msg.Type, msg.Runes = tea.KeyRunes, []rune(name)
+		}
+		m, cmd = m.Update(msg)
+	}
+	return m, cmd
+}
+
+// submitted returns the move the command of OnMove was created for.
+func submitted(t *testing.T, cmd tea.Cmd) (variant.Move, bool) {
+	t.Helper()
+	if cmd == nil {
+		return variant.Move{}, false
+	}
+	mv, ok := cmd().(variant.Move)
+	if !ok {
+		t.Fatal("the command didn't return a move")
+	}
+	return mv, true
+}
+
+func newTestModel(b variant.Board) Model {
+	m := New(b, testHints)
+	m.OnMove = func(mv variant.Move) tea.Cmd {
+		return func() tea.Msg { return mv }
+	}
+	return m
+}
+
+func TestKeys(t *testing.T) {
+	pyramid := variant.MustGet(variant.Default).Setup()
+	tests := []struct {
+		name  string
+		quick bool
+		keys  []string
+		// wantRow and wantCol are the cursor after the keys
+		wantRow, wantCol int
+		wantMove         *variant.Move
+	}{
+		{"arrows", false, []string{"down", "down", "right", "right", "up", "left"}, 1, 1, nil},
+		{"vi keys", false, []string{"j", "j", "l", "k", "h", "j"}, 2, 0, nil},
+		{"stays on the board", false, []string{"up", "left", "G", "down", "end", "right"}, 3, 6, nil},
+		{"jumps", false, []string{"G", "end", "home", "g"}, 0, 0, nil},
+		{"next row", false, []string{"tab", "tab", "tab", "tab"}, 0, 3, nil},
+		{"nothing selected", false, []string{"tab", "enter"}, 1, 2, nil},
+		{"quick", true, []string{"tab", "enter"}, 1, 2, &variant.Move{Row: 1, From: 2, To: 2}},
+		{"quick on an empty cell", true, []string{"enter"}, 0, 0, nil},
+		{"range", false, []string{"G", "right", " ", "right", "right", " ", "enter"}, 3, 3, &variant.Move{Row: 3, From: 1, To: 3}},
+		{"range to the left", true, []string{"G", "end", " ", "home", " ", "enter"}, 3, 0, &variant.Move{Row: 3, From: 0, To: 6}},
+		{"deselect", true, []string{"G", " ", "right", " ", " ", "enter"}, 3, 1, &variant.Move{Row: 3, From: 1, To: 1}},
+		{"other row", false, []string{"G", " ", "up", "right", " ", "enter"}, 2, 1, &variant.Move{Row: 2, From: 1, To: 1}},
+		{"empty cells can't be selected", false, []string{" ", "enter"}, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestModel(pyramid)
+			m.Quick = tt.quick
+			m, cmd := keys(m, tt.keys...)
+			if row, col := m.Cursor(); row != tt.wantRow || col != tt.wantCol {
+				t.Errorf("cursor at %d, %d, want %d, %d", row, col, tt.wantRow, tt.wantCol)
+			}
+			mv, ok := submitted(t, cmd)
+			if tt.wantMove == nil && ok {
+				t.Errorf("submitted %v", mv)
+			}
+			if tt.wantMove != nil && mv != *tt.wantMove {
+				t.Errorf("submitted %v, %v, want %v", mv, ok, *tt.wantMove)
+			}
+		})
+	}
+}
+
+func TestSetBoard(t *testing.T) {
+	m := newTestModel(variant.Board{{true, true, true}, {true, true, true}})
+	m, _ = keys(m, "down", "end", " ")
+	m.SetBoard(variant.Board{{true, true}})
+	if row, col := m.Cursor(); row != 0 || col != 1 {
+		t.Errorf("cursor at %d, %d after a smaller board", row, col)
+	}
+	m.Reset()
+	if row, col := m.Cursor(); row != 0 || col != 0 {
+		t.Errorf("cursor at %d, %d after Reset", row, col)
+	}
+	if mv, ok := m.Selection(); ok {
+		t.Errorf("%v still selected after Reset", mv)
+	}
+}
+
+func TestMouse(t *testing.T) {
+	m := newTestModel(variant.Board{{true, true, true, true}, {true, false, true, true}})
+	click := func(typ tea.MouseEventType, x, y int) {
+		m, _ = m.Update(tea.MouseMsg{Type: typ, X: x, Y: y})
+	}
+	// each cell is two spaces and the mark
+	click(tea.MouseLeft, 2, 1)
+	click(tea.MouseLeft, 8, 1)
+	// dragging into another row doesn't change the selection
+	click(tea.MouseLeft, 11, 0)
+	click(tea.MouseRelease, 8, 1)
+	if mv, ok := m.Selection(); !ok || mv != (variant.Move{Row: 1, From: 0, To: 2}) {
+		t.Errorf("selection after dragging = %v, %v", mv, ok)
+	}
+	if row, col := m.Cursor(); row != 1 || col != 2 {
+		t.Errorf("cursor at %d, %d after dragging", row, col)
+	}
+	// clicking the selection clears it, clicking outside does nothing
+	click(tea.MouseLeft, 8, 1)
+	click(tea.MouseRelease, 8, 1)
+	click(tea.MouseLeft, 40, 0)
+	if mv, ok := m.Selection(); ok {
+		t.Errorf("%v still selected", mv)
+	}
+}
+
+func TestCellAt(t *testing.T) {
+	b := variant.Board{{true, true, true}, {true, true, true}}
+	tests := []struct {
+		name   string
+		labels bool
+		sticks bool
+		x, y   int
+		wantOK bool
+		row    int
+		col    int
+	}{
+		{"first", false, false, 2, 0, true, 0, 0},
+		{"gap", false, false, 3, 1, true, 1, 1},
+		{"right of the board", false, false, 9, 0, false, 0, 0},
+		{"below the board", false, false, 2, 2, false, 0, 0},
+		{"labels", true, false, 4, 1, true, 0, 0},
+		{"on the labels", true, false, 1, 1, false, 0, 0},
+		{"sticks", false, true, 7, 2, true, 0, 1},
+		{"between sticks", false, true, 2, 3, false, 0, 0},
+		{"second row of sticks", false, true, 12, 4, true, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(b, testHints)
+			m.Labels, m.Sticks = tt.labels, tt.sticks
+			row, col, ok := m.CellAt(tt.x, tt.y)
+			if ok != tt.wantOK || ok && (row != tt.row || col != tt.col) {
+				t.Errorf("CellAt(%d, %d) = %d, %d, %v, want %d, %d, %v", tt.x, tt.y, row, col, ok, tt.row, tt.col, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestView(t *testing.T) {
+	b := variant.Board{{true, false, true}, {false, true, false}}
+	tests := []struct {
+		name  string
+		setup func(*Model)
+		want  string
+	}{
+		{"plain", func(*Model) {}, "  X  .  X\n  .  X  .\n"},
+		{"markers", func(m *Model) {
+			m.Markers = true
+			m.toggle()
+		}, ">*X  .  X\n  .  X  .\n"},
+		{"glyph", func(m *Model) { m.Glyph = "🔥" }, "  🔥  .   🔥\n  .   🔥  . \n"},
+		{"labels and counts", func(m *Model) { m.Labels, m.Counts = true, true }, "    A  B  C\n1   X  .  X  2\n2   .  X  .  1\n"},
+		{"sticks", func(m *Model) { m.Sticks = true }, "   o         o \n   |         | \n   |         | \n\n        o      \n        |      \n        |      \n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(b, testHints)
+			tt.setup(&m)
+			if got := m.View(); got != tt.want {
+				t.Errorf("View() =\n%q, want\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlash(t *testing.T) {
+	m := New(variant.Board{{true, false, true}}, testHints)
+	m.Flash(variant.Move{Row: 0, From: 0, To: 2})
+	m.SetBoard(variant.Board{{false, false, false}})
+	var shown []bool
+	for frame := 1; m.flashing; frame++ {
+		s, _, _ := m.flashed(0, 0)
+		shown = append(shown, s)
+		if s, _, ok := m.flashed(0, 1); ok || s {
+			t.Fatal("an empty cell flashed")
+		}
+		var cmd tea.Cmd
+		if m, cmd = m.Update(flashMsg{m.flashID, frame}); (cmd == nil) == m.flashing {
+			t.Fatalf("frame %d: command %v while flashing is %v", frame, cmd != nil, m.flashing)
+		}
+	}
+	if got, want := fmt.Sprint(shown), "[true false true false true]"; got != want {
+		t.Errorf("frames = %v, want %v", got, want)
+	}
+
+	// frames of an older flash are dropped
+	m.Flash(variant.Move{Row: 0, From: 0, To: 0})
+	old := m.flashID
+	m.Flash(variant.Move{Row: 0, From: 2, To: 2})
+	if m, _ = m.Update(flashMsg{old, 1}); m.frame != 0 {
+		t.Error("a frame of an older flash was shown")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for c, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := ColumnName(c); got != want {
+			t.Errorf("ColumnName(%d) = %q, want %q", c, got, want)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/jheuel/nimm/variant"
+)
+
+const botUsage = `usage: bot [first|second] [engine]
+
+Plays a game against the server's solver, or the named engine, one JSON
+object per line. The server sends
+  {"type":"state","game":"...","field":[[false,true,...],...],"player":1,"you":1,"moves":0}
+before every move, and answers your moves with
+  {"type":"error","error":"invalid move"}
+if they are not allowed. Send a move as
+  {"row":3,"from":0,"to":2}
+to take the objects in columns 0 to 2 of the bottom row. The game ends with
+  {"type":"end","game":"...","winner":2,"you":1}
+The player who has to take the last object loses.`
+
+var errBotUsage = errors.New(botUsage)
+
+// botMessage is a line sent to a bot.
+type botMessage struct {
+	Type   string   `json:"type"`
+	Game   string   `json:"game,omitempty"`
+	Field  [][]bool `json:"field,omitempty"`
+	Player int      `json:"player,omitempty"`
+	You    int      `json:"you,omitempty"`
+	Moves  int      `json:"moves"`
+	Winner int      `json:"winner,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// botMove is a line received from a bot.
+type botMove struct {
+	Row  *int `json:"row"`
+	From *int `json:"from"`
+	To   *int `json:"to"`
+}
+
+// botMiddleware lets programs play over `ssh host bot`, or any session
+// without a terminal, using a line based JSON protocol.
+func botMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			cmd := s.Command()
+			_, _, active := s.Pty()
+			if len(cmd) > 0 && cmd[0] != "bot" || len(cmd) == 0 && active {
+				sh(s)
+				return
+			}
+			if err := playBot(s, s, displayName(prefs.get(sessionKey(s)), s.User()), sessionOwner(sessionKey(s), s.Context().SessionID()), cmd); err != nil {
+				wish.Fatalln(s, err)
+				return
+			}
+			_ = s.Exit(0)
+		}
+	}
+}
+
+// playBot plays one game of user, who moves as owner, against an engine,
+// reading moves from r and writing state updates to w.
+func playBot(r io.Reader, w io.Writer, user, owner string, cmd []string) error {
+	you, name := 1, ""
+	if len(cmd) > 1 {
+		switch cmd[1] {
+		case "first":
+		case "second":
+			you = 2
+		default:
+			return errBotUsage
+		}
+	}
+	if len(cmd) > 2 {
+		name = cmd[2]
+	}
+	if len(cmd) > 3 {
+		return errBotUsage
+	}
+	eng, err := newEngine(name)
+	if err != nil {
+		return err
+	}
+	defer eng.close()
+
+	g := games.create(variant.MustGet(variant.Default))
+	g.seat(you, user, owner)
+	fireEvent(hookGameStarted, newAPIGame(g.state()))
+	enc := json.NewEncoder(w)
+	lines := bufio.NewScanner(r)
+	for {
+		state := g.state()
+		if state.finished() {
+			return enc.Encode(botMessage{
+				Type:   "end",
+				Game:   state.id,
+				Moves:  state.moves,
+				Winner: state.winner(),
+				You:    you,
+			})
+		}
+		if state.player != you {
+			if _, err := playEngineMove(g, eng, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := enc.Encode(botMessage{
+			Type:   "state",
+			Game:   state.id,
+			Field:  state.field,
+			Player: state.player,
+			You:    you,
+			Moves:  state.moves,
+		}); err != nil {
+			return err
+		}
+		for {
+			if !lines.Scan() {
+				if err := lines.Err(); err != nil {
+					return err
+				}
+				// the bot gave up
+				return nil
+			}
+			err := takeBotMove(g, owner, lines.Bytes())
+			if err == nil {
+				break
+			}
+			if err := enc.Encode(botMessage{Type: "error", Moves: state.moves, Error: err.Error()}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func takeBotMove(g *game, owner string, line []byte) error {
+	var mv botMove
+	if err := json.Unmarshal(line, &mv); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if mv.Row == nil || mv.From == nil || mv.To == nil {
+		return errors.New(`a move needs "row", "from" and "to"`)
+	}
+	_, err := g.takeFor(owner, *mv.Row, *mv.From, *mv.To)
+	return err
+}
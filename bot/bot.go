@@ -0,0 +1,271 @@
+// Package bot is a client for the bot protocol of a nimm server, spoken over
+// `ssh host bot`. It connects, plays and reconnects; a bot only picks its
+// moves:
+//
+//	signer, _ := ssh.ParsePrivateKey(key)
+//	b := &bot.Bot{
+//		Addr:   "nimm.example.com:22",
+//		User:   "mybot",
+//		Signer: signer,
+//		OnYourTurn: func(p bot.Position) bot.Move {
+//			// take the first object that is left
+//			for row, objects := range p.Field {
+//				for col, left := range objects {
+//					if left {
+//						return bot.Move{Row: row, From: col, To: col}
+//					}
+//				}
+//			}
+//			return bot.Move{}
+//		},
+//		OnGameEnd: func(r bot.Result) {
+//			log.Printf("game %s: won %t", r.Game, r.Won())
+//		},
+//	}
+//	log.Fatal(b.Run(context.Background()))
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	dialTimeout = 30 * time.Second
+	maxBackoff  = time.Minute
+)
+
+var (
+	// ErrRejected is returned by Run when the server did not accept a move.
+	ErrRejected = errors.New("move rejected")
+	// ErrHostKeyChanged is returned when the server presents another key
+	// than the one the bot first connected to.
+	ErrHostKeyChanged = errors.New("host key changed")
+)
+
+// Position is the game when it is the bot's turn.
+type Position struct {
+	Game string
+	// Field is true where an object is left.
+	Field [][]bool
+	// You is the bot's player, 1 or 2.
+	You   int
+	Moves int
+}
+
+// Move takes the objects in columns From to To of Row.
+type Move struct {
+	Row  int `json:"row"`
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+// Result is a finished game.
+type Result struct {
+	Game   string
+	You    int
+	Winner int
+	Moves  int
+}
+
+// Won reports whether the bot won the game.
+func (r Result) Won() bool {
+	return r.You == r.Winner
+}
+
+// message is a line sent by the server.
+type message struct {
+	Type   string   `json:"type"`
+	Game   string   `json:"game"`
+	Field  [][]bool `json:"field"`
+	You    int      `json:"you"`
+	Moves  int      `json:"moves"`
+	Winner int      `json:"winner"`
+	Error  string   `json:"error"`
+}
+
+// Bot plays games on a nimm server.
+type Bot struct {
+	// Addr is host:port of the server.
+	Addr string
+	User string
+	// Signer authenticates the bot. Without one it logs in as a guest,
+	// which the server might not allow.
+	Signer ssh.Signer
+	// HostKeyCallback checks the server's key. If it is nil, the key of
+	// the first connection is trusted and later connections must present
+	// the same key.
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Seat is "first" or "second", first if empty.
+	Seat string
+	// Engine is the opponent, the server's solver if empty.
+	Engine string
+	// Games is how many games to play, 0 to play until ctx is done.
+	Games int
+
+	// OnYourTurn picks the next move.
+	OnYourTurn func(Position) Move
+	// OnGameEnd is told about every finished game, if it is set.
+	OnGameEnd func(Result)
+
+	mu     sync.Mutex
+	pinned ssh.PublicKey
+	// changed is set once the server presented another key
+	changed bool
+}
+
+// Run plays games until Games are done or ctx is. Lost connections are
+// retried with backoff, a rejected move ends Run with ErrRejected.
+func (b *Bot) Run(ctx context.Context) error {
+	if b.OnYourTurn == nil {
+		return errors.New("bot: OnYourTurn is not set")
+	}
+	backoff := time.Second
+	for played := 0; b.Games == 0 || played < b.Games; {
+		res, err := b.play(ctx)
+		if err == nil {
+			played++
+			backoff = time.Second
+			if b.OnGameEnd != nil {
+				b.OnGameEnd(res)
+			}
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if errors.Is(err, ErrRejected) || errors.Is(err, ErrHostKeyChanged) {
+			return err
+		}
+		log.Printf("bot: %v, reconnecting in %v", err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	return nil
+}
+
+// play plays one game on a new connection.
+func (b *Bot) play(ctx context.Context) (Result, error) {
+	auth := []ssh.AuthMethod{
+		ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			return make([]string, len(questions)), nil
+		}),
+	}
+	if b.Signer != nil {
+		auth = []ssh.AuthMethod{ssh.PublicKeys(b.Signer)}
+	}
+	config := &ssh.ClientConfig{
+		User:            b.User,
+		Auth:            auth,
+		HostKeyCallback: b.checkHostKey,
+		Timeout:         dialTimeout,
+	}
+	d := net.Dialer{Timeout: dialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", b.Addr)
+	if err != nil {
+		return Result{}, err
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, b.Addr, config)
+	if err != nil {
+		conn.Close()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if b.changed {
+			// the handshake error doesn't wrap ours
+			return Result{}, ErrHostKeyChanged
+		}
+		return Result{}, err
+	}
+	client := ssh.NewClient(c, chans, reqs)
+	defer client.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			client.Close()
+		case <-done:
+		}
+	}()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		return Result{}, err
+	}
+	defer sess.Close()
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		return Result{}, err
+	}
+	stdout, err := sess.StdoutPipe()
+	if err != nil {
+		return Result{}, err
+	}
+	var stderr bytes.Buffer
+	sess.Stderr = &stderr
+	seat := b.Seat
+	if seat == "" {
+		seat = "first"
+	}
+	if err := sess.Start(strings.TrimSpace("bot " + seat + " " + b.Engine)); err != nil {
+		return Result{}, err
+	}
+
+	dec := json.NewDecoder(stdout)
+	enc := json.NewEncoder(stdin)
+	for {
+		var msg message
+		if err := dec.Decode(&msg); err != nil {
+			if s := strings.TrimSpace(stderr.String()); s != "" {
+				return Result{}, fmt.Errorf("server: %s", s)
+			}
+			return Result{}, err
+		}
+		switch msg.Type {
+		case "state":
+			mv := b.OnYourTurn(Position{Game: msg.Game, Field: msg.Field, You: msg.You, Moves: msg.Moves})
+			if err := enc.Encode(mv); err != nil {
+				return Result{}, err
+			}
+		case "error":
+			return Result{}, fmt.Errorf("%w: %s", ErrRejected, msg.Error)
+		case "end":
+			_ = stdin.Close()
+			return Result{Game: msg.Game, You: msg.You, Winner: msg.Winner, Moves: msg.Moves}, nil
+		}
+	}
+}
+
+func (b *Bot) checkHostKey(hostname string, remote net.Addr, key ssh.PublicKey) error {
+	if b.HostKeyCallback != nil {
+		return b.HostKeyCallback(hostname, remote, key)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pinned == nil {
+		b.pinned = key
+		return nil
+	}
+	if !bytes.Equal(b.pinned.Marshal(), key.Marshal()) {
+		b.changed = true
+		return ErrHostKeyChanged
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func testSigner(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// testServer speaks the bot protocol on a local address with the host keys
+// of the connections in turn. Every game sends one state, reads the move
+// and answers with reply, which ends the game unless it is an error.
+func testServer(t *testing.T, reply message, hostKeys ...ssh.Signer) (addr string, moves <-chan Move, commands <-chan string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	movec := make(chan Move, len(hostKeys))
+	cmdc := make(chan string, len(hostKeys))
+	go func() {
+		for _, key := range hostKeys {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			config := &ssh.ServerConfig{
+				PublicKeyCallback: func(ssh.ConnMetadata, ssh.PublicKey) (*ssh.Permissions, error) { return nil, nil },
+			}
+			config.AddHostKey(key)
+			serveGame(conn, config, reply, movec, cmdc)
+		}
+	}()
+	return l.Addr().String(), movec, cmdc
+}
+
+func serveGame(conn net.Conn, config *ssh.ServerConfig, reply message, moves chan<- Move, commands chan<- string) {
+	defer conn.Close()
+	sc, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	defer sc.Close()
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		ch, chReqs, err := nc.Accept()
+		if err != nil {
+			return
+		}
+		for req := range chReqs {
+			if req.Type != "exec" {
+				_ = req.Reply(false, nil)
+				continue
+			}
+			_ = req.Reply(true, nil)
+			// the command is a string with its length in front
+			commands <- string(req.Payload[4:])
+			enc := json.NewEncoder(ch)
+			_ = enc.Encode(message{Type: "state", Game: "g1", Field: [][]bool{{true, true}}, You: 1})
+			var mv Move
+			if err := json.NewDecoder(ch).Decode(&mv); err != nil {
+				return
+			}
+			moves <- mv
+			_ = enc.Encode(reply)
+			_, _ = ch.SendRequest("exit-status", false, make([]byte, 4))
+			ch.Close()
+			return
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	host := testSigner(t)
+	addr, moves, commands := testServer(t, message{Type: "end", Game: "g1", You: 1, Winner: 1, Moves: 1}, host, host)
+	var results []Result
+	b := &Bot{
+		Addr:   addr,
+		User:   "bot",
+		Signer: testSigner(t),
+		Seat:   "second",
+		Engine: "random",
+		Games:  2,
+		OnYourTurn: func(p Position) Move {
+			return Move{Row: 0, From: 0, To: 0}
+		},
+		OnGameEnd: func(r Result) { results = append(results, r) },
+	}
+	if err := b.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !results[0].Won() || results[0].Game != "g1" {
+		t.Errorf("results %+v, want two won games", results)
+	}
+	if cmd := <-commands; cmd != "bot second random" {
+		t.Errorf("command %q, want bot second random", cmd)
+	}
+	if mv := <-moves; mv != (Move{0, 0, 0}) {
+		t.Errorf("move %+v", mv)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	if err := (&Bot{}).Run(context.Background()); err == nil {
+		t.Error("Run without OnYourTurn didn't fail")
+	}
+
+	addr, _, _ := testServer(t, message{Type: "error", Error: "not your turn"}, testSigner(t))
+	b := &Bot{Addr: addr, User: "bot", Signer: testSigner(t), OnYourTurn: func(Position) Move { return Move{} }}
+	if err := b.Run(context.Background()); !errors.Is(err, ErrRejected) {
+		t.Errorf("Run with a rejected move = %v, want %v", err, ErrRejected)
+	}
+
+	// the server comes back with another key
+	addr, _, _ = testServer(t, message{Type: "end", You: 1, Winner: 2}, testSigner(t), testSigner(t))
+	b = &Bot{Addr: addr, User: "bot", Signer: testSigner(t), Games: 2, OnYourTurn: func(Position) Move { return Move{} }}
+	if err := b.Run(context.Background()); !errors.Is(err, ErrHostKeyChanged) {
+		t.Errorf("Run with a changed host key = %v, want %v", err, ErrHostKeyChanged)
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// matrixConfig is a Matrix room to announce events in.
+type matrixConfig struct {
+	// Homeserver is the base URL of the homeserver, e.g.
+	// https://matrix.example.org.
+	Homeserver string `json:"homeserver"`
+	// AccessToken belongs to the account that posts the announcements,
+	// which has to have joined Room.
+	AccessToken string      `json:"access_token"`
+	Room        string      `json:"room"`
+	Events      eventFilter `json:"events"`
+}
+
+// postMatrix sends text to the room as a notice.
+func postMatrix(m matrixConfig, text string) error {
+	b, err := json.Marshal(struct {
+		MsgType string `json:"msgtype"`
+		Body    string `json:"body"`
+	}{"m.notice", text})
+	if err != nil {
+		return err
+	}
+	txn := make([]byte, 8)
+	_, _ = rand.Read(txn)
+	u := strings.TrimSuffix(m.Homeserver, "/") + "/_matrix/client/v3/rooms/" +
+		url.PathEscape(m.Room) + "/send/m.room.message/" + hex.EncodeToString(txn)
+	req, err := http.NewRequest(http.MethodPut, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.AccessToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+const (
+	// ircTimeout is how long the connection to the IRC server may be silent
+	// before we give up on it. Servers ping idle clients well before that.
+	ircTimeout = 5 * time.Minute
+	// ircMaxBackoff is the longest we wait before reconnecting.
+	ircMaxBackoff = 5 * time.Minute
+)
+
+// ircConfig is an IRC channel to announce events in.
+type ircConfig struct {
+	// Server is host:port of the IRC server.
+	Server  string      `json:"server"`
+	TLS     bool        `json:"tls"`
+	Nick    string      `json:"nick"`
+	Channel string      `json:"channel"`
+	Events  eventFilter `json:"events"`
+}
+
+// ircUnsafe replaces the characters that would end an IRC message or start
+// a new one, so that names and config values can't inject commands.
+var ircUnsafe = strings.NewReplacer("\r", " ", "\n", " ", "\x00", "")
+
+// ircBridge keeps a connection to an IRC server and posts announcements to
+// the configured channel. Announcements made while it is not connected are
+// dropped.
+type ircBridge struct {
+	c   ircConfig
+	out chan string
+}
+
+// irc is the IRC bridge, if one is configured.
+var irc *ircBridge
+
+func startIRC(c ircConfig) *ircBridge {
+	if c.Nick == "" {
+		c.Nick = "nimm"
+	}
+	b := &ircBridge{c: c, out: make(chan string, 32)}
+	go b.run()
+	return b
+}
+
+func (b *ircBridge) say(text string) {
+	select {
+	case b.out <- text:
+	default:
+		log.Printf("Dropping IRC announcement, the queue is full")
+	}
+}
+
+// run reconnects to the server whenever the connection is lost.
+func (b *ircBridge) run() {
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := b.session()
+		log.Printf("IRC connection to %s lost: %v", b.c.Server, err)
+		if time.Since(start) > ircMaxBackoff {
+			backoff = time.Second
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > ircMaxBackoff {
+			backoff = ircMaxBackoff
+		}
+	}
+}
+
+// session connects to the server, joins the channel and posts announcements
+// until the connection breaks.
+func (b *ircBridge) session() error {
+	d := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	var err error
+	if b.c.TLS {
+		conn, err = tls.DialWithDialer(d, "tcp", b.c.Server, nil)
+	} else {
+		conn, err = d.Dial("tcp", b.c.Server)
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	send := func(format string, args ...interface{}) error {
+		for i, a := range args {
+			if s, ok := a.(string); ok {
+				args[i] = ircUnsafe.Replace(s)
+			}
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(30 * time.Second))
+		_, err := fmt.Fprintf(conn, format+"\r\n", args...)
+		return err
+	}
+	nick := b.c.Nick
+	if err := send("NICK %s", nick); err != nil {
+		return err
+	}
+	if err := send("USER %s 0 * :nimm announcements", b.c.Nick); err != nil {
+		return err
+	}
+
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		r := bufio.NewScanner(conn)
+		for {
+			_ = conn.SetReadDeadline(time.Now().Add(ircTimeout))
+			if !r.Scan() {
+				err := r.Err()
+				if err == nil {
+					err = fmt.Errorf("connection closed")
+				}
+				errs <- err
+				close(lines)
+				return
+			}
+			select {
+			case lines <- r.Text():
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// only take announcements once we are in the channel
+	var out chan string
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case line, ok := <-lines:
+			if !ok {
+				return <-errs
+			}
+			if strings.HasPrefix(line, ":") {
+				// drop the prefix
+				if i := strings.IndexByte(line, ' '); i > 0 {
+					line = line[i+1:]
+				}
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "PING":
+				err = send("PONG %s", strings.TrimPrefix(line, "PING "))
+			case "001":
+				// registered
+				err = send("JOIN %s", b.c.Channel)
+				out = b.out
+			case "433":
+				// nickname in use
+				nick += "_"
+				err = send("NICK %s", nick)
+			}
+			if err != nil {
+				return err
+			}
+		case text := <-out:
+			for _, l := range strings.Split(text, "\n") {
+				if err := send("PRIVMSG %s :%s", b.c.Channel, l); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jheuel/nimm/variant"
+)
+
+// campaignLevel is a stage of the campaign, played against the computer
+// with the classic rules.
+type campaignLevel struct {
+	name string
+	// board is the field at the start, a row per string with o for an
+	// object
+	board []string
+	// opponent is the name of the computer player and skill how likely it
+	// makes the best move instead of a random one
+	opponent string
+	skill    float64
+	// seat is the seat of the player, 0 tosses a coin. Against a perfect
+	// opponent the player always gets the seat that can win.
+	seat int
+}
+
+// campaignLevels are played in order, each one after the player beat the
+// one before.
+var campaignLevels = []campaignLevel{
+	{
+		name:     "Three rows",
+		board:    []string{"..o..", ".ooo.", "ooooo"},
+		opponent: "Pip",
+		skill:    0.2,
+	},
+	{
+		name:     "The pyramid",
+		board:    []string{"...o...", "..ooo..", ".ooooo.", "ooooooo"},
+		opponent: "Moss",
+		skill:    0.5,
+	},
+	{
+		name:     "Going second",
+		board:    []string{"...o...", "..ooo..", ".ooooo.", "ooooooo"},
+		opponent: "Quill",
+		skill:    0.7,
+		seat:     2,
+	},
+	{
+		name:     "Gaps",
+		board:    []string{"ooo.ooo", "o.ooo.o", "ooooooo", "oo...oo", "ooo.ooo"},
+		opponent: "Sage",
+		skill:    0.85,
+	},
+	{
+		name:     "The tower",
+		board:    []string{"....o....", "...ooo...", "..ooooo..", ".ooooooo.", "ooooooooo"},
+		opponent: "Nimbus",
+		skill:    1,
+	},
+}
+
+// campaignLevelFor is the level a player plays next after beating done
+// levels. Once they beat all of them, they play the last one again.
+func campaignLevelFor(done int) int {
+	if done >= len(campaignLevels) {
+		return len(campaignLevels)
+	}
+	return done + 1
+}
+
+// campaignComplete reports whether a player beat all levels, which earns
+// them the badge.
+func campaignComplete(done int) bool {
+	return done >= len(campaignLevels)
+}
+
+// campaignBadge is shown next to the name of players who completed the
+// campaign.
+const campaignBadge = "★"
+
+// setup is the board of the level.
+func (l campaignLevel) setup() variant.Board {
+	// the levels are known to be valid
+	b, _ := parseBoard(l.board)
+	return b
+}
+
+// playerSeat is the seat of the player in a game of the level.
+func (l campaignLevel) playerSeat() int {
+	if l.seat != 0 {
+		return l.seat
+	}
+	if l.skill >= 1 {
+		// the one who moves first wins if the position is winning
+		if _, winning, _ := solve(l.setup()); winning {
+			return 1
+		}
+		return 2
+	}
+	return 1 + int(time.Now().UnixNano()%2)
+}
+
+// presetVariant is a variant played on another board, e.g. the one of a
+// level.
+type presetVariant struct {
+	variant.Variant
+	board variant.Board
+}
+
+func (v presetVariant) Setup() variant.Board { return v.board.Clone() }
+
+// newCampaignVariant returns the classic game on the board of the level.
+func newCampaignVariant(l campaignLevel) (variant.Variant, error) {
+	v, err := variant.Get(variant.Default)
+	if err != nil {
+		return nil, err
+	}
+	return presetVariant{Variant: v, board: l.setup()}, nil
+}
+
+// campaignEngine is the solver making mistakes on purpose, less often the
+// further the player gets.
+type campaignEngine struct {
+	level campaignLevel
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newCampaignEngine(l campaignLevel) *campaignEngine {
+	return &campaignEngine{level: l, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (e *campaignEngine) name() string { return e.level.opponent }
+
+func (e *campaignEngine) bestMove(field [][]bool) (move, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return skilledMove(e.rand, e.level.skill, field)
+}
+
+func (e *campaignEngine) close() {}
+
+// finishLevel saves that the player beat their level of the campaign once
+// they won it, and tells them what comes next.
+func (m *model) finishLevel() tea.Cmd {
+	if m.level == 0 || m.state.winner() != m.you || m.prefs.Campaign >= m.level {
+		return nil
+	}
+	m.prefs.Campaign = m.level
+	text := m.lang.trf("You beat level %d! Connect again for the next one.", m.level)
+	if campaignComplete(m.prefs.Campaign) {
+		text = m.lang.tr("You completed the campaign!") + " " + campaignBadge
+	}
+	return tea.Batch(m.savePrefs(), m.notify(text, broadcastDuration))
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// forwardedHeader marks API requests that an instance forwarded to
+	// another one, which answers them by itself.
+	forwardedHeader = "X-Nimm-Forwarded"
+	// peerTimeout bounds how long an instance waits for the lobby of another
+	// one.
+	peerTimeout = 2 * time.Second
+)
+
+// clusterConfig lets several instances of the server share the games of the
+// API, so that it can be spread over more than one process. Every game is
+// hosted by the instance that created it, whose name is part of its id, and
+// requests for it are forwarded there from any instance. The open challenges
+// of all instances are listed by each of them. Sessions over SSH, telnet and
+// the web client play on the instance they are connected to.
+type clusterConfig struct {
+	// Instance is the name of this instance, letters and digits.
+	Instance string `json:"instance"`
+	// Peers are the other instances by name with the address of their HTTP
+	// server, e.g. "http://nimm-2:8080".
+	Peers map[string]string `json:"peers"`
+}
+
+// validateCluster checks the Cluster of c.
+func validateCluster(c config) error {
+	cl := c.Cluster
+	if cl == nil {
+		return nil
+	}
+	if !validInstance(cl.Instance) {
+		return fmt.Errorf("cluster: instance %q is not letters and digits", cl.Instance)
+	}
+	for name, addr := range cl.Peers {
+		if !validInstance(name) || name == cl.Instance {
+			return fmt.Errorf("cluster: invalid peer name %q", name)
+		}
+		if u, err := url.Parse(addr); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("cluster: peer %s: %q is not a URL like http://host:port", name, addr)
+		}
+	}
+	return nil
+}
+
+func validInstance(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// gameInstance returns the instance hosting the game id, empty for this one
+// or if the server doesn't run in a cluster.
+func gameInstance(c config, id string) string {
+	i := strings.IndexByte(id, '-')
+	if c.Cluster == nil || i < 0 || id[:i] == c.Cluster.Instance {
+		return ""
+	}
+	return id[:i]
+}
+
+// forwardGame forwards an API request about a game hosted by another
+// instance to it, and reports whether it did. Requests for unknown
+// instances are answered with 404.
+func forwardGame(w http.ResponseWriter, r *http.Request) bool {
+	c := currentConfig()
+	id := strings.TrimPrefix(r.URL.Path, "/api/games/")
+	if id == r.URL.Path || r.Header.Get(forwardedHeader) != "" {
+		return false
+	}
+	if i := strings.IndexByte(id, '/'); i >= 0 {
+		id = id[:i]
+	}
+	instance := gameInstance(c, id)
+	if instance == "" {
+		return false
+	}
+	addr, ok := c.Cluster.Peers[instance]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, errNoGame.Error())
+		return true
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		writeAPIError(w, http.StatusBadGateway, err.Error())
+		return true
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	// stream the events of the game as they come
+	proxy.FlushInterval = -1
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("Could not forward %s to instance %s: %v", r.URL.Path, instance, err)
+		writeAPIError(w, http.StatusBadGateway, "instance "+instance+" is not reachable")
+	}
+	r.Header.Set(forwardedHeader, c.Cluster.Instance)
+	proxy.ServeHTTP(w, r)
+	return true
+}
+
+// peerChallenges returns the open challenges of the other instances, unless
+// r was forwarded by one of them. Instances that don't answer are left out.
+func peerChallenges(r *http.Request) []apiGame {
+	c := currentConfig()
+	if c.Cluster == nil || r.Header.Get(forwardedHeader) != "" {
+		return nil
+	}
+	var (
+		mu  sync.Mutex
+		all []apiGame
+		wg  sync.WaitGroup
+	)
+	for name, addr := range c.Cluster.Peers {
+		wg.Add(1)
+		go func(name, addr string) {
+			defer wg.Done()
+			open, err := fetchChallenges(r.Context(), addr, c.Cluster.Instance)
+			if err != nil {
+				log.Printf("Could not get the challenges of instance %s: %v", name, err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			all = append(all, open...)
+		}(name, addr)
+	}
+	wg.Wait()
+	return all
+}
+
+func fetchChallenges(ctx context.Context, addr, instance string) ([]apiGame, error) {
+	ctx, cancel := context.WithTimeout(ctx, peerTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(addr, "/")+"/api/challenges", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(forwardedHeader, instance)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	var open []apiGame
+	err = json.NewDecoder(resp.Body).Decode(&open)
+	return open, err
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/muesli/termenv"
+)
+
+// colorProfiles are the names of the color profiles players can pick.
+var colorProfiles = map[string]termenv.Profile{
+	"truecolor": termenv.TrueColor,
+	"256":       termenv.ANSI256,
+	"16":        termenv.ANSI,
+	"mono":      termenv.Ascii,
+}
+
+// parseColorProfile returns the profile called name. "auto" and "" mean
+// that it is detected.
+func parseColorProfile(name string) (termenv.Profile, bool, error) {
+	if name == "" || name == "auto" {
+		return 0, false, nil
+	}
+	p, ok := colorProfiles[name]
+	if !ok {
+		return 0, false, fmt.Errorf("unknown colors %q, try auto, truecolor, 256, 16 or mono", name)
+	}
+	return p, true, nil
+}
+
+// detectColorProfile guesses what a terminal supports from its type and the
+// environment the client sent.
+func detectColorProfile(term string, environ []string) termenv.Profile {
+	for _, kv := range environ {
+		// see https://no-color.org
+		if strings.HasPrefix(kv, "NO_COLOR=") && kv != "NO_COLOR=" {
+			return termenv.Ascii
+		}
+	}
+	for _, kv := range environ {
+		if kv == "COLORTERM=truecolor" || kv == "COLORTERM=24bit" {
+			return termenv.TrueColor
+		}
+	}
+	switch {
+	case asciiTerminal(term):
+		return termenv.Ascii
+	case strings.Contains(term, "truecolor") || strings.Contains(term, "direct") ||
+		strings.HasPrefix(term, "alacritty") || strings.HasPrefix(term, "xterm-kitty") || strings.HasPrefix(term, "wezterm"):
+		return termenv.TrueColor
+	case strings.Contains(term, "256color"):
+		return termenv.ANSI256
+	}
+	return termenv.ANSI
+}
+
+// asciiTerminal reports whether a terminal can only show plain characters.
+func asciiTerminal(term string) bool {
+	return term == "" || term == "dumb" || strings.HasPrefix(term, "vt")
+}
+
+// asciiLocale reports whether the locale the client sent can't show
+// Unicode, e.g. LANG=en_US.ISO-8859-1 or LC_ALL=C. If the client sent none,
+// it is assumed that it can.
+func asciiLocale(environ []string) bool {
+	vars := map[string]string{}
+	for _, kv := range environ {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	// the first one that is set wins, like in the C library
+	for _, k := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		v := strings.ToLower(vars[k])
+		if v == "" {
+			continue
+		}
+		return !strings.Contains(v, "utf-8") && !strings.Contains(v, "utf8")
+	}
+	return false
+}
+
+// sessionColors is the color profile of a session of the player with key,
+// their own choice or else the detected one.
+func sessionColors(key, term string, environ []string) termenv.Profile {
+	if p, ok, _ := parseColorProfile(prefs.get(key).Colors); ok {
+		return p
+	}
+	return detectColorProfile(term, environ)
+}
+
+// colorWriter rewrites the colors in the output of a program, which is
+// rendered in true color, for a terminal that supports fewer.
+type colorWriter struct {
+	w       io.Writer
+	profile termenv.Profile
+	// pending is the start of an escape sequence cut off by the last write
+	pending []byte
+}
+
+func newColorWriter(w io.Writer, profile termenv.Profile) io.Writer {
+	if profile == termenv.TrueColor {
+		return w
+	}
+	return &colorWriter{w: w, profile: profile}
+}
+
+func (cw *colorWriter) Write(p []byte) (int, error) {
+	b := append(cw.pending, p...)
+	cw.pending = nil
+	out := make([]byte, 0, len(b))
+	for len(b) > 0 {
+		i := strings.Index(string(b), "\x1b[")
+		if i < 0 {
+			if b[len(b)-1] == 0x1b {
+				cw.pending = []byte{0x1b}
+				b = b[:len(b)-1]
+			}
+			out = append(out, b...)
+			break
+		}
+		out = append(out, b[:i]...)
+		b = b[i:]
+		// the sequence ends with a byte in @ to ~
+		end := 2
+		for end < len(b) && (b[end] < '@' || b[end] > '~') {
+			end++
+		}
+		if end == len(b) {
+			if len(b) < 64 {
+				cw.pending = append([]byte(nil), b...)
+			} else {
+				out = append(out, b...)
+			}
+			break
+		}
+		if b[end] == 'm' {
+			out = append(out, cw.sgr(string(b[2:end]))...)
+		} else {
+			out = append(out, b[:end+1]...)
+		}
+		b = b[end+1:]
+	}
+	if _, err := cw.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// sgr converts the colors in the parameters of a SGR sequence.
+func (cw *colorWriter) sgr(params string) string {
+	if params == "" {
+		return "\x1b[m"
+	}
+	parts := strings.Split(params, ";")
+	kept := make([]string, 0, len(parts))
+	for i := 0; i < len(parts); i++ {
+		p := parts[i]
+		var c termenv.Color
+		switch {
+		case (p == "38" || p == "48") && i+4 < len(parts) && parts[i+1] == "2":
+			var rgb [3]int
+			for j := range rgb {
+				rgb[j], _ = strconv.Atoi(parts[i+2+j])
+			}
+			c = termenv.RGBColor(fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]))
+			i += 4
+		case (p == "38" || p == "48") && i+2 < len(parts) && parts[i+1] == "5":
+			n, _ := strconv.Atoi(parts[i+2])
+			c = termenv.ANSI256Color(n)
+			i += 2
+		default:
+			n, err := strconv.Atoi(p)
+			if err == nil && cw.profile == termenv.Ascii && (n >= 30 && n <= 49 || n >= 90 && n <= 107) {
+				// basic colors
+				continue
+			}
+			kept = append(kept, p)
+			continue
+		}
+		if c = cw.profile.Convert(c); c != nil {
+			if seq := c.Sequence(p == "48"); seq != "" {
+				kept = append(kept, seq)
+			}
+		}
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return "\x1b[" + strings.Join(kept, ";") + "m"
+}
+
+// asciiSymbols are plain replacements for the symbols on the screen.
+var asciiSymbols = strings.NewReplacer("↑", "^", "↓", "v", "←", "<", "→", ">", "•", "*", "…", "...", "–", "-", "─", "-", "│", "|", "●", "O", "█", "#", "★", "*",
+	"╭", "+", "╮", "+", "╰", "+", "╯", "+", "┌", "+", "┐", "+", "└", "+", "┘", "+",
+	"ä", "ae", "ö", "oe", "ü", "ue", "Ä", "Ae", "Ö", "Oe", "Ü", "Ue", "ß", "ss")
+
+// plainText removes all escape sequences from s and replaces characters
+// that are not ASCII, for terminals that show nothing else.
+func plainText(s string) string {
+	return stripStyles(asciiText(s))
+}
+
+// asciiText replaces the characters of s that are not ASCII, for terminals
+// that show styles but no Unicode.
+func asciiText(s string) string {
+	s = asciiSymbols.Replace(s)
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x80 {
+			b.WriteByte(c)
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size - 1
+		if r != utf8.RuneError || size > 1 {
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// stripStyles removes the escape sequences of styles from s.
+func stripStyles(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '[' {
+			// skip to the final byte of the sequence
+			for i += 2; i < len(s) && (s[i] < '@' || s[i] > '~'); i++ {
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// commandUsage lists what can be done over ssh. It is shown for `ssh host
+// help` and to sessions that ask for something that doesn't exist.
+var commandUsage = `usage:
+  ssh -t host                  play
+  ssh -t host vs [engine]      play against the computer, or the engine
+  ssh -t host vs <opponent>    play against rusty (easy), ada (medium) or zed (hard)
+  ssh -t host variant <name>   play a variant of the rules
+  ssh -t host campaign         play the next level of the campaign
+  ssh -t host join <id>        take the open seat in the game id
+  ssh -t host watch <id>       watch the game id
+  ssh host bot [first|second]  play from a program, see ssh host bot help
+  ssh host admin <command>     manage the server, for admins
+  ssh host delete-my-account   delete your settings and results
+  ssh host help                show this
+  ssh host <query>             answer one of the queries:` +
+	strings.ReplaceAll(strings.TrimPrefix(queryUsage, "usage:"), "\n", "\n  ")
+
+var errCommandUsage = errors.New(commandUsage)
+
+// gameCommands start the game, which needs a terminal.
+var gameCommands = map[string]bool{
+	"vs":       true,
+	"variant":  true,
+	"campaign": true,
+	"join":     true,
+	"watch":    true,
+}
+
+// commandMiddleware answers the sessions without a terminal that are left
+// after the bot, the queries and the admin commands had theirs, which would
+// otherwise end up at the game that can't run without one. It tells them
+// how to start the game, or what the commands are.
+func commandMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			cmd := s.Command()
+			if len(cmd) > 0 && cmd[0] == "help" {
+				fmt.Fprintln(s, commandUsage)
+				_ = s.Exit(0)
+				return
+			}
+			if _, _, active := s.Pty(); active {
+				sh(s)
+				return
+			}
+			wish.Fatalln(s, commandError(cmd))
+		}
+	}
+}
+
+// commandError explains why cmd can't run without a terminal.
+func commandError(cmd []string) error {
+	if len(cmd) == 0 {
+		// the bot plays sessions without a command
+		return errCommandUsage
+	}
+	if gameCommands[cmd[0]] {
+		return fmt.Errorf("%s needs a terminal, try ssh -t host %s", cmd[0], strings.Join(cmd, " "))
+	}
+	return fmt.Errorf("unknown command %q\n%s", cmd[0], commandUsage)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// config holds the server settings that can be set in the config file. The
+// address and host key are only read on startup, everything else is applied
+// to running sessions when the server receives SIGHUP.
+type config struct {
+	Host        string `json:"host"`
+	Port        int    `json:"port"`
+	HostKeyPath string `json:"host_key_path"`
+
+	// ProxyProtocol expects a PROXY protocol header on connections from
+	// TrustedProxies, for running behind a TCP proxy. The server doesn't
+	// start if it is set without any trusted proxies.
+	ProxyProtocol  bool     `json:"proxy_protocol"`
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// OTLPEndpoint is an OTLP/HTTP traces endpoint, e.g.
+	// http://localhost:4318/v1/traces. Tracing is disabled if empty.
+	OTLPEndpoint string `json:"otlp_endpoint"`
+
+	// Banner is shown to every player below the title.
+	Banner string `json:"banner"`
+	// Tagline is shown below the logo on the splash screen players see when
+	// they connect, unless NoSplash is set.
+	Tagline  string `json:"tagline"`
+	NoSplash bool   `json:"no_splash"`
+	// ReplayURL is the web page of the replay of a game with {id} in place
+	// of its id, e.g. https://nimm.example.com/replays/{id}. Players who
+	// turn on the replay QR code see a code of it after their games. If it
+	// is empty, it is the replay page of the web client on Host, if the web
+	// client is enabled.
+	ReplayURL string `json:"replay_url"`
+	// AdminKeys are public keys in authorized_keys format that may use the
+	// admin commands.
+	AdminKeys []string `json:"admin_keys"`
+
+	// Features turns features on or off by name, see knownFeatures.
+	Features map[string]bool `json:"features"`
+
+	// MaxSessions limits the number of concurrent sessions, 0 means no limit.
+	MaxSessions int `json:"max_sessions"`
+
+	// WebAddr is the address of the web client, which lets people play in
+	// the browser. It is disabled if empty.
+	WebAddr string `json:"web_addr"`
+	// TelnetAddr is the address of the telnet listener, e.g. :2323. It is
+	// disabled if empty. Telnet players are guests and unencrypted.
+	TelnetAddr string `json:"telnet_addr"`
+
+	// MaxOutputBuffer is how many bytes of output may be queued for a slow
+	// client before frames are dropped. MaxFPS limits how often a session's
+	// output is sent. Both apply to new sessions.
+	MaxOutputBuffer int `json:"max_output_buffer"`
+	MaxFPS          int `json:"max_fps"`
+
+	// HTTPAddr is the address of the HTTP server with the Prometheus
+	// metrics, the health check and the JSON API under /api/, e.g.
+	// localhost:9090. It is disabled if empty.
+	HTTPAddr string `json:"http_addr"`
+	// GRPCAddr is the address of the gRPC game service of proto/nimm.proto,
+	// e.g. localhost:9091. It is disabled if empty. It takes the APITokens
+	// and, unlike the HTTP API, only serves the games of this instance.
+	GRPCAddr string `json:"grpc_addr"`
+	// FlagBytesPerMinute and FlagRendersPerMinute flag sessions in the admin
+	// dashboard and the log once they exceed them, 0 disables the check.
+	FlagBytesPerMinute   int64 `json:"flag_bytes_per_minute"`
+	FlagRendersPerMinute int64 `json:"flag_renders_per_minute"`
+	// FlagMemoryBytes does the same for the estimated memory of a session,
+	// the output waiting for the client and the last frame.
+	FlagMemoryBytes int64 `json:"flag_memory_bytes"`
+	// GateConnectionsPerMinute is how many guests and players with unknown
+	// keys may connect per minute before the next ones have to press a key
+	// to play, see connectionGate. 0 means no limit.
+	GateConnectionsPerMinute int `json:"gate_connections_per_minute"`
+	// MaxInputPerSecond is how many keys and mouse events a session may send
+	// per second, more are dropped. 0 means no limit.
+	MaxInputPerSecond int `json:"max_input_per_second"`
+
+	// ChatWebhooks announce events in Discord or Slack channels.
+	ChatWebhooks []chatWebhook `json:"chat_webhooks"`
+	// Matrix announces events in a Matrix room.
+	Matrix *matrixConfig `json:"matrix"`
+	// IRC announces events in an IRC channel. The connection is only set up
+	// on startup.
+	IRC *ircConfig `json:"irc"`
+	// Stream publishes game events to an MQTT broker or NATS server. The
+	// connection is only set up on startup.
+	Stream *streamConfig `json:"stream"`
+
+	// Engines are external programs that can be played against, see
+	// engine.go for the protocol they speak.
+	Engines []engineConfig `json:"engines"`
+
+	// Webhooks are told about games starting and finishing and new players.
+	Webhooks []webhookConfig `json:"webhooks"`
+
+	// APITokens let programs create games and move over the HTTP API and
+	// the gRPC service.
+	APITokens []apiToken `json:"api_tokens"`
+
+	// LogAddresses is how client addresses are written to the logs, the
+	// traces and the recordings: full, truncate to their network or hash,
+	// for places where they count as personal data. Empty means full.
+	LogAddresses string `json:"log_addresses"`
+	// UpdateCheckURL is a release endpoint like the one of GitHub, e.g.
+	// https://api.github.com/repos/jheuel/nimm/releases/latest, that is
+	// asked daily whether there is a newer version. Empty means never.
+	UpdateCheckURL string `json:"update_check_url"`
+
+	// Cluster shares the games of the API with other instances of the
+	// server, see clusterConfig. It is only read on startup.
+	Cluster *clusterConfig `json:"cluster"`
+
+	// Terms are the rules of conduct of the server. New players have to
+	// accept them once before they play, and again when they change.
+	Terms string `json:"terms"`
+	// Recording records the game activity of every session in recordings,
+	// to look into reports of abuse. Nil records nothing.
+	Recording *recordingConfig `json:"recording"`
+
+	// Seasons are holidays and other events that change the theme, the
+	// glyph and the board while they last, see seasonConfig.
+	Seasons []seasonConfig `json:"seasons"`
+}
+
+func defaultConfig() config {
+	return config{
+		Host:        host,
+		Port:        port,
+		HostKeyPath: ".ssh/term_info_ed25519",
+		Tagline:     "a game of Nim over SSH",
+
+		MaxInputPerSecond: defaultMaxInput,
+	}
+}
+
+// replayURL is ReplayURL, or the replay page of the web client if it is
+// empty. Then it is empty if the web client is disabled.
+func (c config) replayURL() string {
+	if c.ReplayURL != "" || c.WebAddr == "" {
+		return c.ReplayURL
+	}
+	host, port, err := net.SplitHostPort(c.WebAddr)
+	if err != nil {
+		return ""
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = c.Host
+	}
+	return "http://" + net.JoinHostPort(host, port) + "/replays/{id}"
+}
+
+// configMsg tells a session that the configuration has changed.
+type configMsg config
+
+var (
+	configMu   sync.RWMutex
+	configPath string
+	cfg        = defaultConfig()
+)
+
+func currentConfig() config {
+	configMu.RLock()
+	defer configMu.RUnlock()
+	return cfg
+}
+
+// loadConfig reads the config file at path on top of the defaults. A missing
+// file is not an error.
+func loadConfig(path string) (config, error) {
+	c := defaultConfig()
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+	if err := validateSeasons(c); err != nil {
+		return c, err
+	}
+	if err := validateLogAddresses(c); err != nil {
+		return c, err
+	}
+	if err := validateCluster(c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// reloadConfig re-reads the config file and pushes the new settings to all
+// active sessions. Settings that need a restart keep their current value.
+func reloadConfig() {
+	c, err := loadConfig(configPath)
+	if err != nil {
+		log.Printf("Could not reload config %s: %v", configPath, err)
+		return
+	}
+	configMu.Lock()
+	c.Host, c.Port, c.HostKeyPath = cfg.Host, cfg.Port, cfg.HostKeyPath
+	c.ProxyProtocol, c.TrustedProxies = cfg.ProxyProtocol, cfg.TrustedProxies
+	c.OTLPEndpoint, c.HTTPAddr, c.GRPCAddr, c.WebAddr = cfg.OTLPEndpoint, cfg.HTTPAddr, cfg.GRPCAddr, cfg.WebAddr
+	c.TelnetAddr, c.IRC, c.Stream = cfg.TelnetAddr, cfg.IRC, cfg.Stream
+	// the games already have ids with the name of the instance
+	c.Cluster = cfg.Cluster
+	cfg = c
+	configMu.Unlock()
+	log.Printf("Reloaded config %s", configPath)
+	sessions.broadcast(configMsg(c))
+	// events that were added or removed apply right away
+	seasons.check()
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jheuel/nimm/variant"
+)
+
+func TestReloadConfig(t *testing.T) {
+	inTempDir(t)
+	withConfig(t, func(*config) {})
+	old := configPath
+	configPath = "config.json"
+	defer func() { configPath = old }()
+
+	g := games.create(variant.MustGet("normal"))
+	g.seat(1, "alice", tokenOwner("alice"))
+	g.challenge("")
+	m := newModel(g, "xterm", 80, 24)
+
+	theme := themeNames()[1]
+	b, err := json.Marshal(map[string]interface{}{
+		"banner":   "Back in a minute",
+		"features": map[string]bool{featureVariants: false},
+		"seasons": []seasonConfig{{
+			Name:  "Test",
+			Start: time.Now().Add(-time.Hour),
+			End:   time.Now().Add(time.Hour),
+			Theme: theme,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, b, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reloadConfig()
+	updated, _ := m.Update(configMsg(currentConfig()))
+	m = updated.(model)
+
+	if m.banner != "Back in a minute" {
+		t.Errorf("banner = %q after the reload", m.banner)
+	}
+	if m.season.Name != "Test" || m.theme.name != theme {
+		t.Errorf("event %q with theme %q after the reload, want Test with %s", m.season.Name, m.theme.name, theme)
+	}
+	if g.isOpen() {
+		t.Error("the game of a disabled variant is still open")
+	}
+	if _, err := g.claimSeat("bob", tokenOwner("bob")); !errors.Is(err, errVariantsDisabled) {
+		t.Errorf("claimSeat in a disabled variant = %v, want %v", err, errVariantsDisabled)
+	}
+}
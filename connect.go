@@ -0,0 +1,262 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+// connect plays on a nimm server like `ssh -t host` would, with the keys of
+// the user and host keys pinned on first use.
+func connect(args []string) {
+	fs := flag.NewFlagSet("connect", flag.ExitOnError)
+	name := fs.String("user", currentUser(), "the name to play as")
+	identity := fs.String("i", "", "private key to log in with, the ssh agent and ~/.ssh/id_* if empty")
+	pins := fs.String("known-hosts", defaultKnownHosts(), "file that pins the keys of servers")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: nimm connect [flags] [host[:port]] [command]")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	addr := fmt.Sprintf("%s:%d", host, port)
+	if fs.NArg() > 0 {
+		addr = fs.Arg(0)
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, strconv.Itoa(port))
+		}
+	}
+	var cmd string
+	if fs.NArg() > 1 {
+		cmd = strings.Join(fs.Args()[1:], " ")
+	}
+	status, err := runConnect(addr, *name, *identity, *pins, cmd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(status)
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+func defaultKnownHosts() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nimm", "known_hosts")
+}
+
+// runConnect runs cmd, or the game if it is empty, and returns its exit
+// status.
+func runConnect(addr, name, identity, pins, cmd string) (int, error) {
+	auth, err := connectAuth(identity)
+	if err != nil {
+		return 0, err
+	}
+	hostKey, err := pinHostKey(pins)
+	if err != nil {
+		return 0, err
+	}
+	client, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            name,
+		Auth:            auth,
+		HostKeyCallback: hostKey,
+		Timeout:         30 * time.Second,
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	sess, err := client.NewSession()
+	if err != nil {
+		return 0, err
+	}
+	defer sess.Close()
+	sess.Stdin, sess.Stdout, sess.Stderr = os.Stdin, os.Stdout, os.Stderr
+	for _, name := range []string{"COLORTERM", "NO_COLOR"} {
+		if v := os.Getenv(name); v != "" {
+			// servers may refuse, that is fine
+			_ = sess.Setenv(name, v)
+		}
+	}
+
+	// without a terminal, e.g. for bots, there is no pty
+	in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if term.IsTerminal(in) && term.IsTerminal(out) {
+		width, height, err := term.GetSize(out)
+		if err != nil {
+			width, height = 80, 24
+		}
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		modes := gossh.TerminalModes{gossh.ECHO: 1, gossh.TTY_OP_ISPEED: 14400, gossh.TTY_OP_OSPEED: 14400}
+		if err := sess.RequestPty(termType, height, width, modes); err != nil {
+			return 0, err
+		}
+		state, err := term.MakeRaw(in)
+		if err != nil {
+			return 0, err
+		}
+		defer func() { _ = term.Restore(in, state) }()
+		go watchSize(sess, out, width, height)
+	}
+
+	if cmd == "" {
+		err = sess.Shell()
+	} else {
+		err = sess.Start(cmd)
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = sess.Wait()
+	var exitErr *gossh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+	return 0, err
+}
+
+// watchSize tells the server when the terminal is resized. Polling works on
+// all systems, unlike SIGWINCH.
+func watchSize(sess *gossh.Session, fd, width, height int) {
+	for range time.Tick(250 * time.Millisecond) {
+		w, h, err := term.GetSize(fd)
+		if err != nil || w == width && h == height {
+			continue
+		}
+		width, height = w, h
+		if err := sess.WindowChange(height, width); err != nil {
+			return
+		}
+	}
+}
+
+// connectAuth logs in with identity, or the keys of the ssh agent and the
+// default key files. Without keys we log in as a guest.
+func connectAuth(identity string) ([]gossh.AuthMethod, error) {
+	guest := gossh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+		return make([]string, len(questions)), nil
+	})
+	if identity != "" {
+		signer, err := readKey(identity)
+		if err != nil {
+			return nil, err
+		}
+		return []gossh.AuthMethod{gossh.PublicKeys(signer)}, nil
+	}
+
+	var signers []gossh.Signer
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if s, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, s...)
+			}
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+			b, err := os.ReadFile(filepath.Join(home, ".ssh", name))
+			if err != nil {
+				continue
+			}
+			// keys with a passphrase need -i
+			if s, err := gossh.ParsePrivateKey(b); err == nil {
+				signers = append(signers, s)
+			}
+		}
+	}
+	if len(signers) == 0 {
+		return []gossh.AuthMethod{guest}, nil
+	}
+	return []gossh.AuthMethod{gossh.PublicKeys(signers...), guest}, nil
+}
+
+// readKey reads a private key, asking for its passphrase if it has one.
+func readKey(path string) (gossh.Signer, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := gossh.ParsePrivateKey(b)
+	var missing *gossh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", path)
+	pass, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	return gossh.ParsePrivateKeyWithPassphrase(b, pass)
+}
+
+// pinHostKey checks host keys against ~/.ssh/known_hosts and pins, a
+// known_hosts file of our own. Keys of unknown servers are added to pins.
+func pinHostKey(pins string) (gossh.HostKeyCallback, error) {
+	var files []string
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".ssh", "known_hosts"))
+	}
+	if pins != "" {
+		files = append(files, pins)
+	}
+	var existing []string
+	for _, f := range files {
+		if _, err := os.Stat(f); err == nil {
+			existing = append(existing, f)
+		}
+	}
+	known, err := knownhosts.New(existing...)
+	if err != nil {
+		return nil, err
+	}
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		err := known(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("the host key of %s has changed, which could mean that someone is intercepting the connection. If the server got a new key, remove its line from %s", hostname, keyErr.Want[0].Filename)
+		}
+		if pins == "" {
+			return fmt.Errorf("unknown host key for %s", hostname)
+		}
+		if err := os.MkdirAll(filepath.Dir(pins), 0o700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(pins, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Pinned the %s key of %s, %s\r\n", key.Type(), hostname, gossh.FingerprintSHA256(key))
+		return nil
+	}, nil
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/indent"
+)
+
+// dashboardRefresh is how often the admin dashboard reloads the sessions.
+const dashboardRefresh = time.Second
+
+type dashboardKeyMap struct {
+	Up   key.Binding
+	Down key.Binding
+	Kick key.Binding
+	Help key.Binding
+	Quit key.Binding
+}
+
+var dashboardKeys = dashboardKeyMap{
+	Up:   keys.Up,
+	Down: keys.Down,
+	Kick: key.NewBinding(
+		key.WithKeys("x"),
+		key.WithHelp("x", "kick session"),
+	),
+	Help: keys.Help,
+	Quit: keys.Quit,
+}
+
+// ShortHelp returns keybindings to be shown in the mini help view. It's part
+// of the key.Map interface.
+func (k dashboardKeyMap) ShortHelp() []key.Binding {
+	return []key.Binding{k.Kick, k.Help, k.Quit}
+}
+
+// FullHelp returns keybindings for the expanded help view. It's part of the
+// key.Map interface.
+func (k dashboardKeyMap) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{k.Up, k.Down, k.Kick},
+		{k.Help, k.Quit},
+	}
+}
+
+type dashboardTickMsg time.Time
+
+// dashboard is the admin screen listing all connected sessions.
+type dashboard struct {
+	width  int
+	height int
+	table  table.Model
+	ids    []string
+	help   help.Model
+	keys   dashboardKeyMap
+
+	// kicking is the session for which a kick message is being entered
+	kicking string
+	reason  textinput.Model
+}
+
+func newDashboard(width, height int) dashboard {
+	t := table.New(
+		table.WithColumns([]table.Column{
+			{Title: "ID", Width: 8},
+			{Title: "User", Width: 16},
+			{Title: "Address", Width: 22},
+			{Title: "Connected", Width: 10},
+			{Title: "Screen", Width: 10},
+			{Title: "Game", Width: 8},
+			{Title: "Sent", Width: 9},
+			{Title: "Renders", Width: 8},
+			{Title: "", Width: 1},
+		}),
+		table.WithFocused(true),
+	)
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.Bold(true).BorderStyle(lipgloss.NormalBorder()).BorderBottom(true)
+	styles.Selected = defaultTheme.accent.Copy().Bold(true)
+	t.SetStyles(styles)
+
+	reason := textinput.New()
+	reason.Placeholder = "optional message for the player"
+	reason.CharLimit = 200
+
+	d := dashboard{width: width, height: height, table: t, help: help.New(), keys: dashboardKeys, reason: reason}
+	d.resize()
+	d.refresh()
+	return d
+}
+
+func dashboardTick() tea.Cmd {
+	return tea.Tick(dashboardRefresh, func(t time.Time) tea.Msg {
+		return dashboardTickMsg(t)
+	})
+}
+
+func (d *dashboard) resize() {
+	d.help.Width = d.width
+	height := d.height - 8
+	if height < 1 {
+		height = 1
+	}
+	d.table.SetHeight(height)
+}
+
+// refresh reloads the table rows from the session list.
+func (d *dashboard) refresh() {
+	all := sessions.all()
+	rows := make([]table.Row, 0, len(all))
+	d.ids = d.ids[:0]
+	for _, sess := range all {
+		d.ids = append(d.ids, sess.id)
+		info := sess.info()
+		if info.gameID == "" {
+			info.gameID = "-"
+		}
+		st := sess.stats.snapshot()
+		flag := ""
+		if st.flagged {
+			flag = "!"
+		}
+		rows = append(rows, table.Row{
+			shortID(sess.id),
+			sess.user,
+			sess.addr.String(),
+			time.Since(sess.started).Round(time.Second).String(),
+			info.screen,
+			info.gameID,
+			formatBytes(st.bytesSent),
+			fmt.Sprint(st.renders),
+			flag,
+		})
+	}
+	d.table.SetRows(rows)
+}
+
+func (d dashboard) Init() tea.Cmd {
+	return dashboardTick()
+}
+
+func (d dashboard) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case dashboardTickMsg:
+		d.refresh()
+		return d, dashboardTick()
+	case tea.WindowSizeMsg:
+		d.width = msg.Width
+		d.height = msg.Height
+		d.resize()
+	case tea.KeyMsg:
+		if d.kicking != "" {
+			switch msg.Type {
+			case tea.KeyEnter:
+				if sess := sessions.get(d.kicking); sess != nil {
+					sess.kick(d.reason.Value())
+				}
+				d.kicking = ""
+				d.reason.Blur()
+				return d, nil
+			case tea.KeyEsc:
+				d.kicking = ""
+				d.reason.Blur()
+				return d, nil
+			}
+			var cmd tea.Cmd
+			d.reason, cmd = d.reason.Update(msg)
+			return d, cmd
+		}
+		switch {
+		case key.Matches(msg, d.keys.Kick):
+			if c := d.table.Cursor(); c >= 0 && c < len(d.ids) {
+				d.kicking = d.ids[c]
+				d.reason.Reset()
+				return d, d.reason.Focus()
+			}
+			return d, nil
+		case key.Matches(msg, d.keys.Quit):
+			return d, tea.Quit
+		case key.Matches(msg, d.keys.Help):
+			d.help.ShowAll = !d.help.ShowAll
+			return d, nil
+		}
+	}
+	var cmd tea.Cmd
+	d.table, cmd = d.table.Update(msg)
+	return d, cmd
+}
+
+func (d dashboard) View() string {
+	s := defaultTheme.title.Render("== Nimm admin ==") + "\n\n"
+	s += defaultTheme.dim.Render(fmt.Sprintf("%d sessions", sessions.len())) + "\n\n"
+	if r, ok := availableUpdate(); ok {
+		s += defaultTheme.warn.Render(fmt.Sprintf("nimm %s is available: %s", r.Tag, r.URL)) + "\n\n"
+	}
+	s += d.table.View() + "\n"
+	if d.kicking != "" {
+		s += "\n" + defaultTheme.warn.Render("Kick "+shortID(d.kicking)+"? ") + d.reason.View() + "\n"
+		s += defaultTheme.dim.Render("enter to kick, esc to cancel") + "\n"
+	}
+	helpView := d.help.View(d.keys)
+	height := d.height - 4 - strings.Count(s, "\n") - strings.Count(helpView, "\n")
+	if height < 0 {
+		height = 0
+	}
+	return indent.String("\n"+s+strings.Repeat("\n", height)+helpView, 2)
+}
+
+// formatBytes formats n with a binary unit prefix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// drainInterval is how often a draining server looks whether the last
+// session has ended.
+const drainInterval = time.Second
+
+var errDraining = errors.New("server is restarting, please try again in a minute")
+
+var (
+	drainOnce sync.Once
+	// drained is closed once the server is draining and the last session
+	// has ended, the server stops then
+	drained = make(chan struct{})
+)
+
+// drain refuses new sessions with msg, or errDraining if it is empty, and
+// stops the server once the sessions that are still connected have ended,
+// so that it can be restarted without ending any games. It reports false if
+// the server is already draining or shutting down.
+func drain(msg string) bool {
+	err := errDraining
+	if msg != "" {
+		err = errors.New(msg)
+	}
+	if !sessions.closeWith(err) {
+		return false
+	}
+	log.Printf("Draining, %d sessions are left", sessions.len())
+	go func() {
+		for range time.Tick(drainInterval) {
+			if sessions.len() == 0 {
+				drainOnce.Do(func() { close(drained) })
+				return
+			}
+		}
+	}()
+	return true
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// External engines are programs that talk a line based protocol on stdin
+// and stdout, similar to UCI for chess:
+//
+//	server: nimm 1                          the protocol version
+//	engine: nimmok                          ready; "info ..." lines may come first
+//	server: position 0001000/0011100/...    the board, row by row, 1 = object
+//	server: go
+//	engine: bestmove 3 0 2                  take columns 0 to 2 of row 3
+//	server: quit
+//
+// The player who has to take the last object loses. Lines the server doesn't
+// understand are ignored.
+const engineProtocolVersion = 1
+
+// engineTimeout is how long an external engine may take to start or to pick
+// a move before it is stopped.
+const engineTimeout = 10 * time.Second
+
+// computerEngine is the name of the built-in solver.
+const computerEngine = "computer"
+
+var errUnknownEngine = errors.New("unknown engine")
+
+// engine picks the moves of a computer opponent.
+type engine interface {
+	name() string
+	bestMove(field [][]bool) (move, error)
+	close()
+}
+
+// engineConfig is an external engine the server may launch.
+type engineConfig struct {
+	Name string `json:"name"`
+	// Command is the program and its arguments.
+	Command []string `json:"command"`
+}
+
+// newEngine starts the engine called name, which is the built-in solver if
+// name is empty, or one of the personalities.
+func newEngine(name string) (engine, error) {
+	if name == "" || name == computerEngine {
+		return solverEngine{}, nil
+	}
+	if p, ok := findPersonality(name); ok {
+		return newPersonalityEngine(p), nil
+	}
+	for _, ec := range currentConfig().Engines {
+		if ec.Name == name {
+			return startExternalEngine(ec)
+		}
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownEngine, name)
+}
+
+// thinkingDelays are the least and the most time computer opponents take
+// before they move, by the Thinking setting of the player.
+var thinkingDelays = map[string][2]time.Duration{
+	"instant": {0, 0},
+	"":        {300 * time.Millisecond, time.Second},
+	"normal":  {time.Second, 2 * time.Second},
+	"slow":    {2 * time.Second, 4 * time.Second},
+}
+
+// thinkingDelay is a random time within the range of the setting, so that
+// moves don't come at a steady beat.
+func thinkingDelay(setting string) time.Duration {
+	r, ok := thinkingDelays[setting]
+	if !ok {
+		r = thinkingDelays[""]
+	}
+	if r[1] <= r[0] {
+		return r[0]
+	}
+	return r[0] + time.Duration(time.Now().UnixNano()%int64(r[1]-r[0]))
+}
+
+// engineMove asks eng for a move, and falls back to the built-in solver if
+// the engine fails so that the game can go on. The solver also moves if
+// cancel is closed before the engine answers, whose answer is dropped then.
+func engineMove(eng engine, field [][]bool, cancel <-chan struct{}) (move, error) {
+	if cancel == nil {
+		mv, err := eng.bestMove(field)
+		if err == nil {
+			return mv, nil
+		}
+		log.Printf("Engine %s failed, using the solver: %v", eng.name(), err)
+		return (solverEngine{}).bestMove(field)
+	}
+	type answer struct {
+		mv  move
+		err error
+	}
+	done := make(chan answer, 1)
+	go func() {
+		mv, err := eng.bestMove(field)
+		done <- answer{mv, err}
+	}()
+	select {
+	case a := <-done:
+		if a.err == nil {
+			return a.mv, nil
+		}
+		log.Printf("Engine %s failed, using the solver: %v", eng.name(), a.err)
+	case <-cancel:
+	}
+	return (solverEngine{}).bestMove(field)
+}
+
+// playEngineMove lets eng make the next move in g. If the engine fails,
+// picks an invalid move or cancel is closed while it thinks, the solver
+// moves instead. cancel may be nil.
+func playEngineMove(g *game, eng engine, cancel <-chan struct{}) (gameState, error) {
+	state := g.state()
+	mv, err := engineMove(eng, state.field, cancel)
+	if err != nil {
+		return state, err
+	}
+	state, err = g.take(mv.row, mv.from, mv.to)
+	if errors.Is(err, errInvalidMove) || errors.Is(err, errLastObject) {
+		log.Printf("Engine %s made an invalid move in game %s, using the solver", eng.name(), state.id)
+		if mv, err = (solverEngine{}).bestMove(state.field); err != nil {
+			return state, err
+		}
+		state, err = g.take(mv.row, mv.from, mv.to)
+	}
+	return state, err
+}
+
+// solverEngine plays perfectly using solve.
+type solverEngine struct{}
+
+func (solverEngine) name() string { return computerEngine }
+
+func (solverEngine) bestMove(field [][]bool) (move, error) {
+	mv, _, ok := solve(field)
+	if !ok {
+		return move{}, errGameFinished
+	}
+	return mv, nil
+}
+
+func (solverEngine) close() {}
+
+// skilledMove is the best move with the probability skill, and a random one
+// otherwise.
+func skilledMove(r *rand.Rand, skill float64, field [][]bool) (move, error) {
+	moves := legalMoves(field)
+	if len(moves) == 0 {
+		return move{}, errGameFinished
+	}
+	if r.Float64() >= skill {
+		return moves[r.Intn(len(moves))], nil
+	}
+	return (solverEngine{}).bestMove(field)
+}
+
+// externalEngine is an engine process.
+type externalEngine struct {
+	conf engineConfig
+
+	mu    sync.Mutex
+	cmd   *exec.Cmd
+	in    io.WriteCloser
+	lines chan string
+}
+
+func startExternalEngine(ec engineConfig) (*externalEngine, error) {
+	if len(ec.Command) == 0 {
+		return nil, fmt.Errorf("engine %q has no command", ec.Name)
+	}
+	cmd := exec.Command(ec.Command[0], ec.Command[1:]...)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	e := &externalEngine{conf: ec, cmd: cmd, in: in, lines: make(chan string)}
+	go func() {
+		defer close(e.lines)
+		r := bufio.NewScanner(out)
+		for r.Scan() {
+			e.lines <- r.Text()
+		}
+		// reap the process once it closed its output
+		_ = cmd.Wait()
+	}()
+
+	if _, err := fmt.Fprintf(in, "nimm %d\n", engineProtocolVersion); err != nil {
+		e.close()
+		return nil, err
+	}
+	if _, err := e.expect("nimmok"); err != nil {
+		e.close()
+		return nil, fmt.Errorf("engine %q: %w", ec.Name, err)
+	}
+	return e, nil
+}
+
+func (e *externalEngine) name() string { return e.conf.Name }
+
+// expect waits for a line starting with word and returns its other fields.
+func (e *externalEngine) expect(word string) ([]string, error) {
+	timeout := time.After(engineTimeout)
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return nil, errors.New("engine exited")
+			}
+			fields := strings.Fields(line)
+			if len(fields) > 0 && fields[0] == word {
+				return fields[1:], nil
+			}
+		case <-timeout:
+			return nil, fmt.Errorf("no %s within %s", word, engineTimeout)
+		}
+	}
+}
+
+func (e *externalEngine) bestMove(field [][]bool) (move, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rows := make([]string, len(field))
+	for i, row := range field {
+		b := make([]byte, len(row))
+		for j, avail := range row {
+			b[j] = '0'
+			if avail {
+				b[j] = '1'
+			}
+		}
+		rows[i] = string(b)
+	}
+	if _, err := fmt.Fprintf(e.in, "position %s\ngo\n", strings.Join(rows, "/")); err != nil {
+		return move{}, err
+	}
+	args, err := e.expect("bestmove")
+	if err != nil {
+		// don't let a stuck engine answer the next position
+		e.kill()
+		return move{}, err
+	}
+	if len(args) != 3 {
+		return move{}, fmt.Errorf("invalid bestmove %q", strings.Join(args, " "))
+	}
+	var mv [3]int
+	for i, a := range args {
+		if mv[i], err = strconv.Atoi(a); err != nil {
+			return move{}, fmt.Errorf("invalid bestmove %q", strings.Join(args, " "))
+		}
+	}
+	return move{row: mv[0], from: mv[1], to: mv[2]}, nil
+}
+
+func (e *externalEngine) kill() {
+	if e.cmd.Process != nil {
+		_ = e.cmd.Process.Kill()
+	}
+}
+
+// close asks the engine to quit and stops it if it doesn't.
+func (e *externalEngine) close() {
+	_, _ = io.WriteString(e.in, "quit\n")
+	_ = e.in.Close()
+	go func() {
+		timeout := time.After(engineTimeout)
+		for {
+			select {
+			case _, ok := <-e.lines:
+				if !ok {
+					return
+				}
+			case <-timeout:
+				e.kill()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// apiClock is how long both players have thought about their moves so far.
+type apiClock struct {
+	// Player is the player whose clock is running.
+	Player   int      `json:"player"`
+	Thinking [2]int64 `json:"thinking_ms"`
+}
+
+// thinkingTime adds up the time each player spent on their turns of a game
+// started at start, including the current turn until now.
+func thinkingTime(state gameState, start, now time.Time) [2]time.Duration {
+	var t [2]time.Duration
+	turn := start
+	for _, mv := range state.history {
+		// restored games start when they were saved
+		if d := mv.At.Sub(turn); d > 0 {
+			t[mv.Player-1] += d
+		}
+		turn = mv.At
+	}
+	if d := now.Sub(turn); !state.finished() && d > 0 {
+		t[state.player-1] += d
+	}
+	return t
+}
+
+// serveGameEvents streams a game as server-sent events, so that viewers in
+// a browser can follow it without a WebSocket:
+//
+//	event: state  the game when the stream starts
+//	event: move   an apiMove after every move
+//	event: clock  an apiClock every second
+//	event: end    the finished game, after which the stream ends
+//
+// Streams of games that nobody plays for gameIdleTimeout end, so that they
+// can be collected. Finished and idle games are answered with 204 No
+// Content, which also keeps EventSource from reconnecting.
+func serveGameEvents(w http.ResponseWriter, r *http.Request, g *game) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+	moves, stop, err := g.watch()
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer stop()
+	state := g.state()
+	if state.finished() || g.idle(gameIdleTimeout) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keep proxies like nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	if r.Method == http.MethodHead {
+		return
+	}
+	if err := writeEvent(w, flusher, "state", newAPIGame(state)); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case s := <-moves:
+			if s.moves <= state.moves || len(s.history) == 0 {
+				continue
+			}
+			state = s
+			err = writeEvent(w, flusher, "move", apiMove{newAPIGame(s), s.history[len(s.history)-1]})
+			if err == nil && s.finished() {
+				_ = writeEvent(w, flusher, "end", newAPIGame(s))
+				return
+			}
+		case now := <-ticker.C:
+			if g.idle(gameIdleTimeout) {
+				return
+			}
+			t := thinkingTime(state, g.created, now)
+			err = writeEvent(w, flusher, "clock", apiClock{
+				Player:   state.player,
+				Thinking: [2]int64{t[0].Milliseconds(), t[1].Milliseconds()},
+			})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, f http.Flusher, event string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	f.Flush()
+	return nil
+}
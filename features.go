@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jheuel/nimm/variant"
+)
+
+// Feature flags can be set in the config file and toggled at runtime with
+// `admin feature`. Features are enabled unless configured otherwise.
+const (
+	featureGuests   = "guests"
+	featureVariants = "variants"
+)
+
+var knownFeatures = []string{featureGuests, featureVariants}
+
+func featureEnabled(name string) bool {
+	enabled, ok := currentConfig().Features[name]
+	return !ok || enabled
+}
+
+// variantEnabled reports whether games of v may be started or joined.
+func variantEnabled(v variant.Variant) bool {
+	return v.Name() == variant.Default || featureEnabled(featureVariants)
+}
+
+// setFeature toggles a feature until the config is reloaded.
+func setFeature(name string, enabled bool) error {
+	known := false
+	for _, f := range knownFeatures {
+		known = known || f == name
+	}
+	if !known {
+		return fmt.Errorf("unknown feature %q", name)
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	features := make(map[string]bool, len(cfg.Features)+1)
+	for k, v := range cfg.Features {
+		features[k] = v
+	}
+	features[name] = enabled
+	cfg.Features = features
+	return nil
+}
+
+func sortedFeatures() []string {
+	names := append([]string(nil), knownFeatures...)
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// framed reports whether the board is drawn in a frame with a title bar.
+func (m model) framed() bool {
+	return !m.prefs.NoFrame && !m.reader
+}
+
+// frameOffset is where the board is inside its frame: behind the border and
+// the padding on the left, and below the border, the title bar and the rule
+// under it on the top.
+func (m model) frameOffset() (x, y int) {
+	if !m.framed() {
+		return 0, 0
+	}
+	return 2, 3
+}
+
+// frameSize is how much wider and higher the frame makes the board.
+func (m model) frameSize() (width, height int) {
+	if !m.framed() {
+		return 0, 0
+	}
+	return 4, 4
+}
+
+// frame draws the border around board, with the variant, the game and the
+// clock in a title bar at the top.
+func (m model) frame(board string) string {
+	if !m.framed() {
+		return board
+	}
+	width := lipgloss.Width(board)
+	title, _ := m.frameTitle(width)
+	rule := m.theme.dim.Render(strings.Repeat("─", width))
+	return m.theme.frame.Render(lipgloss.JoinVertical(lipgloss.Left, title, rule, board))
+}
+
+// frameClock reports whether the clock is in the title bar of the frame,
+// where it takes the place of the one in the status bar.
+func (m model) frameClock() bool {
+	if !m.framed() || !m.clock {
+		return false
+	}
+	_, clock := m.frameTitle(lipgloss.Width(m.boardView()))
+	return clock
+}
+
+// frameTitle is the title bar of the frame, width wide: the variant and the
+// game on the left and the clock on the right. The game and then the clock
+// are left out if they don't fit, clock tells whether it is in.
+func (m model) frameTitle(width int) (title string, clock bool) {
+	left := []string{m.theme.title.Render(m.state.variant.Name())}
+	if m.state.id != "" {
+		left = append(left, m.theme.dim.Render("#"+m.state.id))
+	}
+	right := ""
+	if m.clock {
+		right = m.theme.text.Render(m.clockText())
+	}
+	for {
+		ls := strings.Join(left, " ")
+		gap := width - lipgloss.Width(ls) - lipgloss.Width(right)
+		switch {
+		case gap >= 2 || gap >= 0 && right == "":
+			return ls + strings.Repeat(" ", gap) + right, right != ""
+		case len(left) > 1:
+			left = left[:1]
+		case right != "":
+			right = ""
+		default:
+			return lipgloss.NewStyle().MaxWidth(width).Render(ls), false
+		}
+	}
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jheuel/nimm/variant"
+)
+
+const (
+	// gameIdleTimeout is how long a game without any attached session is
+	// kept before it is garbage collected.
+	gameIdleTimeout = 10 * time.Minute
+	// gameGCInterval is how often the registry looks for idle games.
+	gameGCInterval = time.Minute
+	// maxWatchers is how many API clients and spectators may watch a game
+	// at the same time.
+	maxWatchers = 32
+)
+
+var (
+	errNoGame          = errors.New("no such game")
+	errInvalidMove     = variant.ErrInvalidMove
+	errLastObject      = variant.ErrLastObject
+	errGameFinished    = errors.New("game is finished")
+	errNotYourTurn     = errors.New("it is not your turn")
+	errNotOpen         = errors.New("game is not open")
+	errNoOpponent      = errors.New("game is waiting for an opponent")
+	errTooManyWatchers = errors.New("too many are watching this game")
+	errStaleMove       = errors.New("the game has moved on")
+)
+
+// gameState is a snapshot of a game that can be rendered without locking.
+type gameState struct {
+	id      string
+	variant variant.Variant
+	field   [][]bool
+	rows    int
+	cols    int
+	player  int
+	moves   int
+	// players are the names of the players, empty if unknown
+	players [2]string
+	// owners are who may move for the players, see game.owners
+	owners [2]string
+	// start is the board the game started with, nil for games saved
+	// before it was kept
+	start   [][]bool
+	history []moveRecord
+	// spectators is how many are watching the game without playing
+	spectators int
+}
+
+// finished reports whether the player to move has lost, e.g. because only
+// the last object is left.
+func (s gameState) finished() bool {
+	return s.variant.Lost(s.field)
+}
+
+// winner returns the player who won a finished game.
+func (s gameState) winner() int {
+	if !s.finished() {
+		return 0
+	}
+	// the player to move has lost
+	return s.player%2 + 1
+}
+
+// gameUpdateMsg tells a session that the game it is attached to changed.
+type gameUpdateMsg gameState
+
+// cursorMsg tells a session where player moved their cursor.
+type cursorMsg struct{ player, row, col int }
+
+// spectatorsMsg tells a session how many are watching its game.
+type spectatorsMsg int
+
+// game is a game of Nim shared by all sessions attached to it.
+type game struct {
+	id      string
+	created time.Time
+
+	variant variant.Variant
+
+	mu      sync.Mutex
+	field   [][]bool
+	rows    int
+	cols    int
+	player  int
+	moves   int
+	updated time.Time
+	players [2]string
+	// owners are who may move for the seats, see sessionOwner and
+	// tokenOwner. Anyone can log in under any name, so the names in
+	// players are only shown.
+	owners [2]string
+	// start is the board the game started with, for replays. It is never
+	// changed.
+	start    [][]bool
+	history  []moveRecord
+	attached map[string]*tea.Program
+	// watchers are told about moves outside of sessions, e.g. by the API
+	watchers map[chan gameState]struct{}
+
+	// opponent is the engine playing the empty seat of a game created over
+	// the API. Without one the game is open until another player sits down.
+	opponent string
+	open     bool
+}
+
+// sessionOwner is who owns the seats of a player connected over SSH: the
+// fingerprint of their login key, or the session for guests, who can't come
+// back to their seats.
+func sessionOwner(key, sessionID string) string {
+	if key != "" {
+		return key
+	}
+	return "session:" + sessionID
+}
+
+// tokenOwner is who owns the seats of the API clients of player name.
+func tokenOwner(name string) string {
+	return "token:" + name
+}
+
+func (g *game) state() gameState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.stateLocked()
+}
+
+func (g *game) stateLocked() gameState {
+	field := make([][]bool, len(g.field))
+	for i, row := range g.field {
+		field[i] = append([]bool(nil), row...)
+	}
+	return gameState{
+		id:         g.id,
+		variant:    g.variant,
+		field:      field,
+		rows:       g.rows,
+		cols:       g.cols,
+		player:     g.player,
+		moves:      g.moves,
+		players:    g.players,
+		owners:     g.owners,
+		start:      g.start,
+		history:    append([]moveRecord(nil), g.history...),
+		spectators: len(g.watchers),
+	}
+}
+
+// seat records that name plays as player (1 or 2), moving as owner.
+func (g *game) seat(player int, name, owner string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.players[player-1] = name
+	g.owners[player-1] = owner
+}
+
+// rename shows name in the seats owned by owner that have a name, once the
+// player chose one, and tells the other sessions about it.
+func (g *game) rename(owner, name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	renamed := false
+	for i, o := range g.owners {
+		if o == owner && g.players[i] != "" && g.players[i] != name {
+			g.players[i] = name
+			renamed = true
+		}
+	}
+	if renamed {
+		g.notifyLocked(g.stateLocked())
+	}
+}
+
+// challenge sets up a game created over the API, where opponent plays the
+// empty seat. If opponent is empty, the seat is left for claimSeat.
+func (g *game) challenge(opponent string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.opponent = opponent
+	g.open = opponent == ""
+}
+
+func (g *game) opponentEngine() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.opponent
+}
+
+// isOpen reports whether another player can claim the empty seat, which
+// they can't once its variant was disabled.
+func (g *game) isOpen() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.open && variantEnabled(g.variant)
+}
+
+// claimSeat seats name in the empty seat of an open game, moving as owner.
+func (g *game) claimSeat(name, owner string) (gameState, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.open || g.owners[0] == owner || g.owners[1] == owner {
+		return g.stateLocked(), errNotOpen
+	}
+	if !variantEnabled(g.variant) {
+		return g.stateLocked(), errVariantsDisabled
+	}
+	seat := 0
+	if g.owners[0] != "" {
+		seat = 1
+	}
+	g.players[seat] = name
+	g.owners[seat] = owner
+	g.open = false
+	g.updated = time.Now()
+	return g.stateLocked(), nil
+}
+
+// unclaimSeat empties the seat owner took with claimSeat and opens the game
+// again, for players whose session couldn't start after all.
+func (g *game) unclaimSeat(owner string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for i, o := range g.owners {
+		if o == owner {
+			g.players[i], g.owners[i] = "", ""
+			g.open = true
+		}
+	}
+}
+
+// take removes the objects in columns from to to of row on behalf of the
+// player to move, and tells all attached sessions about the new state.
+func (g *game) take(row, from, to int) (gameState, error) {
+	g.mu.Lock()
+	state, err := g.takeLocked(row, from, to)
+	g.mu.Unlock()
+	return state, moved(state, err)
+}
+
+// takeFor is take for owner, if it owns the seat of the player to move.
+func (g *game) takeFor(owner string, row, from, to int) (gameState, error) {
+	g.mu.Lock()
+	state, err := g.takeForLocked(owner, row, from, to)
+	g.mu.Unlock()
+	return state, moved(state, err)
+}
+
+// takeAt is takeFor if the game is still at move moves, for clients that
+// moved on a state they fetched earlier.
+func (g *game) takeAt(owner string, moves, row, from, to int) (gameState, error) {
+	g.mu.Lock()
+	if g.moves != moves {
+		state, err := g.stateLocked(), fmt.Errorf("%w: it is at move %d, not %d", errStaleMove, g.moves, moves)
+		g.mu.Unlock()
+		return state, err
+	}
+	state, err := g.takeForLocked(owner, row, from, to)
+	g.mu.Unlock()
+	return state, moved(state, err)
+}
+
+func (g *game) takeForLocked(owner string, row, from, to int) (gameState, error) {
+	if g.open {
+		return g.stateLocked(), errNoOpponent
+	}
+	if owner == "" || g.owners[g.player-1] != owner {
+		return g.stateLocked(), errNotYourTurn
+	}
+	return g.takeLocked(row, from, to)
+}
+
+func (g *game) takeLocked(row, from, to int) (gameState, error) {
+	if g.variant.Lost(g.field) {
+		return g.stateLocked(), errGameFinished
+	}
+	mv := variant.Move{Row: row, From: from, To: to}
+	field, err := g.variant.Apply(g.field, mv)
+	if err != nil {
+		return g.stateLocked(), err
+	}
+
+	taken := variant.Taken(g.field, mv)
+	g.field = field
+	g.history = append(g.history, moveRecord{Player: g.player, Row: row, From: from, To: to, Taken: taken, At: time.Now()})
+	g.player %= 2
+	g.player++
+	g.moves++
+	g.updated = time.Now()
+
+	state := g.stateLocked()
+	g.notifyLocked(state)
+	return state, nil
+}
+
+// notifyLocked tells the attached sessions and the watchers about state.
+func (g *game) notifyLocked(state gameState) {
+	for _, p := range g.attached {
+		// don't block the caller, which might be one of these programs
+		go p.Send(gameUpdateMsg(state))
+	}
+	for ch := range g.watchers {
+		select {
+		case ch <- state:
+		default:
+			// the watcher catches up with the next state
+		}
+	}
+}
+
+// moved announces the move that led to state, unless err says that it
+// wasn't made. It is called once the game is unlocked, so that saving the
+// results and the webhooks don't hold up the next move.
+func moved(state gameState, err error) error {
+	if err != nil {
+		return err
+	}
+	publishEvent(streamGameMove, apiMove{newAPIGame(state), state.history[len(state.history)-1]})
+	if state.finished() {
+		players.record(state)
+		fireEvent(hookGameFinished, newAPIGame(state))
+		go archiveGame(state)
+	}
+	return nil
+}
+
+// anonymize replaces the name in the seats of g owned by key if it is
+// finished. Games in progress keep it, the seat is how the player moves.
+func (g *game) anonymize(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.variant.Lost(g.field) {
+		return
+	}
+	for i, owner := range g.owners {
+		if owner == key {
+			g.players[i] = deletedPlayer
+			g.owners[i] = ""
+		}
+	}
+}
+
+// moveCursor tells the sessions attached to g other than the one with
+// sessionID where the cursor of player is, so that they can show it.
+func (g *game) moveCursor(sessionID string, player, row, col int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for id, p := range g.attached {
+		if id != sessionID {
+			go p.Send(cursorMsg{player, row, col})
+		}
+	}
+}
+
+// watch returns a channel that receives the state after every move, and a
+// function to stop watching. Watchers keep the game from being collected,
+// so there may only be maxWatchers of them.
+func (g *game) watch() (<-chan gameState, func(), error) {
+	ch := make(chan gameState, 8)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.watchers) >= maxWatchers {
+		return nil, nil, errTooManyWatchers
+	}
+	if g.watchers == nil {
+		g.watchers = map[chan gameState]struct{}{}
+	}
+	g.watchers[ch] = struct{}{}
+	g.sendSpectatorsLocked()
+	return ch, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		delete(g.watchers, ch)
+		// give a game that is still played time before it is collected,
+		// without letting watchers keep an abandoned one alive
+		if !g.idleLocked(gameIdleTimeout) {
+			g.updated = time.Now()
+		}
+		g.sendSpectatorsLocked()
+	}, nil
+}
+
+// idle reports whether no session has been attached to g and nobody has
+// moved for d.
+func (g *game) idle(d time.Duration) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.idleLocked(d)
+}
+
+func (g *game) idleLocked(d time.Duration) bool {
+	return len(g.attached) == 0 && time.Since(g.updated) > d
+}
+
+// sendSpectatorsLocked tells the sessions attached to g how many are
+// watching it.
+func (g *game) sendSpectatorsLocked() {
+	for _, p := range g.attached {
+		go p.Send(spectatorsMsg(len(g.watchers)))
+	}
+}
+
+// gameRegistry holds all games on this server by id.
+type gameRegistry struct {
+	mu    sync.Mutex
+	games map[string]*game
+}
+
+var games = newGameRegistry()
+
+func newGameRegistry() *gameRegistry {
+	return &gameRegistry{games: map[string]*game{}}
+}
+
+// newGameID returns a short random id that is easy to type. In a cluster it
+// starts with the name of the instance, see gameInstance.
+func newGameID() string {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	id := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	if c := currentConfig().Cluster; c != nil {
+		id = c.Instance + "-" + id
+	}
+	return id
+}
+
+// create starts a new game of v.
+func (r *gameRegistry) create(v variant.Variant) *game {
+	now := time.Now()
+	field := v.Setup()
+	start := make([][]bool, len(field))
+	for i, row := range field {
+		start[i] = append([]bool(nil), row...)
+	}
+	g := &game{
+		created:  now,
+		variant:  v,
+		field:    field,
+		start:    start,
+		rows:     len(field),
+		cols:     len(field[0]),
+		player:   1,
+		updated:  now,
+		attached: map[string]*tea.Program{},
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for {
+		g.id = newGameID()
+		if _, ok := r.games[g.id]; !ok {
+			break
+		}
+	}
+	r.games[g.id] = g
+	return g
+}
+
+// remove drops the game id, e.g. one created for a session that couldn't
+// start.
+func (r *gameRegistry) remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.games, id)
+}
+
+// restore adds a previously saved game under its old id.
+func (r *gameRegistry) restore(sg savedGame) (*game, error) {
+	if sg.ID == "" || len(sg.Field) == 0 {
+		return nil, fmt.Errorf("invalid saved game %q", sg.ID)
+	}
+	if sg.Variant == "" {
+		// saved before there were variants
+		sg.Variant = variant.Default
+	}
+	v, err := variant.Get(sg.Variant)
+	if err != nil {
+		return nil, err
+	}
+	g := &game{
+		id:       sg.ID,
+		variant:  v,
+		created:  sg.SavedAt,
+		field:    sg.Field,
+		rows:     len(sg.Field),
+		cols:     len(sg.Field[0]),
+		player:   sg.Player,
+		moves:    sg.Moves,
+		players:  sg.Players,
+		owners:   sg.Owners,
+		start:    sg.Start,
+		history:  sg.History,
+		updated:  time.Now(),
+		attached: map[string]*tea.Program{},
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.games[g.id]; ok {
+		return nil, fmt.Errorf("game %q already exists", g.id)
+	}
+	r.games[g.id] = g
+	return g, nil
+}
+
+func (r *gameRegistry) get(id string) (*game, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	g, ok := r.games[id]
+	if !ok {
+		return nil, errNoGame
+	}
+	return g, nil
+}
+
+// join attaches the program of a session to a game, so that it is told
+// about every move. p may be nil if the session doesn't need updates.
+func (r *gameRegistry) join(id, sessionID string, p *tea.Program) (*game, error) {
+	g, err := r.get(id)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if p != nil {
+		g.attached[sessionID] = p
+	}
+	return g, nil
+}
+
+// leave detaches a session from a game. Games nobody is attached to anymore
+// are collected after gameIdleTimeout.
+func (r *gameRegistry) leave(id, sessionID string) {
+	g, err := r.get(id)
+	if err != nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.attached, sessionID)
+	g.updated = time.Now()
+}
+
+// all returns a snapshot of all games.
+func (r *gameRegistry) all() []*game {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := make([]*game, 0, len(r.games))
+	for _, g := range r.games {
+		all = append(all, g)
+	}
+	return all
+}
+
+// gc removes games that nobody has been attached to for longer than idle.
+func (r *gameRegistry) gc(idle time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, g := range r.games {
+		g.mu.Lock()
+		remove := len(g.watchers) == 0 && g.idleLocked(idle)
+		g.mu.Unlock()
+		if remove {
+			delete(r.games, id)
+		}
+	}
+}
+
+// collectGames periodically removes idle games.
+func (r *gameRegistry) collectGames() {
+	for range time.Tick(gameGCInterval) {
+		before := len(r.all())
+		r.gc(gameIdleTimeout)
+		if removed := before - len(r.all()); removed > 0 {
+			log.Printf("Removed %d idle games", removed)
+		}
+	}
+}
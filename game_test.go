@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jheuel/nimm/variant"
+)
+
+func TestTakeAt(t *testing.T) {
+	g := games.create(variant.MustGet(variant.Default))
+	g.seat(1, "alice", "a")
+	g.seat(2, "bob", "b")
+	row := len(g.state().field) - 1
+	if _, err := g.takeAt("a", 0, row, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	// bob moves on the state before alice's move
+	state, err := g.takeAt("b", 0, row, 1, 1)
+	if !errors.Is(err, errStaleMove) {
+		t.Fatalf("takeAt on an old state = %v, want %v", err, errStaleMove)
+	}
+	if state.moves != 1 {
+		t.Errorf("the stale move was made, the game is at move %d", state.moves)
+	}
+	if _, err := g.takeAt("b", 1, row, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// playOut plays g to the end with the first legal move of every state and
+// waits for it to be archived.
+func playOut(t *testing.T, g *game) gameState {
+	t.Helper()
+	state := g.state()
+	for !state.finished() {
+		mv := g.variant.LegalMoves(state.field)[0]
+		var err error
+		if state, err = g.takeFor(state.owners[state.player-1], mv.Row, mv.From, mv.To); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; ; i++ {
+		if _, err := archivedGame(g.id); err == nil {
+			return state
+		} else if i == 100 {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRecordByKey(t *testing.T) {
+	inTempDir(t)
+	g := games.create(variant.MustGet(variant.Default))
+	g.seat(1, "alice", keyPrefix+"alice")
+	g.seat(2, "bob", sessionOwner("", "1"))
+	playOut(t, g)
+	want, err := players.get(keyPrefix + "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := players.named("bob"); !errors.Is(err, errNoPlayer) {
+		t.Errorf("results of a guest = %v, want %v", err, errNoPlayer)
+	}
+
+	// someone else logging in as alice
+	g = games.create(variant.MustGet(variant.Default))
+	g.seat(1, "alice", keyPrefix+"mallory")
+	g.seat(2, "bob", sessionOwner("", "2"))
+	playOut(t, g)
+	if got, _ := players.get(keyPrefix + "alice"); got.Wins != want.Wins || got.Losses != want.Losses {
+		t.Errorf("results of alice = %d-%d, changed by another key to %d-%d", want.Wins, want.Losses, got.Wins, got.Losses)
+	}
+	if got, _ := players.get(keyPrefix + "mallory"); got.Wins+got.Losses != 1 {
+		t.Errorf("results of the other key = %d-%d, want one game", got.Wins, got.Losses)
+	}
+}
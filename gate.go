@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+const (
+	// gateWindow is the interval over which connections from unknown keys
+	// are counted.
+	gateWindow = time.Minute
+	// gateTimeout is how long players have to press the key.
+	gateTimeout = 5 * time.Second
+	// gateKeys are the keys players may be asked to press, without the ones
+	// that are easy to mix up.
+	gateKeys = "abcdefghjkmnpqrstuvwxyz"
+	// gateMemory is how long a key that passed the gate is let through, and
+	// gateMaxKnown how many keys are remembered at most.
+	gateMemory   = 24 * time.Hour
+	gateMaxKnown = 10000
+)
+
+// connectionGate makes players with unknown keys and guests press a random
+// key before they can play while more of them connect than the configured
+// rate, to keep out bots that open sessions without looking at them. It
+// covers all the ways in: SSH, the web terminal and telnet.
+type connectionGate struct {
+	mu sync.Mutex
+	// recent are the times of the last connections from unknown keys
+	recent []time.Time
+	// known are the fingerprints of keys that played before, with when
+	// they last passed, see gateMemory
+	known map[string]time.Time
+}
+
+var gate = &connectionGate{known: map[string]time.Time{}}
+
+// gateClient is a connection that may have to pass the gate.
+type gateClient struct {
+	ctx context.Context
+	w   io.Writer
+	// read returns the next key the player pressed
+	read func() (byte, error)
+	lang language
+	// id and addr identify the session in the log
+	id   string
+	addr net.Addr
+}
+
+// keyReader returns a function that reads the keys pressed from r.
+func keyReader(r io.Reader) func() (byte, error) {
+	return func() (byte, error) {
+		b := make([]byte, 1)
+		_, err := io.ReadFull(r, b)
+		return b[0], err
+	}
+}
+
+// gateMiddleware lets sessions through to the game only if they pass the
+// gate.
+func gateMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			if !gate.admit(s) {
+				return
+			}
+			sh(s)
+		}
+	}
+}
+
+// admit reports whether the SSH session may play, asking the player to
+// press a key first if the gate is closed.
+func (g *connectionGate) admit(s ssh.Session) bool {
+	fingerprint := sessionKey(s)
+	if isAdmin(fingerprint) {
+		return true
+	}
+	c := gateClient{
+		ctx:  s.Context(),
+		w:    s,
+		read: keyReader(s),
+		lang: languageFromEnv(s.Environ()),
+		id:   s.Context().SessionID(),
+		addr: s.RemoteAddr(),
+	}
+	if !g.pass(c, fingerprint) {
+		_ = s.Exit(1)
+		return false
+	}
+	return true
+}
+
+// pass reports whether the player of c with the key fingerprint, empty for
+// guests, may play, asking them to press a key first if the gate is closed.
+// It tells them why not.
+func (g *connectionGate) pass(c gateClient, fingerprint string) bool {
+	if !g.needed(fingerprint, time.Now(), currentConfig().GateConnectionsPerMinute) {
+		g.remember(fingerprint, time.Now())
+		return true
+	}
+	want := randomGateKey()
+	fmt.Fprintf(c.w, "\r\n  %s\r\n", c.lang.trf("Many players are connecting right now. Press %c within %d seconds to play.", want, int(gateTimeout.Seconds())))
+	pressed := make(chan byte, 1)
+	go func() {
+		b, err := c.read()
+		if err != nil {
+			// they hung up
+			close(pressed)
+			return
+		}
+		pressed <- b
+	}()
+	msg := "That was not the key, please try again."
+	select {
+	case b, ok := <-pressed:
+		if ok && b == want {
+			g.remember(fingerprint, time.Now())
+			return true
+		}
+	case <-time.After(gateTimeout):
+		msg = "Time is up, please try again."
+	case <-c.ctx.Done():
+		return false
+	}
+	log.Printf("Session %s (%s) did not pass the gate", shortID(c.id), logAddr(c.addr))
+	fmt.Fprintf(c.w, "  %s\r\n", c.lang.tr(msg))
+	return false
+}
+
+// needed reports whether a connection at now with the key fingerprint, empty
+// for guests, has to pass the gate if max connections from unknown keys may
+// come per minute. It counts the connection if the key is unknown. A max of
+// 0 leaves the gate open.
+func (g *connectionGate) needed(fingerprint string, now time.Time, max int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if max <= 0 {
+		return false
+	}
+	if passed, ok := g.known[fingerprint]; ok && fingerprint != "" && now.Sub(passed) < gateMemory {
+		return false
+	}
+	i := 0
+	for i < len(g.recent) && now.Sub(g.recent[i]) >= gateWindow {
+		i++
+	}
+	g.recent = append(g.recent[i:], now)
+	return len(g.recent) > max
+}
+
+// remember lets the key with fingerprint, which passed at now, through for
+// gateMemory. Once gateMaxKnown keys are remembered, the ones that passed
+// the longest ago are forgotten first.
+func (g *connectionGate) remember(fingerprint string, now time.Time) {
+	if fingerprint == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.known[fingerprint]; !ok && len(g.known) >= gateMaxKnown {
+		oldest := ""
+		for k, passed := range g.known {
+			if now.Sub(passed) >= gateMemory {
+				delete(g.known, k)
+			} else if oldest == "" || passed.Before(g.known[oldest]) {
+				oldest = k
+			}
+		}
+		if len(g.known) >= gateMaxKnown {
+			delete(g.known, oldest)
+		}
+	}
+	g.known[fingerprint] = now
+}
+
+// randomGateKey picks the key players have to press, so that a script can't
+// know it in advance.
+func randomGateKey() byte {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(gateKeys))))
+	if err != nil {
+		return gateKeys[0]
+	}
+	return gateKeys[n.Int64()]
+}
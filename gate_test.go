@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGateRemembersKeys(t *testing.T) {
+	g := &connectionGate{known: map[string]time.Time{}}
+	now := time.Now()
+	if g.needed("SHA256:a", now, 0) {
+		t.Fatal("the gate is closed without a rate")
+	}
+	g.remember("SHA256:a", now)
+	if g.needed("SHA256:a", now, 1) || g.needed("SHA256:a", now.Add(gateMemory/2), 1) {
+		t.Error("a known key has to pass the gate")
+	}
+	g.needed("", now.Add(gateMemory), 1)
+	if !g.needed("SHA256:a", now.Add(gateMemory), 1) {
+		t.Error("a key is let through after gateMemory")
+	}
+
+	for i := 0; i < gateMaxKnown+10; i++ {
+		g.remember(fmt.Sprintf("SHA256:%d", i), now.Add(time.Duration(i)*time.Millisecond))
+	}
+	if len(g.known) != gateMaxKnown {
+		t.Errorf("%d keys remembered, want %d", len(g.known), gateMaxKnown)
+	}
+	if _, ok := g.known["SHA256:0"]; ok {
+		t.Error("the key that passed the longest ago wasn't forgotten")
+	}
+	if _, ok := g.known[fmt.Sprintf("SHA256:%d", gateMaxKnown+9)]; !ok {
+		t.Error("the last key wasn't remembered")
+	}
+}
+
+func TestGatePass(t *testing.T) {
+	withConfig(t, func(c *config) { c.GateConnectionsPerMinute = 1 })
+
+	tests := []struct {
+		name string
+		// keys are what the player presses, with the key asked for as %c
+		keys string
+		want bool
+	}{
+		{"right key", "%c", true},
+		{"wrong key", "1", false},
+		{"hung up", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &connectionGate{known: map[string]time.Time{}}
+			// the first guest fills the rate
+			g.needed("", time.Now(), 1)
+			r, w := io.Pipe()
+			var out bytes.Buffer
+			// the key asked for is only known once it is written
+			screen := writerFunc(func(p []byte) (int, error) {
+				out.Write(p)
+				go func() {
+					if i := strings.Index(string(p), "Press "); i >= 0 && tt.keys != "" {
+						fmt.Fprintf(w, tt.keys, p[i+len("Press ")])
+					}
+					if tt.keys == "" {
+						w.Close()
+					}
+				}()
+				return len(p), nil
+			})
+			client := gateClient{ctx: context.Background(), w: screen, read: keyReader(r), lang: english}
+			if got := g.pass(client, ""); got != tt.want {
+				t.Errorf("pass = %v, want %v, the player saw %q", got, tt.want, out.String())
+			}
+		})
+	}
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// withConfig changes the configuration with f for the test.
+func withConfig(t *testing.T, f func(*config)) {
+	t.Helper()
+	configMu.Lock()
+	old := cfg
+	f(&cfg)
+	configMu.Unlock()
+	t.Cleanup(func() {
+		configMu.Lock()
+		cfg = old
+		configMu.Unlock()
+	})
+}
@@ -0,0 +1,184 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative proto/nimm.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	nimmpb "github.com/jheuel/nimm/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcService serves the game API of proto/nimm.proto for clients other
+// than the terminal UI. It plays by the rules of the HTTP API, with the same
+// tokens, but only knows the games of this instance.
+type grpcService struct {
+	nimmpb.UnimplementedNimmServer
+}
+
+// serveGRPC serves the game API on addr.
+func serveGRPC(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s := grpc.NewServer()
+	nimmpb.RegisterNimmServer(s, grpcService{})
+	return s.Serve(l)
+}
+
+// grpcPlayer returns the player whose API token is in the metadata of ctx.
+func grpcPlayer(ctx context.Context) (string, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if name, ok := tokenPlayer(strings.TrimPrefix(v, "Bearer ")); ok {
+			return name, nil
+		}
+	}
+	return "", status.Error(codes.Unauthenticated, errUnauthorized.Error())
+}
+
+func grpcGame(id string) (*game, error) {
+	g, err := games.get(id)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return g, nil
+}
+
+// grpcError is err of a game with the code that fits it.
+func grpcError(err error) error {
+	code := codes.Internal
+	switch {
+	case errors.Is(err, errInvalidMove), errors.Is(err, errLastObject):
+		code = codes.InvalidArgument
+	case errors.Is(err, errNotYourTurn), errors.Is(err, errNotOpen), errors.Is(err, errVariantsDisabled), errors.Is(err, errNoOpponent), errors.Is(err, errGameFinished), errors.Is(err, errStaleMove):
+		code = codes.FailedPrecondition
+	case errors.Is(err, errTooManyWatchers):
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, err.Error())
+}
+
+func newGRPCGame(state gameState) *nimmpb.GameState {
+	field := make([]*nimmpb.Row, len(state.field))
+	for i, row := range state.field {
+		field[i] = &nimmpb.Row{Objects: row}
+	}
+	return &nimmpb.GameState{
+		Game:     state.id,
+		Field:    field,
+		Player:   int32(state.player),
+		Moves:    int32(state.moves),
+		Finished: state.finished(),
+		Winner:   int32(state.winner()),
+		Players:  state.players[:],
+	}
+}
+
+func (grpcService) CreateGame(ctx context.Context, req *nimmpb.CreateGameRequest) (*nimmpb.GameState, error) {
+	name, err := grpcPlayer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ng := apiNewGame{Opponent: req.Opponent, Seat: int(req.Seat), Variant: req.Variant}
+	v, err := checkAPINewGame(&ng)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	_, state, err := startAPIGame(name, v, ng)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newGRPCGame(state), nil
+}
+
+func (grpcService) JoinGame(ctx context.Context, req *nimmpb.GetGameRequest) (*nimmpb.GameState, error) {
+	name, err := grpcPlayer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g, err := grpcGame(req.Game)
+	if err != nil {
+		return nil, err
+	}
+	state, err := g.claimSeat(name, tokenOwner(name))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	fireEvent(hookGameStarted, newAPIGame(state))
+	return newGRPCGame(state), nil
+}
+
+func (grpcService) GetGame(_ context.Context, req *nimmpb.GetGameRequest) (*nimmpb.GameState, error) {
+	g, err := grpcGame(req.Game)
+	if err != nil {
+		return nil, err
+	}
+	return newGRPCGame(g.state()), nil
+}
+
+func (grpcService) TakeObjects(ctx context.Context, req *nimmpb.TakeObjectsRequest) (*nimmpb.GameState, error) {
+	name, err := grpcPlayer(ctx)
+	if err != nil {
+		return nil, err
+	}
+	g, err := grpcGame(req.Game)
+	if err != nil {
+		return nil, err
+	}
+	state, err := g.takeAt(tokenOwner(name), int(req.Moves), int(req.Row), int(req.From), int(req.To))
+	if err == nil {
+		state, err = apiEngineMove(g, state)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return newGRPCGame(state), nil
+}
+
+// WatchGame sends the game and then its state after every move. Like the
+// event stream of the HTTP API, it ends once the game is finished or idle.
+func (grpcService) WatchGame(req *nimmpb.GetGameRequest, stream nimmpb.Nimm_WatchGameServer) error {
+	g, err := grpcGame(req.Game)
+	if err != nil {
+		return err
+	}
+	moves, stop, err := g.watch()
+	if err != nil {
+		return grpcError(err)
+	}
+	defer stop()
+	state := g.state()
+	if err := stream.Send(newGRPCGame(state)); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for !state.finished() {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case s := <-moves:
+			if s.moves <= state.moves {
+				continue
+			}
+			state = s
+			if err := stream.Send(newGRPCGame(s)); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if g.idle(gameIdleTimeout) {
+				return nil
+			}
+		}
+	}
+	return nil
+}
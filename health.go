@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// healthHandler reports whether the server accepts new sessions.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	if sessions.isClosed() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// health checks the server configured in the config file and exits with a
+// non-zero status if it is not healthy. It asks the HTTP health check if
+// there is one, and otherwise checks that the SSH server greets us.
+func health(args []string) {
+	fs := flag.NewFlagSet("health", flag.ExitOnError)
+	path := fs.String("config", "nimm.json", "path to the config file")
+	timeout := fs.Duration("timeout", 5*time.Second, "how long to wait for the server")
+	_ = fs.Parse(args)
+
+	c, err := loadConfig(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if c.HTTPAddr != "" {
+		err = checkHTTP(c.HTTPAddr, *timeout)
+	} else {
+		err = checkSSH(fmt.Sprintf("%s:%d", c.Host, c.Port), *timeout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "unhealthy:", err)
+		os.Exit(1)
+	}
+	fmt.Println("ok")
+}
+
+func checkHTTP(addr string, timeout time.Duration) error {
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get("http://" + addr + "/healthz")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned %s", resp.Status)
+	}
+	return nil
+}
+
+func checkSSH(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	version, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(version, "SSH-2.0-") {
+		return fmt.Errorf("unexpected greeting %q", strings.TrimSpace(version))
+	}
+	return nil
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// language is a language players can be talked to in, e.g. "de". The text
+// in the code is English and translated by looking it up in translations.
+type language string
+
+const english language = "en"
+
+// languages can be chosen in the settings, English first.
+var languages = []language{english, "de"}
+
+// translations map the English text shown to players to other languages.
+// Text that is missing stays English.
+var translations = map[language]map[string]string{
+	"de": {
+		// the game
+		"Player %d":              "Spieler %d",
+		"Player %d lost":         "Spieler %d hat verloren",
+		"Player %d's turn":       "Spieler %d ist am Zug",
+		"You":                    "Du",
+		"Opponent":               "Gegner",
+		"You lost":               "Du hast verloren",
+		"You won!":               "Du hast gewonnen!",
+		"Your turn":              "Du bist am Zug",
+		"Opponent's turn":        "Gegner ist am Zug",
+		"move %d":                "Zug %d",
+		"%d moves":               "%d Züge",
+		"taking %d from row %d":  "nimmt %d aus Reihe %d",
+		"taking %s":              "nimmt %s",
+		"vs %s":                  "gegen %s",
+		"nim-sum %d":             "Nim-Summe %d",
+		"nim-sum %d → %d":        "Nim-Summe %d → %d",
+		"Moves":                  "Züge",
+		"%d from row %d":         "%d aus Reihe %d",
+		"%s joined the game":     "%s ist dem Spiel beigetreten",
+		"%s stops waiting":       "%s beendet das Warten",
+		"%d watching":            "%d schauen zu",
+		"game %s":                "Spiel %s",
+		"Day %d of your streak!": "Tag %d deiner Serie!",
+		"Finish a game today for day %d of your streak!": "Beende heute ein Spiel für Tag %d deiner Serie!",
+		"level %d: %s": "Level %d: %s",
+		"Three rows":   "Drei Reihen",
+		"The pyramid":  "Die Pyramide",
+		"Going second": "Als Zweiter",
+		"Gaps":         "Lücken",
+		"The tower":    "Der Turm",
+
+		// the personalities
+		"easy":                              "leicht",
+		"medium":                            "mittel",
+		"hard":                              "schwer",
+		"Beep boop. Let's play!":            "Piep piep. Lass uns spielen!",
+		"Bold choice!":                      "Mutige Wahl!",
+		"Hmm, interesting...":               "Hm, interessant...",
+		"Did you mean to do that?":          "Wolltest du das wirklich?",
+		"I think that was good?":            "Ich glaube, das war gut?",
+		"Oops, wrong row.":                  "Ups, falsche Reihe.",
+		"I won? I won!":                     "Ich habe gewonnen? Ich habe gewonnen!",
+		"Well played, human.":               "Gut gespielt, Mensch.",
+		"Hello! May the better player win.": "Hallo! Möge der Bessere gewinnen.",
+		"Nice one.":                         "Schöner Zug.",
+		"I see what you're doing.":          "Ich sehe, was du vorhast.",
+		"Your move.":                        "Du bist dran.",
+		"Let's see how you handle this.":    "Mal sehen, was du daraus machst.",
+		"Good game!":                        "Gutes Spiel!",
+		"You got me this time.":             "Diesmal hast du mich erwischt.",
+		"I have never lost a game.":         "Ich habe noch nie ein Spiel verloren.",
+		"Predictable.":                      "Vorhersehbar.",
+		"As expected.":                      "Wie erwartet.",
+		"Every position has an answer.":     "Jede Stellung hat eine Antwort.",
+		"Your options are shrinking.":       "Deine Möglichkeiten werden weniger.",
+		"As calculated.":                    "Wie berechnet.",
+		"Impossible. Recalculating...":      "Unmöglich. Berechne neu...",
+
+		"You beat level %d! Connect again for the next one.": "Du hast Level %d geschafft! Verbinde dich erneut für das nächste.",
+		"You completed the campaign!":                        "Du hast die Kampagne abgeschlossen!",
+		"Really quit? You will give up this game.":           "Wirklich beenden? Du gibst dieses Spiel auf.",
+		"y or q quits • any other key goes back":             "y oder q beendet • jede andere Taste kehrt zurück",
+		"[ take ]":      "[ nehmen ]",
+		"press any key": "drücke eine beliebige Taste",
+		"Server shutting down in %ds, your game will be saved":         "Der Server wird in %d s beendet, dein Spiel wird gespeichert",
+		"Message from the operator: ":                                  "Nachricht vom Betreiber: ",
+		"Please enlarge your terminal to at least %dx%d, it is %dx%d.": "Bitte vergrößere dein Terminal auf mindestens %dx%d, es ist %dx%d.",
+
+		// the keys
+		"move up":                 "nach oben",
+		"move down":               "nach unten",
+		"move left":               "nach links",
+		"move right":              "nach rechts",
+		"select":                  "auswählen",
+		"submit":                  "nehmen",
+		"first column":            "erste Spalte",
+		"last column":             "letzte Spalte",
+		"first row":               "erste Reihe",
+		"last row":                "letzte Reihe",
+		"next row":                "nächste Reihe",
+		"rules":                   "Regeln",
+		"settings":                "Einstellungen",
+		"help":                    "Hilfe",
+		"any key closes the help": "jede Taste schließt die Hilfe",
+		"Replay":                  "Wiedergabe",
+		"any key closes the code": "jede Taste schließt den Code",
+		"quit":                    "beenden",
+		"stop waiting":            "nicht mehr warten",
+		"session":                 "Sitzung",
+
+		// the summary of the session
+		"Your session":                        "Deine Sitzung",
+		"Games played":                        "Gespielte Partien",
+		"Won / lost":                          "Gewonnen / verloren",
+		"Time per move":                       "Zeit pro Zug",
+		"Connected":                           "Verbunden",
+		"any key closes the summary":          "jede Taste schließt die Übersicht",
+		"Thanks for playing! Any key leaves.": "Danke fürs Spielen! Jede Taste beendet.",
+
+		// the board for screen readers
+		"Row %d: no objects":                 "Reihe %d: keine Objekte",
+		"Row %d: 1 object at position %s":    "Reihe %d: 1 Objekt an Position %s",
+		"Row %d: %d objects at positions %s": "Reihe %d: %d Objekte an den Positionen %s",
+		"%d to %d":                           "%d bis %d",
+		"Cursor at row %d, position %d.":     "Cursor in Reihe %d, Position %d.",
+		"Selected: row %d, positions %s.":    "Ausgewählt: Reihe %d, Positionen %s.",
+		"Nothing selected.":                  "Nichts ausgewählt.",
+		"No moves yet.":                      "Noch keine Züge.",
+		"1 object":                           "1 Objekt",
+		"%d objects":                         "%d Objekte",
+		"Player %d took %s from row %d.":     "Spieler %d hat %s aus Reihe %d genommen.",
+		"You took %s from row %d.":           "Du hast %s aus Reihe %d genommen.",
+		"The opponent took %s from row %d.":  "Der Gegner hat %s aus Reihe %d genommen.",
+
+		// the rules
+		"Nim is a mathematical game of strategy in which two players take turns removing (or \"nimming\") objects from distinct heaps or piles. On each turn, a player must remove at least one object, and may remove any number of objects provided they all come from the same heap or pile.": "Nim ist ein mathematisches Strategiespiel, in dem zwei Spieler abwechselnd Objekte von verschiedenen Haufen nehmen. In jedem Zug muss ein Spieler mindestens ein Objekt nehmen und darf beliebig viele Objekte nehmen, solange sie alle vom selben Haufen stammen.",
+		"This game: %s": "Dieses Spiel: %s",
+		"How to play":   "Spielanleitung",
+		"Move the cursor with the arrow keys or %s. Space selects the object under the cursor, selecting a second object in the same row selects all objects in between. Enter takes the selected objects. With the mouse, click an object or drag over a row and click %s.": "Bewege den Cursor mit den Pfeiltasten oder %s. Die Leertaste wählt das Objekt unter dem Cursor aus, ein zweites Objekt in derselben Reihe wählt alle Objekte dazwischen aus. Enter nimmt die ausgewählten Objekte. Mit der Maus klickst du ein Objekt an oder ziehst über eine Reihe und klickst auf %s.",
+		"Playing the computer": "Gegen den Computer spielen",
+		"Connect with `ssh -t host vs` to play against the computer, which knows the winning strategy. Who starts is decided by a coin toss. With `ssh -t host campaign` you play the levels of the campaign, one after the other.": "Verbinde dich mit `ssh -t host vs`, um gegen den Computer zu spielen, der die Gewinnstrategie kennt. Wer anfängt, entscheidet ein Münzwurf. Mit `ssh -t host campaign` spielst du die Level der Kampagne, eines nach dem anderen.",
+		"↑/↓ scrolls • %s or esc closes • %3.f%%":                                             "↑/↓ blättert • %s oder esc schließt • %3.f%%",
+		"Take any number of objects from one row. Whoever has to take the last object loses.": "Nimm beliebig viele Objekte aus einer Reihe. Wer das letzte Objekt nehmen muss, verliert.",
+		"Take any number of objects from one row. Whoever takes the last object wins.":        "Nimm beliebig viele Objekte aus einer Reihe. Wer das letzte Objekt nimmt, gewinnt.",
+
+		// the settings
+		"Settings":   "Einstellungen",
+		"Theme":      "Farben",
+		"Background": "Hintergrund",
+		"Display":    "Anzeige",
+		"Bell":       "Glocke",
+		"Flash":      "Blinken",
+		"Selection":  "Auswahl",
+		"Glyph":      "Symbol",
+		"Variant":    "Variante",
+		"Mouse":      "Maus",
+		"Scrollback": "Verlauf",
+		"on keeps the game in your terminal after you leave": "an lässt das Spiel in deinem Terminal, wenn du gehst",
+		"Clock":     "Uhr",
+		"Game time": "Spielzeit",
+		"Replay QR": "QR-Code zur Wiedergabe",
+		"Thinking":  "Bedenkzeit",
+		"instant":   "sofort",
+		"normal":    "normal",
+		"slow":      "langsam",
+		"Analysis":  "Analyse",
+		"Language":  "Sprache",
+		"Labels":    "Beschriftung",
+		"Counts":    "Anzahl",
+		"how many objects are left at the end of each row": "wie viele Objekte am Ende jeder Reihe übrig sind",
+		"Frame": "Rahmen",
+		"a border around the board with the game and the clock": "ein Rahmen um das Spielfeld mit dem Spiel und der Uhr",
+		"letters and numbers around the board to name cells":    "Buchstaben und Zahlen am Spielfeld, um Felder zu benennen",
+		"Keys": "Tasten",
+		"Help": "Tastenhilfe",
+		"the keys below the board, full shows all of them": "die Tasten unter dem Spielfeld, voll zeigt alle",
+		"short": "kurz",
+		"full":  "voll",
+		"wasd or hjkl where they are on a Dvorak keyboard": "wasd oder hjkl, wo sie auf einer Dvorak-Tastatur liegen",
+		"on":                      "an",
+		"off":                     "aus",
+		"dark":                    "dunkel",
+		"light":                   "hell",
+		"styled":                  "farbig",
+		"range":                   "Bereich",
+		"quick":                   "schnell",
+		"reader":                  "vorlesen",
+		"the colors of the game":  "die Farben des Spiels",
+		"auto asks your terminal": "auto fragt dein Terminal",
+		"ascii shows plain characters, reader describes the board": "ascii zeigt einfache Zeichen, vorlesen beschreibt das Spielfeld",
+		"ring the terminal bell when it is your turn":              "läutet die Glocke des Terminals, wenn du am Zug bist",
+		"flash the screen when it is your turn":                    "lässt den Bildschirm aufblinken, wenn du am Zug bist",
+		"quick takes a single object with enter alone":             "schnell nimmt ein einzelnes Objekt nur mit Enter",
+		"how objects are drawn, type a character for your own":     "wie Objekte gezeichnet werden, tippe ein eigenes Zeichen",
+		"the game you get when you connect":                        "das Spiel, das du beim Verbinden bekommst",
+		"off lets your terminal select text again":                 "aus lässt dein Terminal wieder Text auswählen",
+		"show how long both players have been thinking":            "zeigt, wie lange beide Spieler nachgedacht haben",
+		"show how long the game has been going on":                 "zeigt, wie lange das Spiel schon läuft",
+		"how long the computer takes before it moves":              "wie lange der Computer vor seinem Zug überlegt",
+		"show a code of the replay for your phone after a game":    "zeigt nach dem Spiel einen Code, der die Wiedergabe auf dem Handy öffnet",
+		"show the nim-sum before and after your selection":         "zeigt die Nim-Summe vor und nach deiner Auswahl",
+		"auto follows LANG of your terminal":                       "auto folgt LANG deines Terminals",
+		"Changes are saved for your next visit.":                   "Änderungen werden für deinen nächsten Besuch gespeichert.",
+		"These settings last until you leave.":                     "Diese Einstellungen gelten, bis du gehst.",
+		"←/→ changes • %s or esc closes":                           "←/→ ändert • %s oder esc schließt",
+
+		// the session
+		"server is shutting down, please try again later": "der Server wird beendet, bitte versuche es später noch einmal",
+		"no such game":                                                               "dieses Spiel gibt es nicht",
+		"game is not open":                                                           "in diesem Spiel ist kein Platz frei",
+		"Server restarting in %s":                                                    "Neustart des Servers in %s",
+		"Server restarting any moment now":                                           "Der Server wird jeden Moment neu gestartet",
+		"server is restarting, please try again in a minute":                         "der Server wird neu gestartet, bitte versuche es in einer Minute noch einmal",
+		"server is full, please try again later":                                     "der Server ist voll, bitte versuche es später noch einmal",
+		"variants are disabled on this server":                                       "Varianten sind auf diesem Server abgeschaltet",
+		"engines only play the classic variant":                                      "Gegner spielen nur die klassische Variante",
+		"You have been disconnected by the operator.":                                "Der Betreiber hat dich getrennt.",
+		"Sorry, something went wrong and your session had to be closed.":             "Entschuldigung, etwas ist schiefgegangen und deine Sitzung musste beendet werden.",
+		"Press any key to disconnect.":                                               "Drücke eine beliebige Taste, um die Verbindung zu trennen.",
+		"Many players are connecting right now. Press %c within %d seconds to play.": "Gerade verbinden sich viele Spieler. Drücke %c innerhalb von %d Sekunden, um zu spielen.",
+		"That was not the key, please try again.":                                    "Das war nicht die Taste, bitte versuche es noch einmal.",
+		"Time is up, please try again.":                                              "Die Zeit ist um, bitte versuche es noch einmal.",
+		"You are typing too fast, some keys were ignored.":                           "Du tippst zu schnell, einige Tasten wurden ignoriert.",
+
+		// the name
+		"Choose your name": "Wähle deinen Namen",
+		"Other players see it instead of your login.": "Andere Spieler sehen ihn statt deines Logins.",
+		"enter confirms • esc keeps %s":               "Enter bestätigt • esc behält %s",
+		"a name has 2 to 16 characters":               "ein Name hat 2 bis 16 Zeichen",
+		"a name has letters a-z, digits, - and _":     "ein Name besteht aus Buchstaben a-z, Ziffern, - und _",
+		"this name belongs to another player":         "dieser Name gehört einem anderen Spieler",
+
+		// the terms
+		"Rules of conduct": "Verhaltensregeln",
+		"Please read and accept the rules of this server before you play.": "Bitte lies die Regeln dieses Servers und akzeptiere sie, bevor du spielst.",
+		"y accepts • q leaves": "y akzeptiert • q verlässt das Spiel",
+	},
+}
+
+// tr returns s in language l.
+func (l language) tr(s string) string {
+	if t, ok := translations[l][s]; ok {
+		return t
+	}
+	return s
+}
+
+// trf formats the translation of format like fmt.Sprintf.
+func (l language) trf(format string, args ...interface{}) string {
+	return fmt.Sprintf(l.tr(format), args...)
+}
+
+// parseLanguage returns the language of a locale like de_DE.UTF-8, if it is
+// one of languages.
+func parseLanguage(locale string) (language, bool) {
+	fields := strings.FieldsFunc(locale, func(r rune) bool {
+		return r == '_' || r == '.' || r == '@' || r == '-'
+	})
+	if len(fields) == 0 {
+		return "", false
+	}
+	code := language(strings.ToLower(fields[0]))
+	for _, l := range languages {
+		if l == code {
+			return l, true
+		}
+	}
+	return "", false
+}
+
+// languageFromEnv reads the language of the player from the locale
+// variables their client sends, English if there are none.
+func languageFromEnv(environ []string) language {
+	vars := map[string]string{}
+	for _, kv := range environ {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	// the first one that is set wins, like in the C library
+	for _, k := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := vars[k]
+		if v == "" || v == "C" || v == "POSIX" {
+			continue
+		}
+		if l, ok := parseLanguage(v); ok {
+			return l
+		}
+		return english
+	}
+	return english
+}
+
+// timeFormats are how dates and clock times are written in a region, as
+// layouts of the time package.
+type timeFormats struct {
+	date, clock string
+}
+
+// isoFormats are used where no other formats are known.
+var isoFormats = timeFormats{date: "2006-01-02", clock: "15:04"}
+
+// localFormats are the formats of regions, e.g. "en_US", and of languages
+// everywhere else, e.g. "de".
+var localFormats = map[string]timeFormats{
+	"en_US": {date: "Jan 2, 2006", clock: "3:04 PM"},
+	"en_CA": {date: "2006-01-02", clock: "3:04 PM"},
+	"en_AU": {date: "02/01/2006", clock: "3:04 PM"},
+	"en_GB": {date: "02/01/2006", clock: "15:04"},
+	"de":    {date: "02.01.2006", clock: "15:04"},
+}
+
+// localeFromEnv returns the locale the client sent for dates and times,
+// e.g. de_AT.UTF-8, or "" if there is none.
+func localeFromEnv(environ []string) string {
+	vars := map[string]string{}
+	for _, kv := range environ {
+		if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+			vars[parts[0]] = parts[1]
+		}
+	}
+	// the first one that is set wins, like in the C library
+	for _, k := range []string{"LC_ALL", "LC_TIME", "LANG"} {
+		if v := vars[k]; v != "" && v != "C" && v != "POSIX" {
+			return v
+		}
+	}
+	return ""
+}
+
+// formatsFor returns the formats of the region of locale, or else the ones
+// of language l.
+func formatsFor(locale string, l language) timeFormats {
+	fields := strings.FieldsFunc(locale, func(r rune) bool {
+		return r == '.' || r == '@'
+	})
+	if len(fields) > 0 {
+		code := strings.Replace(fields[0], "-", "_", 1)
+		if f, ok := localFormats[code]; ok {
+			return f
+		}
+		if i := strings.Index(code, "_"); i > 0 {
+			code = code[:i]
+		}
+		if f, ok := localFormats[strings.ToLower(code)]; ok {
+			return f
+		}
+	}
+	if f, ok := localFormats[string(l)]; ok {
+		return f
+	}
+	return isoFormats
+}
+
+// translateKeys returns km with its help in language l.
+func translateKeys(km keyMap, l language) keyMap {
+	for _, b := range []*key.Binding{
+		&km.Up, &km.Down, &km.Left, &km.Right, &km.Select, &km.Submit,
+		&km.Home, &km.End, &km.Top, &km.Bottom, &km.NextRow,
+		&km.Rules, &km.Settings, &km.Stats, &km.Help, &km.Quit, &km.Cancel,
+	} {
+		b.SetHelp(b.Help().Key, l.tr(b.Help().Desc))
+	}
+	return km
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// board images are drawn in cells of boardCell pixels with a margin of one
+// cell around the board
+const boardCell = 48
+
+var (
+	boardBackground = color.RGBA{0x1a, 0x1a, 0x1a, 0xff}
+	boardObject     = color.RGBA{0x7d, 0x56, 0xf4, 0xff}
+	boardEmpty      = color.RGBA{0x3a, 0x3a, 0x3a, 0xff}
+)
+
+// renderBoardPNG draws the board of state as a PNG image.
+func renderBoardPNG(state gameState) ([]byte, error) {
+	width, height := (state.cols+2)*boardCell, (state.rows+2)*boardCell
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = boardBackground.R, boardBackground.G, boardBackground.B, boardBackground.A
+	}
+	for row := 0; row < state.rows; row++ {
+		for col := 0; col < state.cols; col++ {
+			c, r := boardEmpty, boardCell/8
+			if state.field[row][col] {
+				c, r = boardObject, boardCell/3
+			}
+			cx, cy := (col+1)*boardCell+boardCell/2, (row+1)*boardCell+boardCell/2
+			for y := cy - r; y <= cy+r; y++ {
+				for x := cx - r; x <= cx+r; x++ {
+					if (x-cx)*(x-cx)+(y-cy)*(y-cy) <= r*r {
+						img.SetRGBA(x, y, c)
+					}
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderBoardSVG draws the board of state and its status as an SVG image.
+func renderBoardSVG(state gameState) []byte {
+	width, height := (state.cols+2)*boardCell, (state.rows+3)*boardCell
+	hex := func(c color.RGBA) string { return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B) }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", width, height, width, height)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hex(boardBackground))
+	for row := 0; row < state.rows; row++ {
+		for col := 0; col < state.cols; col++ {
+			c, r := boardEmpty, boardCell/8
+			if state.field[row][col] {
+				c, r = boardObject, boardCell/3
+			}
+			fmt.Fprintf(&b, `<circle cx="%d" cy="%d" r="%d" fill="%s"/>`+"\n",
+				(col+1)*boardCell+boardCell/2, (row+1)*boardCell+boardCell/2, r, hex(c))
+		}
+	}
+	status := fmt.Sprintf("Player %d's turn", state.player)
+	if state.finished() {
+		status = fmt.Sprintf("Player %d won", state.winner())
+	}
+	fmt.Fprintf(&b, `<text x="50%%" y="%d" fill="#ffffff" font-family="monospace" font-size="%d" text-anchor="middle">Nimm %s · %s</text>`+"\n",
+		(state.rows+2)*boardCell, boardCell/3, state.id, status)
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	// defaultMaxInput is how many keys and mouse events per second a
+	// session may send unless the config says otherwise. Holding a key
+	// down sends about 30.
+	defaultMaxInput = 100
+	// inputWarnAfter is how many seconds with dropped input it takes until
+	// the player is warned and the session is flagged.
+	inputWarnAfter = 3
+)
+
+// inputLimiter drops the input of a session that comes faster than the
+// configured rate, e.g. from a script flooding it with keys, so that it
+// can't keep the server busy rendering.
+type inputLimiter struct {
+	window time.Time
+	n      int
+	// violations counts the seconds in which input was dropped
+	violations int
+	// dropped is set if the last message was dropped, view is the last
+	// screen, which stays the same then
+	dropped bool
+	view    string
+}
+
+// allow reports whether one more input event may pass at now if max may
+// pass per second, and whether the player should be warned about the ones
+// that don't. A max of 0 lets everything pass.
+func (l *inputLimiter) allow(now time.Time, max int) (ok, warn bool) {
+	if max <= 0 {
+		return true, false
+	}
+	if now.Sub(l.window) >= time.Second {
+		l.window, l.n = now, 0
+	}
+	l.n++
+	if l.n <= max {
+		return true, false
+	}
+	if l.n == max+1 {
+		l.violations++
+		return false, l.violations == inputWarnAfter
+	}
+	return false, false
+}
+
+// limitInput drops msg if it is input that comes too fast. The returned
+// command warns the player once they keep doing it.
+func (m safeModel) limitInput(msg tea.Msg) (drop bool, cmd tea.Cmd) {
+	switch msg.(type) {
+	case tea.KeyMsg, tea.MouseMsg:
+	default:
+		return false, nil
+	}
+	ok, warn := m.input.allow(time.Now(), currentConfig().MaxInputPerSecond)
+	if ok {
+		return false, nil
+	}
+	if !warn {
+		return true, nil
+	}
+	if atomic.CompareAndSwapInt32(&m.sess.stats.flagged, 0, 1) {
+		log.Printf("Flagged session %s (%s): input faster than %d events per second", shortID(m.sess.id), logAddr(m.sess.addr), currentConfig().MaxInputPerSecond)
+	}
+	text := m.sess.lang.tr("You are typing too fast, some keys were ignored.")
+	return true, func() tea.Msg { return toastMsg(text) }
+}
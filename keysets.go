@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/jheuel/nimm/board"
+)
+
+// keySet are the letter keys of a keyMap. The arrow keys, space, enter and
+// esc work with all sets.
+type keySet struct {
+	name string
+	// move is how the set is called in the rules, e.g. "hjkl"
+	move                  string
+	up, down, left, right string
+	rules, settings       string
+}
+
+// keySets are the keys players can choose from. The first is the default.
+var keySets = []keySet{
+	{name: "default", move: "hjkl", up: "k", down: "j", left: "h", right: "l", rules: "r", settings: "s"},
+	// s moves down, so the settings are the options
+	{name: "wasd", move: "wasd", up: "w", down: "s", left: "a", right: "d", rules: "r", settings: "o"},
+	// hjkl where they are on a QWERTY keyboard
+	{name: "dvorak", move: "dhtn", up: "t", down: "h", left: "d", right: "n", rules: "r", settings: "s"},
+}
+
+func keySetNames() []string {
+	names := make([]string, len(keySets))
+	for i, s := range keySets {
+		names[i] = s.name
+	}
+	return names
+}
+
+// getKeySet returns the set called name, the default one if there is none.
+func getKeySet(name string) keySet {
+	for _, s := range keySets {
+		if s.name == name {
+			return s
+		}
+	}
+	return keySets[0]
+}
+
+// keyMap returns the bindings of the set.
+func (s keySet) keyMap() keyMap {
+	return keyMap{
+		KeyMap: board.KeyMap{
+			Up: key.NewBinding(
+				key.WithKeys("up", s.up),
+				key.WithHelp("↑/"+s.up, "move up"),
+			),
+			Down: key.NewBinding(
+				key.WithKeys("down", s.down),
+				key.WithHelp("↓/"+s.down, "move down"),
+			),
+			Left: key.NewBinding(
+				key.WithKeys("left", s.left),
+				key.WithHelp("←/"+s.left, "move left"),
+			),
+			Right: key.NewBinding(
+				key.WithKeys("right", s.right),
+				key.WithHelp("→/"+s.right, "move right"),
+			),
+			Select:  board.DefaultKeyMap.Select,
+			Submit:  board.DefaultKeyMap.Submit,
+			Home:    board.DefaultKeyMap.Home,
+			End:     board.DefaultKeyMap.End,
+			Top:     board.DefaultKeyMap.Top,
+			Bottom:  board.DefaultKeyMap.Bottom,
+			NextRow: board.DefaultKeyMap.NextRow,
+		},
+		Rules: key.NewBinding(
+			key.WithKeys(s.rules),
+			key.WithHelp(s.rules, "rules"),
+		),
+		Settings: key.NewBinding(
+			key.WithKeys(s.settings),
+			key.WithHelp(s.settings, "settings"),
+		),
+		Help: key.NewBinding(
+			key.WithKeys("?"),
+			key.WithHelp("?", "help"),
+		),
+		Quit: key.NewBinding(
+			key.WithKeys("q", "esc", "ctrl+c"),
+			key.WithHelp("q", "quit"),
+		),
+		Stats: key.NewBinding(
+			key.WithKeys("i"),
+			key.WithHelp("i", "session"),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "stop waiting"),
+		),
+	}
+}
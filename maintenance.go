@@ -0,0 +1,56 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// maintenance is a restart the operator announced, which all sessions show
+// with the time that is left until then.
+type maintenance struct {
+	at time.Time
+	// text is what the operator added, e.g. why
+	text string
+}
+
+// maintenanceMsg tells a session about an announced restart, a zero one
+// takes it back.
+type maintenanceMsg maintenance
+
+var (
+	maintenanceMu sync.Mutex
+	announced     maintenance
+)
+
+// announceMaintenance shows mt in all sessions, and in the ones that start
+// until then.
+func announceMaintenance(mt maintenance) {
+	maintenanceMu.Lock()
+	announced = mt
+	maintenanceMu.Unlock()
+	sessions.broadcast(maintenanceMsg(mt))
+}
+
+func currentMaintenance() maintenance {
+	maintenanceMu.Lock()
+	defer maintenanceMu.Unlock()
+	return announced
+}
+
+// pending reports whether the restart is still ahead at now, so that the
+// time left changes.
+func (mt maintenance) pending(now time.Time) bool {
+	return !mt.at.IsZero() && now.Before(mt.at)
+}
+
+// banner tells the player in language l how long it is until the restart.
+func (mt maintenance) banner(l language, now time.Time) string {
+	msg := l.tr("Server restarting any moment now")
+	if mt.pending(now) {
+		msg = l.trf("Server restarting in %s", formatClock(mt.at.Sub(now)))
+	}
+	if mt.text != "" {
+		msg += ": " + mt.text
+	}
+	return msg
+}
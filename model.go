@@ -0,0 +1,1042 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/board"
+	"github.com/jheuel/nimm/variant"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+type model struct {
+	id         string
+	game       *game
+	state      gameState
+	trace      *span
+	banner     string
+	term       string
+	width      int
+	height     int
+	time       time.Time
+	clock      bool
+	gameTime   bool
+	help       help.Model
+	keys       keyMap
+	board      board.Model
+	shutdownIn time.Duration
+	// maintenance is the restart the operator announced, if any
+	maintenance maintenance
+	// watching is set if the player only watches the game
+	watching bool
+	// toasts are the notifications shown, lastToast is the id of the
+	// newest one
+	toasts    []toast
+	lastToast int
+
+	// you is the player of this session against opponent or another
+	// player, or 0 if both players share the keyboard
+	you      int
+	opponent engine
+	// said is what the opponent said last, if it is a personality
+	said string
+	// level is the level of the campaign played, counted from 1, or 0
+	level int
+	// season is the event that lasts, if any
+	season seasonConfig
+	// stats are the results of the player when they connected
+	stats playerStats
+	// cancelWait is closed to let the solver move for the opponent instead
+	// of waiting any longer for the engine, and replaced by a new one
+	cancelWait chan struct{}
+	// spinner spins while the player waits for the opponent
+	spinner spinner.Model
+	theme   theme
+	// light is set if the terminal has a light background
+	light bool
+	// noColor is set if the terminal or the player doesn't want colors,
+	// ascii if it should only get plain characters
+	noColor bool
+	ascii   bool
+	// asciiLocale is set if the locale of the client can't show Unicode,
+	// which is replaced then unless the player chose a display
+	asciiLocale bool
+	// reader describes the board in words for screen readers
+	reader bool
+
+	// user is the login of the player and key the fingerprint of their
+	// login key, which their settings are saved under, empty for guests
+	user string
+	key  string
+	// owner is who the player moves as, see game.owners
+	owner    string
+	prefs    userPrefs
+	settings *settingsScreen
+	// name asks the player for the name shown instead of their login
+	name  *namePrompt
+	rules *viewport.Model
+	// confirmQuit asks the player whether they really want to leave their
+	// game
+	confirmQuit bool
+	// showHelp shows all keys on top of the screen
+	showHelp bool
+	// showSummary shows what the player did in the session since they
+	// connected, and leaving is set if it was opened by quitting
+	showSummary bool
+	leaving     bool
+	connected   time.Time
+	// replayURL is where the replays of games are on the web, see
+	// config.replayURL, and qrClosed is set once the player closed the code
+	// of the finished game
+	replayURL string
+	qrClosed  bool
+	// splash shows the logo and tagline before the game
+	splash  bool
+	tagline string
+	// ticking is set while a timeMsg is on its way
+	ticking bool
+	// bell is where the terminal bell is rung and the screen flashed
+	bell io.Writer
+	// terms are the rules of conduct the player still has to accept
+	terms string
+	// rec records the moves and names of the session, nil if recording is
+	// off
+	rec *recording
+	// lang is the language of the screen, envLang the one the client asked
+	// for
+	lang    language
+	envLang language
+	// formats are how dates and times are written, envLocale is the locale
+	// the client sent for them
+	formats   timeFormats
+	envLocale string
+	// location is the time zone times are shown in, envLocation the one
+	// the client sent
+	location    *time.Location
+	envLocation *time.Location
+}
+
+type timeMsg time.Time
+
+// moveMsg is a move submitted on the board.
+type moveMsg variant.Move
+
+// tick sends a timeMsg after a second. Models only keep ticking while they
+// display a clock or the game time that is running, everything else is
+// rendered when it happens, so idle sessions don't cause any work.
+func tick() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return timeMsg(t)
+	})
+}
+
+// newModel returns a model for playing g.
+func newModel(g *game, term string, width, height int) model {
+	state := g.state()
+	b := board.New(state.field, state.variant.Hints())
+	b.KeyMap = keys.KeyMap
+	b.OnMove = func(mv variant.Move) tea.Cmd {
+		return func() tea.Msg { return moveMsg(mv) }
+	}
+	m := model{
+		game:   g,
+		state:  state,
+		term:   term,
+		width:  width,
+		height: height,
+		time:   time.Now(),
+		help:   help.New(),
+		keys:   keys,
+		board:  b,
+
+		cancelWait: make(chan struct{}),
+		spinner:    spinner.New(),
+		// the client's locale is applied by setPrefs
+		envLang:     english,
+		envLocation: time.Local,
+	}
+	m.connected = m.time
+	m.setPrefs(userPrefs{})
+	return m
+}
+
+// applyConfig applies a reloaded config to the session. The game keeps its
+// variant and board, new games and players joining get the new ones.
+func (m *model) applyConfig(c config) {
+	m.banner = c.Banner
+	m.tagline = c.Tagline
+	m.replayURL = c.replayURL()
+	// the theme and glyph of an event that was added or removed
+	m.season, _ = activeSeason(c, time.Now())
+	m.setPrefs(m.prefs)
+}
+
+// setPrefs applies the settings of the player to the session.
+func (m *model) setPrefs(p userPrefs) {
+	m.prefs = p
+	light := m.light
+	switch p.Background {
+	case "light":
+		light = true
+	case "dark":
+		light = false
+	}
+	name := p.Theme
+	if name == "" {
+		name = m.season.Theme
+	}
+	m.theme = getTheme(name, light)
+	if m.noColor {
+		m.theme = getTheme("monochrome", light)
+	}
+	m.reader = p.Display == "reader"
+	m.ascii = p.Display == "ascii" || m.reader || p.Display == "" && asciiTerminal(m.term)
+	m.board.Markers = m.ascii
+	m.spinner.Spinner = spinner.MiniDot
+	if m.ascii {
+		m.spinner.Spinner = spinner.Line
+	}
+	m.board.Styles = m.theme.board
+	m.help.Styles = m.theme.keys
+	m.clock = p.Clock
+	m.gameTime = p.GameTime
+	m.board.Quick = p.Selection == "quick"
+	m.board.Glyph = p.Glyph
+	if p.Glyph == "" {
+		m.board.Glyph = m.season.Glyph
+	}
+	m.board.Labels = p.Labels
+	m.board.Counts = p.Counts
+	m.lang = m.envLang
+	m.location = m.envLocation
+	if loc, err := parseTimeZone(p.TimeZone); err == nil {
+		m.location = loc
+	}
+	if l, ok := parseLanguage(p.Language); ok {
+		m.lang = l
+	}
+	m.formats = formatsFor(m.envLocale, m.lang)
+	m.keys = translateKeys(getKeySet(p.Keys).keyMap(), m.lang)
+	m.board.KeyMap = m.keys.KeyMap
+}
+
+// clockRunning reports whether the clock, the game time or the time until
+// an announced restart is shown and still changes. The clocks stop once the
+// game is finished, the restart when it is due.
+func (m model) clockRunning() bool {
+	return (m.clock || m.gameTime) && !m.state.finished() || m.maintenance.pending(m.time)
+}
+
+// startClock starts ticking if the clock is running and isn't ticking yet.
+func (m *model) startClock() tea.Cmd {
+	if !m.clockRunning() || m.ticking {
+		return nil
+	}
+	m.ticking = true
+	return tick()
+}
+
+// flashDuration is how long the screen is inverted to alert the player.
+const flashDuration = 150 * time.Millisecond
+
+// alert rings the terminal bell and flashes the screen, as far as the
+// player wants it.
+func (m model) alert() tea.Cmd {
+	if !m.prefs.Bell && !m.prefs.Flash || m.bell == nil {
+		return nil
+	}
+	w, bell, flash := m.bell, m.prefs.Bell, m.prefs.Flash
+	return func() tea.Msg {
+		if bell {
+			_, _ = io.WriteString(w, "\a")
+		}
+		if flash {
+			// the reverse video mode of the terminal, like a visual bell
+			_, _ = io.WriteString(w, "\x1b[?5h")
+			time.Sleep(flashDuration)
+			_, _ = io.WriteString(w, "\x1b[?5l")
+		}
+		return nil
+	}
+}
+
+// savePrefs saves the settings of players with a key for their next visit.
+func (m model) savePrefs() tea.Cmd {
+	if m.key == "" {
+		return nil
+	}
+	user, key, p := m.user, m.key, m.prefs
+	return func() tea.Msg {
+		err := prefs.update(key, func(old *userPrefs) {
+			colors := old.Colors
+			*old = p
+			old.Colors = colors
+		})
+		if err != nil {
+			log.Printf("Could not save settings of %s: %v", user, err)
+		}
+		return nil
+	}
+}
+
+func (m model) Init() tea.Cmd {
+	var cmds []tea.Cmd
+	if m.splash {
+		cmds = append(cmds, tea.Tick(splashDuration, func(time.Time) tea.Msg {
+			return splashDoneMsg{}
+		}))
+	}
+	if m.clockRunning() {
+		// the first timeMsg starts the ticking, Init can't mark the model
+		// as ticking, and a second ticker would render twice as often
+		cmds = append(cmds, func() tea.Msg { return timeMsg(time.Now()) })
+	}
+	if m.mouse() {
+		cmds = append(cmds, tea.EnableMouseCellMotion)
+	}
+	if m.opponent != nil {
+		name := m.opponent.name()
+		cmds = append(cmds, func() tea.Msg {
+			return toastMsg(m.lang.trf("%s joined the game", name))
+		})
+	}
+	if text := m.streakText(time.Now()); text != "" {
+		cmds = append(cmds, func() tea.Msg { return toastMsg(text) })
+	}
+	if greeting := m.season.Greeting; greeting != "" {
+		cmds = append(cmds, func() tea.Msg { return toastMsg(greeting) })
+	}
+	if m.opponentsTurn() {
+		cmds = append(cmds, m.opponentMove(), m.spinner.Tick)
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m model) opponentsTurn() bool {
+	return m.opponent != nil && m.state.player != m.you && !m.state.finished()
+}
+
+// canMove reports whether the player may move now: it is their turn, or
+// they play both sides.
+func (m model) canMove() bool {
+	return !m.watching && (m.you == 0 || m.state.player == m.you)
+}
+
+// opponentMove lets the opponent engine make its move.
+func (m model) opponentMove() tea.Cmd {
+	g, eng, cancel := m.game, m.opponent, m.cancelWait
+	delay := thinkingDelay(m.prefs.Thinking)
+	return func() tea.Msg {
+		// look like thinking, unless the player stops waiting
+		select {
+		case <-time.After(delay):
+		case <-cancel:
+		}
+		state, err := playEngineMove(g, eng, cancel)
+		if err != nil {
+			log.Printf("Opponent %s could not move in game %s: %v", eng.name(), state.id, err)
+		}
+		return gameUpdateMsg(state)
+	}
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case timeMsg:
+		m.time = time.Time(msg)
+		m.ticking = false
+		return m, m.startClock()
+	case spinner.TickMsg:
+		// the spinner stops once the opponent moved
+		if !m.opponentsTurn() {
+			return m, nil
+		}
+		m.time = msg.Time
+		var cmd tea.Cmd
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+	case backgroundMsg:
+		m.light = bool(msg)
+		m.setPrefs(m.prefs)
+		if m.rules != nil {
+			m.openRules()
+		}
+	case configMsg:
+		m.applyConfig(config(msg))
+	case seasonMsg:
+		m.season = seasonConfig(msg)
+		m.setPrefs(m.prefs)
+		if m.season.Greeting != "" {
+			return m, m.notify(m.season.Greeting, broadcastDuration)
+		}
+	case broadcastMsg:
+		return m, m.notify(m.lang.tr("Message from the operator: ")+string(msg), broadcastDuration)
+	case toastMsg:
+		return m, m.notify(string(msg), toastDuration)
+	case dismissToastMsg:
+		m.dismissToast(int(msg))
+	case maintenanceMsg:
+		m.maintenance = maintenance(msg)
+		m.time = time.Now()
+		return m, m.startClock()
+	case shutdownMsg:
+		m.shutdownIn = time.Duration(msg)
+	case spectatorsMsg:
+		m.state.spectators = int(msg)
+	case cursorMsg:
+		// only the cursor of the player to move is interesting
+		if m.you != 0 && msg.player != m.you && msg.player == m.state.player {
+			m.board.SetRemote(msg.row, msg.col)
+		}
+	case gameUpdateMsg:
+		// updates are sent concurrently, so they might arrive out of order
+		if msg.moves == m.state.moves {
+			// a player chose their name
+			m.state.players = msg.players
+		}
+		if msg.moves > m.state.moves {
+			m.state = gameState(msg)
+			m.recordMove()
+			// show what the opponent took, while the board still has it
+			var flash tea.Cmd
+			if n := len(m.state.history); m.you != 0 && n > 0 && m.state.history[n-1].Player != m.you {
+				mv := m.state.history[n-1]
+				flash = m.board.Flash(variant.Move{Row: mv.Row, From: mv.From, To: mv.To})
+			}
+			m.board.SetBoard(m.state.field)
+			m.board.HideRemote()
+			if m.you == 0 {
+				return m, nil
+			}
+			cmds := []tea.Cmd{m.finishLevel(), m.remarkOnMove(), flash}
+			if m.state.player == m.you || m.state.finished() {
+				cmds = append(cmds, m.alert())
+			}
+			return m, tea.Batch(cmds...)
+		}
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.width = msg.Width
+		m.help.Width = msg.Width
+		if m.rules != nil {
+			m.openRules()
+		}
+	case moveMsg:
+		if m.opponentsTurn() || !m.canMove() {
+			return m, nil
+		}
+		move := m.trace.child("move",
+			attr{"game.id", m.state.id},
+			attr{"player", m.state.player},
+			attr{"row", msg.Row},
+			attr{"count", variant.Taken(m.state.field, variant.Move{Row: msg.Row, From: msg.From, To: msg.To})},
+		)
+		defer move.finish()
+		state, err := m.game.takeFor(m.owner, msg.Row, msg.From, msg.To)
+		m.state = state
+		m.board.SetBoard(state.field)
+		if err != nil {
+			move.setError(err)
+			return m, nil
+		}
+		m.recordMove()
+
+		// reset selection
+		m.board.Reset()
+		remark := m.remarkOnMove()
+		if m.opponentsTurn() {
+			return m, tea.Batch(m.opponentMove(), m.spinner.Tick, remark)
+		}
+		return m, tea.Batch(m.finishLevel(), remark)
+	case tea.MouseMsg:
+		if m.rules != nil {
+			vp, cmd := m.rules.Update(msg)
+			m.rules = &vp
+			return m, cmd
+		}
+		if m.splash || m.terms != "" || m.name != nil || m.settings != nil || m.confirmQuit || m.showSummary || m.showHelp || m.showReplayQR() || !m.mouse() {
+			return m, nil
+		}
+		// find the objects where they were drawn
+		m.fitBoard()
+		if msg.Type == tea.MouseLeft && m.onTakeButton(msg.X, msg.Y) {
+			return m, m.board.Submit()
+		}
+		// the board counts from its own corner
+		x, y := m.boardOrigin()
+		msg.X -= x
+		msg.Y -= y
+		row, col := m.board.Cursor()
+		var cmd tea.Cmd
+		m.board, cmd = m.board.Update(msg)
+		m.shareCursor(row, col)
+		return m, cmd
+	case splashDoneMsg:
+		m.splash = false
+	case tea.KeyMsg:
+		if m.splash {
+			// any key skips the splash screen
+			m.splash = false
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.terms != "" {
+			return m.updateTerms(msg)
+		}
+		if m.name != nil {
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			p, name, done := m.name.update(msg, m.user, m.key)
+			m.name = &p
+			if !done {
+				return m, nil
+			}
+			m.name = nil
+			m.prefs.Name = name
+			m.rec.record(recordedEvent{Event: "name", Text: name})
+			// the seats were taken under the login
+			m.game.rename(m.owner, name)
+			return m, m.savePrefs()
+		}
+		if m.showHelp {
+			// any key closes the help
+			m.showHelp = false
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.showSummary {
+			return m.updateSummary(msg)
+		}
+		if m.showReplayQR() {
+			// any key closes the code, like the help
+			m.qrClosed = true
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.confirmQuit {
+			m.confirmQuit = false
+			// esc goes back like any other key
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			if msg.String() == "y" || msg.Type != tea.KeyEsc && key.Matches(msg, m.keys.Quit) {
+				return m.openSummary(true)
+			}
+			return m, nil
+		}
+		if m.rules != nil {
+			switch {
+			case msg.Type == tea.KeyCtrlC:
+				return m, tea.Quit
+			case key.Matches(msg, m.keys.Rules, m.keys.Quit):
+				m.rules = nil
+				return m, nil
+			}
+			vp, cmd := m.rules.Update(msg)
+			m.rules = &vp
+			return m, cmd
+		}
+		if m.settings != nil {
+			s, changed, closed := m.settings.update(msg, m.keys)
+			m.settings = &s
+			if closed {
+				m.settings = nil
+			}
+			if !changed {
+				return m, nil
+			}
+			mouse, scrollback := m.mouse(), m.prefs.Scrollback
+			m.setPrefs(s.prefs)
+			cmds := []tea.Cmd{m.startClock(), m.savePrefs()}
+			if m.mouse() && !mouse {
+				cmds = append(cmds, tea.EnableMouseCellMotion)
+			} else if !m.mouse() && mouse {
+				cmds = append(cmds, tea.DisableMouse)
+			}
+			if m.prefs.Scrollback && !scrollback {
+				cmds = append(cmds, tea.ExitAltScreen)
+			} else if !m.prefs.Scrollback && scrollback {
+				cmds = append(cmds, tea.EnterAltScreen)
+			}
+			return m, tea.Batch(cmds...)
+		}
+		switch {
+		case key.Matches(msg, m.keys.Settings):
+			m.settings = &settingsScreen{prefs: m.prefs}
+		case key.Matches(msg, m.keys.Rules):
+			m.openRules()
+		case key.Matches(msg, m.keys.Quit):
+			if msg.Type != tea.KeyCtrlC && m.inProgress() {
+				m.confirmQuit = true
+				return m, nil
+			}
+			if msg.Type == tea.KeyCtrlC {
+				return m, tea.Quit
+			}
+			return m.openSummary(true)
+		case key.Matches(msg, m.keys.Stats):
+			return m.openSummary(false)
+		case key.Matches(msg, m.keys.Help):
+			m.showHelp = true
+		case key.Matches(msg, m.keys.Cancel) && m.opponentsTurn():
+			close(m.cancelWait)
+			m.cancelWait = make(chan struct{})
+		default:
+			row, col := m.board.Cursor()
+			var cmd tea.Cmd
+			m.board, cmd = m.board.Update(msg)
+			m.shareCursor(row, col)
+			return m, cmd
+		}
+	default:
+		// e.g. the frames of a flash
+		var cmd tea.Cmd
+		m.board, cmd = m.board.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+// shareCursor shows the cursor to the other sessions of the game, if it
+// moved away from row and col.
+func (m model) shareCursor(row, col int) {
+	if m.you == 0 {
+		return
+	}
+	if r, c := m.board.Cursor(); r != row || c != col {
+		m.game.moveCursor(m.id, m.you, r, c)
+	}
+}
+
+func available(field [][]bool) int {
+	sum := 0
+	for _, row := range field {
+		for _, col := range row {
+			if col {
+				sum++
+			}
+		}
+	}
+	return sum
+}
+
+// status tells whose turn it is, or who lost.
+func (m model) status() string {
+	switch {
+	case m.you == 0 && m.state.finished():
+		return m.lang.trf("Player %d lost", m.state.player)
+	case m.you == 0:
+		return m.lang.trf("Player %d's turn", m.state.player)
+	case m.state.finished() && m.state.player == m.you:
+		return m.lang.tr("You lost")
+	case m.state.finished():
+		return m.lang.tr("You won!")
+	case m.state.player == m.you:
+		return m.lang.tr("Your turn")
+	}
+	return m.lang.tr("Opponent's turn")
+}
+
+// streakText greets the player with their streak when they connect at t,
+// nothing if they have none.
+func (m model) streakText(t time.Time) string {
+	days, today := m.stats.streakAt(t)
+	if days == 0 {
+		return ""
+	}
+	if today {
+		if days == 1 {
+			// a single day is no streak yet
+			return ""
+		}
+		return m.lang.trf("Day %d of your streak!", days)
+	}
+	return m.lang.trf("Finish a game today for day %d of your streak!", days+1)
+}
+
+// clockText tells how long both players have been thinking.
+func (m model) clockText() string {
+	t := thinkingTime(m.state, m.game.created, m.time)
+	names := [2]string{m.lang.trf("Player %d", 1), m.lang.trf("Player %d", 2)}
+	if m.you != 0 {
+		names[m.you-1], names[2-m.you] = m.lang.tr("You"), m.lang.tr("Opponent")
+	}
+	return fmt.Sprintf("%s %s  %s %s", names[0], formatClock(t[0]), names[1], formatClock(t[1]))
+}
+
+// recordMove records the last move of the game.
+func (m model) recordMove() {
+	if n := len(m.state.history); n > 0 {
+		mv := m.state.history[n-1]
+		m.rec.record(recordedEvent{Event: "move", Game: m.state.id, Move: &mv})
+	}
+}
+
+// gameTimeText tells how long the game has been going on, until the last
+// move once it is finished.
+func (m model) gameTimeText() string {
+	end := m.time
+	if n := len(m.state.history); m.state.finished() && n > 0 {
+		end = m.state.history[n-1].At
+	}
+	d := end.Sub(m.game.created)
+	if d < 0 {
+		d = 0
+	}
+	return m.lang.trf("game %s", formatClock(d))
+}
+
+// waited is how long the player has been waiting for the last move.
+func (m model) waited() time.Duration {
+	since := m.game.created
+	if n := len(m.state.history); n > 0 {
+		since = m.state.history[n-1].At
+	}
+	if d := m.time.Sub(since); d > 0 {
+		return d
+	}
+	return 0
+}
+
+func formatClock(d time.Duration) string {
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+func (m model) View() string {
+	m.fitBoard()
+	switch {
+	case m.ascii:
+		return plainText(m.styledView())
+	case m.asciiLocale && m.prefs.Display == "":
+		return asciiText(m.styledView())
+	}
+	return m.styledView()
+}
+
+// compactHeight is the height of screens below which the compact layout is
+// used, with fewer empty lines and a shorter help.
+const compactHeight = 30
+
+// compact reports whether the screen is too low for the roomy layout, e.g.
+// 80x24.
+func (m model) compact() bool {
+	return m.height < compactHeight
+}
+
+// inset indents the rules and the settings against the rest of the screen.
+var inset = lipgloss.NewStyle().PaddingLeft(2)
+
+// contentWidth is the width of the screen inside the border of two columns
+// on both sides.
+func (m model) contentWidth() int {
+	if m.width < 4 {
+		return 0
+	}
+	return m.width - 4
+}
+
+// textWidth is the width text in the header is wrapped to.
+func (m model) textWidth() int {
+	if w := m.contentWidth() - 8; w > 10 {
+		return w
+	}
+	return 10
+}
+
+// center places the block s in the middle of the screen, or at its left
+// edge if the screen is too narrow for it.
+func (m model) center(s string) string {
+	// pad the lines to the widest one so that the block keeps its shape
+	s = lipgloss.NewStyle().Width(lipgloss.Width(s)).Render(s)
+	return lipgloss.PlaceHorizontal(m.contentWidth(), lipgloss.Center, s)
+}
+
+// minSize is the smallest screen the game fits on, without the rules.
+func (m model) minSize() (width, height int) {
+	m.board.Sticks = false
+	fw, _ := m.frameSize()
+	width = lipgloss.Width(m.boardView()) + fw + 8
+	// the help is cut off to its width, measure all of it
+	h := m.help
+	h.Width = 0
+	if w := lipgloss.Width(h.View(m.keys)) + 4; m.prefs.Help != "off" && w > width {
+		width = w
+	}
+	return width, m.heightFor(m.header())
+}
+
+// fitBoard draws the objects as matchsticks if they fit on the screen.
+func (m *model) fitBoard() {
+	if m.reader {
+		m.board.Sticks = false
+		return
+	}
+	m.board.Sticks = true
+	fw, _ := m.frameSize()
+	width := lipgloss.Width(m.board.View()) + fw + 8
+	m.board.Sticks = m.width >= width && m.height >= m.heightFor(m.header())
+}
+
+// heightFor is how many lines the screen needs below header.
+func (m model) heightFor(header string) int {
+	// the empty lines around, the status bar and the help, see styledView
+	height := strings.Count(header, "\n") + 6 + strings.Count(m.helpFooter(), "\n") + 1
+	if m.compact() {
+		// without the empty lines around the help and the version
+		height -= 3
+	}
+	if m.terms != "" {
+		return height + strings.Count(m.termsView(), "\n")
+	}
+	if m.name != nil {
+		return height + strings.Count(m.name.view(m.theme, m.lang, m.user), "\n")
+	}
+	if m.settings != nil {
+		return height + strings.Count(m.settings.view(m.theme, m.lang, m.keys, m.key != "", minSettingsRows), "\n")
+	}
+	if m.confirmQuit {
+		return height + strings.Count(m.quitDialog(), "\n")
+	}
+	if m.showSummary {
+		return height + strings.Count(m.summaryView(), "\n")
+	}
+	_, fh := m.frameSize()
+	height += strings.Count(m.boardView(), "\n") + fh
+	if m.mouse() {
+		height += 2
+	}
+	return height
+}
+
+// openRules shows the rules page, or fits it to the screen if it is open.
+func (m *model) openRules() {
+	offset := 0
+	if m.rules != nil {
+		offset = m.rules.YOffset
+	}
+	w, h := rulesSize(m.header(), m.width, m.height, m.compact())
+	vp := viewport.New(w, h)
+	vp.SetContent(rulesText(m.state.variant, m.theme, m.lang, getKeySet(m.prefs.Keys), w, m.compact()))
+	vp.SetYOffset(offset)
+	m.rules = &vp
+}
+
+// header is the part of the screen above the board, the settings or the
+// rules.
+func (m model) header() string {
+	var blocks []string
+	if m.shutdownIn > 0 {
+		msg := m.lang.trf("Server shutting down in %ds, your game will be saved", int(m.shutdownIn.Round(time.Second).Seconds()))
+		blocks = append(blocks, m.center(m.theme.warn.Render(wordwrap.String(msg, m.contentWidth()))))
+	} else if !m.maintenance.at.IsZero() {
+		msg := m.maintenance.banner(m.lang, m.time)
+		blocks = append(blocks, m.center(m.theme.warn.Render(wordwrap.String(msg, m.contentWidth()))))
+	}
+	blocks = append(blocks, m.center(m.theme.title.Render("== Nimm ==")))
+	sep := "\n\n"
+	if m.compact() {
+		sep = "\n"
+	}
+	if m.banner != "" {
+		blocks = append(blocks, inset.Render(m.theme.text.Render(wordwrap.String(m.banner, m.textWidth()))))
+	}
+	return strings.Join(blocks, sep) + sep
+}
+
+// helpFooter is the help below the board, the short one unless the player
+// hid it or pinned all keys. The full help leaves out the columns that don't
+// fit.
+func (m model) helpFooter() string {
+	switch m.prefs.Help {
+	case "off":
+		return ""
+	case "full":
+		if m.compact() {
+			// all keys don't fit, they are still in the help box
+			break
+		}
+		h := m.help
+		h.ShowAll = true
+		h.Width = m.contentWidth()
+		return h.View(m.keys)
+	}
+	return m.help.View(m.keys)
+}
+
+// helpBox shows all keys in a box for the help overlay.
+func (m model) helpBox() string {
+	h := m.help
+	h.Width = m.width - 12
+	text := m.theme.title.Render(m.lang.tr("Keys")) + "\n\n" +
+		h.FullHelpView(m.keys.FullHelp()) + "\n\n" +
+		m.theme.dim.Render(m.lang.tr("any key closes the help"))
+	return lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(1, 2).Render(text)
+}
+
+// showReplayQR reports whether the code of the replay is shown, after a
+// finished game if the player turned it on.
+func (m model) showReplayQR() bool {
+	return m.prefs.ReplayQR && m.replayURL != "" && m.state.finished() && !m.qrClosed
+}
+
+// replayQRBox shows the link to the replay of the game and a QR code of it,
+// unless the screen shows no Unicode or is too small for the code.
+func (m model) replayQRBox() string {
+	t, l := m.theme, m.lang
+	link := replayLink(m.replayURL, m.state.id)
+	style := lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 2)
+	footer := t.text.Render(wordwrap.String(link, m.width-12)) + "\n" +
+		t.dim.Render(l.tr("any key closes the code"))
+	if !m.ascii && !(m.asciiLocale && m.prefs.Display == "") {
+		if code, err := qrText(link); err == nil {
+			code = lipgloss.PlaceHorizontal(lipgloss.Width(footer), lipgloss.Center, code)
+			if box := style.Render(code + "\n\n" + footer); lipgloss.Height(box) <= m.height {
+				return box
+			}
+		}
+	}
+	return style.Padding(1, 2).Render(t.title.Render(l.tr("Replay")) + "\n\n" + footer)
+}
+
+// inProgress reports whether the game has started and isn't over yet, so
+// that leaving it would give it up.
+func (m model) inProgress() bool {
+	return m.state.moves > 0 && !m.state.finished()
+}
+
+// quitDialog asks whether the player really wants to give up their game.
+func (m model) quitDialog() string {
+	text := m.theme.warn.Render(m.lang.tr("Really quit? You will give up this game.")) + "\n\n" +
+		m.theme.dim.Render(m.lang.tr("y or q quits • any other key goes back"))
+	return lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 2).Render(text) + "\n"
+}
+
+// takeButton submits the selection when it is clicked.
+const takeButton = "[ take ]"
+
+// mouse reports whether the player uses the mouse.
+func (m model) mouse() bool {
+	return !m.prefs.NoMouse && !m.reader
+}
+
+// onTakeButton reports whether x, y of the screen is on the take button.
+func (m model) onTakeButton(x, y int) bool {
+	bx, by := m.boardOrigin()
+	fx, fy := m.frameOffset()
+	_, fh := m.frameSize()
+	// the button is below the frame, indented by two like the board
+	bx += 2 - fx
+	by += strings.Count(m.board.View(), "\n") + 1 + fh - fy
+	return y == by && x >= bx && x < bx+lipgloss.Width(m.lang.tr(takeButton))
+}
+
+// boardBlock is the board in its frame with the take button below it, if
+// the player uses the mouse.
+func (m model) boardBlock() string {
+	board := m.frame(strings.TrimSuffix(m.boardView(), "\n"))
+	if !m.mouse() {
+		return board
+	}
+	style := m.theme.dim
+	if _, ok := m.board.Selection(); ok {
+		style = m.theme.accent
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, board, "", "  "+style.Render(m.lang.tr(takeButton)))
+}
+
+// boardOrigin is where the top left corner of the board is on the screen.
+func (m model) boardOrigin() (x, y int) {
+	// the view starts with an empty line unless it is compact and is
+	// indented by two, the board is centered like in center, with the moves
+	// to its right on wide screens, and the board is inside its frame
+	x, y = m.frameOffset()
+	x += 2
+	y += strings.Count(m.header(), "\n")
+	if !m.compact() {
+		y++
+	}
+	if gap := m.contentWidth() - lipgloss.Width(m.mainBlock()); gap > 0 {
+		x += gap / 2
+	}
+	return x, y
+}
+
+func (m model) styledView() string {
+	start := time.Now()
+	defer func() {
+		if end := time.Now(); end.Sub(start) > slowRender {
+			m.trace.record("render", start, end, attr{"width", m.width}, attr{"height", m.height})
+		}
+	}()
+
+	if m.splash {
+		return m.splashView()
+	}
+	if width, height := m.minSize(); m.width < width || m.height < height {
+		msg := m.lang.trf("Please enlarge your terminal to at least %dx%d, it is %dx%d.", width, height, m.width, m.height)
+		return m.theme.warn.Render(wordwrap.String(msg, m.width))
+	}
+	blocks := []string{strings.TrimSuffix(m.header(), "\n")}
+	if !m.compact() {
+		blocks = append([]string{""}, blocks...)
+	}
+	switch {
+	case m.rules != nil:
+		blocks = append(blocks, inset.Render(m.rules.View()), "", inset.Render(rulesFooter(*m.rules, m.theme, m.lang, m.keys)))
+	case m.terms != "":
+		blocks = append(blocks, inset.Render(m.termsView()))
+	case m.name != nil:
+		blocks = append(blocks, inset.Render(m.name.view(m.theme, m.lang, m.user)))
+	case m.settings != nil:
+		// scroll the settings if they don't all fit, keeping an empty line
+		// above the status bar
+		rows := m.height - m.heightFor(m.header()) + minSettingsRows - 1
+		blocks = append(blocks, inset.Render(strings.TrimSuffix(m.settings.view(m.theme, m.lang, m.keys, m.key != "", rows), "\n")))
+	case m.confirmQuit:
+		blocks = append(blocks, m.center(strings.TrimSuffix(m.quitDialog(), "\n")))
+	case m.showSummary:
+		blocks = append(blocks, m.center(strings.TrimSuffix(m.summaryView(), "\n")))
+	default:
+		blocks = append(blocks, m.center(m.mainBlock()))
+	}
+	bottom := lipgloss.JoinVertical(lipgloss.Left,
+		m.statusBar(m.contentWidth()),
+		"",
+		m.center(m.helpFooter()),
+		lipgloss.PlaceHorizontal(m.contentWidth(), lipgloss.Right, m.theme.dim.Render(versionString())),
+	)
+	if m.compact() {
+		bottom = lipgloss.JoinVertical(lipgloss.Left, m.statusBar(m.contentWidth()), m.center(m.helpFooter()))
+	}
+	// the content stays at the top and the status bar and the help at the
+	// bottom of the screen
+	top := lipgloss.PlaceVertical(m.height-2-lipgloss.Height(bottom), lipgloss.Top, strings.Join(blocks, "\n"))
+	// lines that are still too wide are cut off instead of wrapping around
+	screen := lipgloss.NewStyle().PaddingLeft(2).MaxWidth(m.width).Render(lipgloss.JoinVertical(lipgloss.Left, top, bottom))
+	if m.showHelp {
+		screen = overlay(screen, m.helpBox(), m.width, m.theme.dim.Copy().Faint(true))
+	} else if m.showReplayQR() {
+		screen = overlay(screen, m.replayQRBox(), m.width, m.theme.dim.Copy().Faint(true))
+	}
+	if len(m.toasts) > 0 {
+		screen = overlayCorner(screen, m.toastsView(), m.width)
+	}
+	return screen
+}
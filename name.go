@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// minNameLength and maxNameLength bound the length of display names in
+// characters.
+const (
+	minNameLength = 2
+	maxNameLength = 16
+)
+
+var (
+	errNameLength     = errors.New("a name has 2 to 16 characters")
+	errNameCharacters = errors.New("a name has letters a-z, digits, - and _")
+	errNameTaken      = errors.New("this name belongs to another player")
+)
+
+// validName checks a display name chosen by the player with key, empty for
+// guests. Names are plain ASCII, so that they can't look like another one,
+// and can't be the name or the login of another player in any case.
+func validName(name, key string) error {
+	if n := len(name); n < minNameLength || n > maxNameLength {
+		return errNameLength
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return errNameCharacters
+		}
+	}
+	if prefs.nameTaken(name, key) || players.nameTaken(name, key) {
+		return errNameTaken
+	}
+	return nil
+}
+
+// displayName is what the player with settings p is called on the screen
+// and in games, login if they didn't choose a name.
+func displayName(p userPrefs, login string) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return login
+}
+
+func (m model) displayName() string {
+	return displayName(m.prefs, m.user)
+}
+
+// namePrompt asks a player for the name that is shown instead of their
+// login.
+type namePrompt struct {
+	input textinput.Model
+	err   error
+}
+
+func newNamePrompt(login string) *namePrompt {
+	input := textinput.New()
+	input.Placeholder = login
+	input.CharLimit = maxNameLength
+	input.SetCursorMode(textinput.CursorStatic)
+	input.Focus()
+	return &namePrompt{input: input}
+}
+
+// update handles a key on the prompt of the player with key. done is set
+// once the player chose name, which is login if they kept it. The login is
+// checked like any other name, it may belong to another player.
+func (p namePrompt) update(msg tea.KeyMsg, login, key string) (prompt namePrompt, name string, done bool) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyEnter:
+		name = strings.TrimSpace(p.input.Value())
+		if name == "" || msg.Type == tea.KeyEsc {
+			name = login
+		}
+		if p.err = validName(name, key); p.err != nil {
+			return p, "", false
+		}
+		return p, name, true
+	}
+	p.input, _ = p.input.Update(msg)
+	p.err = nil
+	return p, "", false
+}
+
+// view shows the prompt in language l.
+func (p namePrompt) view(t theme, l language, login string) string {
+	s := t.title.Render(l.tr("Choose your name")) + "\n\n" +
+		t.text.Render(l.tr("Other players see it instead of your login.")) + "\n\n" +
+		p.input.View() + "\n\n"
+	if p.err != nil {
+		s += t.warn.Render(l.tr(p.err.Error()))
+	}
+	return s + "\n" + t.dim.Render(l.trf("enter confirms • esc keeps %s", login))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jheuel/nimm/variant"
+)
+
+func TestChosenNameInGame(t *testing.T) {
+	g := games.create(variant.MustGet(variant.Default))
+	g.seat(1, "alice", keyPrefix+"alice")
+	g.seat(2, "bob", keyPrefix+"bob")
+	updates, stop, err := g.watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	alice := newModel(g, "xterm", 80, 24)
+	alice.user, alice.key, alice.owner, alice.you = "alice", keyPrefix+"alice", keyPrefix+"alice", 1
+	alice.name = newNamePrompt("alice")
+	bob := newModel(g, "xterm", 80, 24)
+	bob.user, bob.key, bob.owner, bob.you = "bob", keyPrefix+"bob", keyPrefix+"bob", 2
+
+	var m tea.Model = alice
+	for _, msg := range []tea.KeyMsg{{Type: tea.KeyRunes, Runes: []rune("Alicia")}, {Type: tea.KeyEnter}} {
+		m, _ = m.Update(msg)
+	}
+	if got := m.(model).displayName(); got != "Alicia" {
+		t.Fatalf("alice is called %q, want Alicia", got)
+	}
+	if got := g.state().players; got != [2]string{"Alicia", "bob"} {
+		t.Errorf("players = %q, want the chosen name", got)
+	}
+
+	// the other session gets the name with the update
+	var state gameState
+	select {
+	case state = <-updates:
+	case <-time.After(time.Second):
+		t.Fatal("the other session wasn't told about the name")
+	}
+	m, _ = bob.Update(gameUpdateMsg(state))
+	if got := m.(model).statusBar(80); !strings.Contains(got, "vs Alicia") {
+		t.Errorf("status bar of bob = %q, want the chosen name", got)
+	}
+}
+
+func TestKeepInvalidLogin(t *testing.T) {
+	p := *newNamePrompt("first.last")
+	p, name, done := p.update(tea.KeyMsg{Type: tea.KeyEsc}, "first.last", keyPrefix+"first")
+	if done || p.err != errNameCharacters {
+		t.Errorf("keeping an invalid login = %q, %v, %v, want %v", name, done, p.err, errNameCharacters)
+	}
+}
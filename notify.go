@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// events that can be announced in a chat channel
+const (
+	// eventTopGame is a finished game between two top players.
+	eventTopGame = "top_game"
+	// eventNewLeader is a new player at the top of the leaderboard.
+	eventNewLeader = "new_leader"
+	// eventGameFinished is any finished game with a known player.
+	eventGameFinished = "game_finished"
+)
+
+// eventFilter lists the events to announce somewhere, all if it is empty.
+type eventFilter []string
+
+func (f eventFilter) wants(event string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, e := range f {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyTimeout bounds how long posting to a chat webhook may take.
+const notifyTimeout = 10 * time.Second
+
+// chatWebhook posts announcements to a Discord or Slack channel.
+type chatWebhook struct {
+	URL string `json:"url"`
+	// Kind is "discord" or "slack".
+	Kind string `json:"kind"`
+	// Events are the events to announce, see eventFilter.
+	Events eventFilter `json:"events"`
+}
+
+// payload is the message in the format of h's chat service.
+func (h chatWebhook) payload(text string) ([]byte, error) {
+	switch h.Kind {
+	case "discord":
+		return json.Marshal(struct {
+			Content string `json:"content"`
+		}{text})
+	case "slack":
+		return json.Marshal(struct {
+			Text string `json:"text"`
+		}{text})
+	}
+	return nil, fmt.Errorf("unknown webhook kind %q", h.Kind)
+}
+
+var notifyClient = &http.Client{Timeout: notifyTimeout}
+
+// notify announces text on all chat webhooks, the Matrix room and the IRC
+// channel that want event. It doesn't wait for the messages to be sent.
+func notify(event, text string) {
+	c := currentConfig()
+	if c.Matrix != nil && c.Matrix.Events.wants(event) {
+		go func(m matrixConfig) {
+			if err := postMatrix(m, text); err != nil {
+				log.Printf("Could not post %s to Matrix: %v", event, err)
+			}
+		}(*c.Matrix)
+	}
+	if irc != nil && c.IRC != nil && c.IRC.Events.wants(event) {
+		irc.say(text)
+	}
+	for _, h := range c.ChatWebhooks {
+		if !h.Events.wants(event) {
+			continue
+		}
+		go func(h chatWebhook) {
+			if err := postChat(h, text); err != nil {
+				log.Printf("Could not post %s to %s webhook: %v", event, h.Kind, err)
+			}
+		}(h)
+	}
+}
+
+func postChat(h chatWebhook, text string) error {
+	b, err := h.payload(text)
+	if err != nil {
+		return err
+	}
+	resp, err := notifyClient.Post(h.URL, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+const (
+	defaultMaxOutputBuffer = 256 << 10
+	defaultMaxFPS          = 30
+
+	// outputDrainTimeout bounds how long we wait for the last output of a
+	// session to be sent before it is closed.
+	outputDrainTimeout = 2 * time.Second
+)
+
+// throttledWriter decouples a program from a possibly slow client. Writes
+// never block: they are queued and sent by a separate goroutine at most fps
+// times per second. If the client falls behind by more than max bytes, the
+// queue is dropped and the program is asked to repaint the whole screen once
+// the client has caught up, so memory stays bounded.
+type throttledWriter struct {
+	w        io.Writer
+	max      int
+	interval time.Duration
+
+	mu      sync.Mutex
+	pending []byte
+	dropped bool
+	closed  bool
+	p       *tea.Program
+	wake    chan struct{}
+	done    chan struct{}
+}
+
+func newThrottledWriter(w io.Writer, max, fps int) *throttledWriter {
+	if max <= 0 {
+		max = defaultMaxOutputBuffer
+	}
+	if fps <= 0 {
+		fps = defaultMaxFPS
+	}
+	t := &throttledWriter{
+		w:        w,
+		max:      max,
+		interval: time.Second / time.Duration(fps),
+		wake:     make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// setProgram sets the program that is asked to repaint after output had to
+// be dropped.
+func (t *throttledWriter) setProgram(p *tea.Program) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.p = p
+}
+
+func (t *throttledWriter) Write(b []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return 0, io.ErrClosedPipe
+	}
+	if len(t.pending)+len(b) > t.max {
+		t.pending = nil
+		t.dropped = true
+	} else {
+		t.pending = append(t.pending, b...)
+	}
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	return len(b), nil
+}
+
+// queued returns how many bytes wait to be sent.
+func (t *throttledWriter) queued() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending)
+}
+
+func (t *throttledWriter) run() {
+	defer close(t.done)
+	for range t.wake {
+		t.mu.Lock()
+		out := t.pending
+		t.pending = nil
+		repaint := t.dropped && len(out) == 0
+		if repaint {
+			t.dropped = false
+		}
+		p, closed := t.p, t.closed
+		t.mu.Unlock()
+
+		if len(out) > 0 {
+			if _, err := t.w.Write(out); err != nil {
+				return
+			}
+		}
+		if repaint && p != nil && !closed {
+			// the client has caught up, redraw everything it missed
+			go p.Send(tea.ClearScreen())
+		}
+		if closed {
+			t.mu.Lock()
+			empty := len(t.pending) == 0
+			t.mu.Unlock()
+			if empty {
+				return
+			}
+		}
+		time.Sleep(t.interval)
+
+		// look again in case output was dropped without a new write
+		t.mu.Lock()
+		again := len(t.pending) > 0 || t.dropped
+		t.mu.Unlock()
+		if again {
+			select {
+			case t.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// close sends the remaining output, waiting at most outputDrainTimeout.
+func (t *throttledWriter) close() {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+	t.closed = true
+	t.mu.Unlock()
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	select {
+	case <-t.done:
+	case <-time.After(outputDrainTimeout):
+	}
+}
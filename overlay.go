@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/truncate"
+)
+
+// ansiReset ends all styles, e.g. of a line that was cut off.
+const ansiReset = "\x1b[0m"
+
+// overlay draws fg centered on top of the screen bg, whose styles are
+// replaced by style, e.g. to dim what is behind a dialog.
+func overlay(bg, fg string, width int, style lipgloss.Style) string {
+	bgLines := strings.Split(stripStyles(bg), "\n")
+	fgLines := strings.Split(fg, "\n")
+	fgWidth := lipgloss.Width(fg)
+	x, y := (width-fgWidth)/2, (len(bgLines)-len(fgLines))/2
+	if x < 0 {
+		x = 0
+	}
+	if y < 0 {
+		y = 0
+	}
+	for len(bgLines) < y+len(fgLines) {
+		bgLines = append(bgLines, "")
+	}
+	for i, line := range bgLines {
+		if i < y || i >= y+len(fgLines) {
+			bgLines[i] = style.Render(line)
+			continue
+		}
+		left, rest := cutColumns(line, x)
+		_, right := cutColumns(rest, fgWidth)
+		fgLine := fgLines[i-y]
+		fgLine += strings.Repeat(" ", fgWidth-lipgloss.Width(fgLine))
+		bgLines[i] = style.Render(left) + fgLine + style.Render(right)
+	}
+	return strings.Join(bgLines, "\n")
+}
+
+// cutColumns splits the plain text s after n columns. The first part is
+// padded with spaces if s is shorter. A wide character that is cut in half
+// is replaced by spaces.
+func cutColumns(s string, n int) (string, string) {
+	w := 0
+	for i, r := range s {
+		if w >= n {
+			return s[:i], s[i:]
+		}
+		rw := lipgloss.Width(string(r))
+		if w+rw > n {
+			// both parts keep their half as a space
+			return s[:i] + strings.Repeat(" ", n-w), strings.Repeat(" ", w+rw-n) + s[i+len(string(r)):]
+		}
+		w += rw
+	}
+	return s + strings.Repeat(" ", n-w), ""
+}
+
+// overlayCorner draws fg in the top right corner of the screen bg, below
+// the first line and two columns from the right edge. Unlike overlay, the
+// styles of bg are kept.
+func overlayCorner(bg, fg string, width int) string {
+	bgLines := strings.Split(bg, "\n")
+	fgLines := strings.Split(fg, "\n")
+	fgWidth := lipgloss.Width(fg)
+	x := width - 2 - fgWidth
+	if x < 0 {
+		x = 0
+	}
+	for i, fgLine := range fgLines {
+		y := 1 + i
+		if y >= len(bgLines) {
+			break
+		}
+		// right of the toast there only is the empty margin
+		left := truncate.String(bgLines[y], uint(x))
+		left += ansiReset + strings.Repeat(" ", x-lipgloss.Width(left))
+		fgLine += strings.Repeat(" ", fgWidth-lipgloss.Width(fgLine))
+		if x+fgWidth < width {
+			fgLine += strings.Repeat(" ", width-x-fgWidth)
+		}
+		bgLines[y] = left + fgLine
+	}
+	return strings.Join(bgLines, "\n")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// remarkKind is what a personality remarks on.
+type remarkKind int
+
+const (
+	remarkGreeting remarkKind = iota
+	remarkPlayerMove
+	remarkOwnMove
+	remarkWon
+	remarkLost
+)
+
+// remarkChance is how likely a personality comments on a move. The greeting
+// and the end of the game are always commented on.
+const remarkChance = 0.3
+
+// personality is a computer opponent with a character, chosen with `vs` by
+// its name or its difficulty.
+type personality struct {
+	name       string
+	difficulty string
+	// skill is how likely it makes the best move instead of a random one
+	skill float64
+	// avatar is its face, in ASCII so that every terminal shows it
+	avatar []string
+	// remarks are what it may say when something happens
+	remarks map[remarkKind][]string
+}
+
+// personalities are the opponents to choose from, from easy to hard.
+var personalities = []personality{
+	{
+		name:       "Rusty",
+		difficulty: "easy",
+		skill:      0.3,
+		avatar:     []string{"[o_o]", "/|_|\\", " d b "},
+		remarks: map[remarkKind][]string{
+			remarkGreeting:   {"Beep boop. Let's play!"},
+			remarkPlayerMove: {"Bold choice!", "Hmm, interesting...", "Did you mean to do that?"},
+			remarkOwnMove:    {"I think that was good?", "Oops, wrong row."},
+			remarkWon:        {"I won? I won!"},
+			remarkLost:       {"Well played, human."},
+		},
+	},
+	{
+		name:       "Ada",
+		difficulty: "medium",
+		skill:      0.7,
+		avatar:     []string{"(^_^)", "/) )\\", " / \\ "},
+		remarks: map[remarkKind][]string{
+			remarkGreeting:   {"Hello! May the better player win."},
+			remarkPlayerMove: {"Nice one.", "Bold choice!", "I see what you're doing."},
+			remarkOwnMove:    {"Your move.", "Let's see how you handle this."},
+			remarkWon:        {"Good game!"},
+			remarkLost:       {"You got me this time."},
+		},
+	},
+	{
+		name:       "Zed",
+		difficulty: "hard",
+		skill:      1,
+		avatar:     []string{"|-_-|", "/|=|\\", "_| |_"},
+		remarks: map[remarkKind][]string{
+			remarkGreeting:   {"I have never lost a game."},
+			remarkPlayerMove: {"Predictable.", "Bold choice!", "As expected."},
+			remarkOwnMove:    {"Every position has an answer.", "Your options are shrinking."},
+			remarkWon:        {"As calculated."},
+			remarkLost:       {"Impossible. Recalculating..."},
+		},
+	},
+}
+
+// findPersonality returns the personality called name or playing at the
+// difficulty name.
+func findPersonality(name string) (personality, bool) {
+	for _, p := range personalities {
+		if strings.EqualFold(name, p.name) || strings.EqualFold(name, p.difficulty) {
+			return p, true
+		}
+	}
+	return personality{}, false
+}
+
+// personalityEngine plays like its personality.
+type personalityEngine struct {
+	personality
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+func newPersonalityEngine(p personality) *personalityEngine {
+	return &personalityEngine{personality: p, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (e *personalityEngine) name() string { return e.personality.name }
+
+func (e *personalityEngine) bestMove(field [][]bool) (move, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return skilledMove(e.rand, e.skill, field)
+}
+
+func (e *personalityEngine) close() {}
+
+// remark is what the personality says about kind, empty if it keeps quiet.
+func (e *personalityEngine) remark(kind remarkKind) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	texts := e.remarks[kind]
+	if len(texts) == 0 || (kind == remarkPlayerMove || kind == remarkOwnMove) && e.rand.Float64() >= remarkChance {
+		return ""
+	}
+	return texts[e.rand.Intn(len(texts))]
+}
+
+// remarkOnMove lets the opponent of the player remark on the last move.
+func (m *model) remarkOnMove() tea.Cmd {
+	n := len(m.state.history)
+	switch {
+	case n == 0 || m.you == 0:
+		return nil
+	case m.state.finished() && m.state.winner() == m.you:
+		return m.say(remarkLost)
+	case m.state.finished():
+		return m.say(remarkWon)
+	case m.state.history[n-1].Player == m.you:
+		return m.say(remarkPlayerMove)
+	}
+	return m.say(remarkOwnMove)
+}
+
+// say lets the opponent remark on kind if it is a personality. Remarks are
+// shown next to its avatar, or in a toast on narrow screens.
+func (m *model) say(kind remarkKind) tea.Cmd {
+	p, ok := m.opponent.(*personalityEngine)
+	if !ok {
+		return nil
+	}
+	text := p.remark(kind)
+	if text == "" {
+		return nil
+	}
+	m.said = text
+	if m.sidePanel() {
+		return nil
+	}
+	return m.notify(p.name()+": "+m.lang.tr(text), toastDuration)
+}
+
+// opponentView is the avatar of the opponent with its name and what it said
+// last, above the moves, empty unless it is a personality.
+func (m model) opponentView() string {
+	p, ok := m.opponent.(*personalityEngine)
+	if !ok {
+		return ""
+	}
+	t, l := m.theme, m.lang
+	avatar := t.text.Render(strings.Join(p.avatar, "\n"))
+	lines := []string{t.title.Render(p.name()) + " " + t.dim.Render("("+l.tr(p.difficulty)+")")}
+	if m.said != "" {
+		width := sidePanelWidth - lipgloss.Width(avatar) - 2
+		lines = append(lines, t.text.Render(wordwrap.String("\""+l.tr(m.said)+"\"", width)))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, avatar, "  ", strings.Join(lines, "\n"))
+}
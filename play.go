@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/variant"
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+// play runs a game directly on the local terminal, without the SSH server.
+func play(args []string) {
+	fs := flag.NewFlagSet("play", flag.ExitOnError)
+	name := fs.String("variant", variant.Default, "the game to play, one of "+strings.Join(variant.Names(), ", "))
+	scrollback := fs.Bool("scrollback", false, "leave the game in the terminal after quitting instead of using the alternate screen")
+	_ = fs.Parse(args)
+	v, err := variant.Get(*name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	m := newModel(games.create(v), os.Getenv("TERM"), width, height)
+	m.light = !lipgloss.HasDarkBackground()
+	m.noColor = detectColorProfile(m.term, os.Environ()) == termenv.Ascii
+	m.envLang = languageFromEnv(os.Environ())
+	m.envLocale = localeFromEnv(os.Environ())
+	m.prefs.Scrollback = *scrollback
+	m.setPrefs(m.prefs)
+	var opts []tea.ProgramOption
+	if !*scrollback {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	if _, err := tea.NewProgram(m, opts...).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
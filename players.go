@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// playersFile is where the results of all players are kept between restarts.
+const playersFile = "players.json"
+
+// topPlayers is how many players at the top of the leaderboard count as top
+// players for announcements.
+const topPlayers = 10
+
+var errNoPlayer = errors.New("no such player")
+
+// playerStats are the results of one player.
+type playerStats struct {
+	Name       string    `json:"name"`
+	Wins       int       `json:"wins"`
+	Losses     int       `json:"losses"`
+	LastPlayed time.Time `json:"last_played"`
+	// Streak is the number of days in a row up to LastPlayed on which the
+	// player finished a game.
+	Streak int `json:"streak,omitempty"`
+	// key is who the results are counted for, see playerRegistry. It is
+	// only saved.
+	key string
+}
+
+// savedPlayer is how the results of a player are saved, with their key.
+type savedPlayer struct {
+	playerStats
+	Key string `json:"key,omitempty"`
+}
+
+// startOfDay is midnight before t in the time zone of the server.
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// playedOn counts a finished game at t for the streak.
+func (p *playerStats) playedOn(t time.Time) {
+	last, today := startOfDay(p.LastPlayed), startOfDay(t)
+	switch {
+	case p.Streak > 0 && last.Equal(today):
+	case p.Streak > 0 && last.Equal(today.AddDate(0, 0, -1)):
+		p.Streak++
+	default:
+		p.Streak = 1
+	}
+	p.LastPlayed = t
+}
+
+// streakAt is the streak of the player at t, and whether they already
+// finished a game on that day. A streak ends once a day without a game
+// passed.
+func (p playerStats) streakAt(t time.Time) (days int, today bool) {
+	last, day := startOfDay(p.LastPlayed), startOfDay(t)
+	switch {
+	case last.Equal(day):
+		return p.Streak, true
+	case last.Equal(day.AddDate(0, 0, -1)):
+		return p.Streak, false
+	}
+	return 0, false
+}
+
+// playerRegistry holds the results of all players by the key they play
+// with: the fingerprint of their login key, or the tokenOwner of API
+// clients. Anyone can log in under any name, so guests have no results.
+type playerRegistry struct {
+	mu      sync.Mutex
+	players map[string]*playerStats
+}
+
+var players = &playerRegistry{players: map[string]*playerStats{}}
+
+// record counts the result of a finished game for all known players in it,
+// saves the results and announces the game if it changed the leaderboard or
+// was between top players.
+func (r *playerRegistry) record(state gameState) {
+	winner := state.winner()
+	if winner == 0 {
+		return
+	}
+	r.mu.Lock()
+	before := r.rankedLocked()
+	top := state.players[0] != "" && state.players[1] != ""
+	for _, name := range state.players {
+		if place := rank(before, name); place == 0 || place > topPlayers {
+			top = false
+		}
+	}
+	var registered []playerStats
+	counted := false
+	for i, name := range state.players {
+		key := state.owners[i]
+		if name == "" || !hasResults(key) {
+			continue
+		}
+		counted = true
+		p, ok := r.players[key]
+		if !ok {
+			p = &playerStats{Name: name, key: key}
+			r.players[key] = p
+			registered = append(registered, *p)
+		}
+		// the player may have chosen another name since
+		p.Name = name
+		if i+1 == winner {
+			p.Wins++
+		} else {
+			p.Losses++
+		}
+		p.playedOn(time.Now())
+	}
+	after := r.rankedLocked()
+	r.mu.Unlock()
+
+	if counted {
+		// a crash shouldn't cost the results since the last shutdown
+		if err := savePlayers(playersFile); err != nil {
+			log.Printf("Could not save players: %v", err)
+		}
+	}
+	for _, p := range registered {
+		fireEvent(hookPlayerRegistered, p)
+	}
+
+	winnerName, loserName := state.players[winner-1], state.players[winner%2]
+	if winnerName == "" {
+		winnerName = "The computer"
+	}
+	if loserName == "" {
+		loserName = "the computer"
+	}
+	notify(eventGameFinished, fmt.Sprintf("%s beat %s in game %s after %d moves.", winnerName, loserName, state.id, state.moves))
+	if top {
+		notify(eventTopGame, fmt.Sprintf("Top game: %s beat %s in game %s after %d moves.", winnerName, loserName, state.id, state.moves))
+	}
+	if len(after) > 0 && after[0].Wins > 0 && (len(before) == 0 || before[0].Name != after[0].Name) {
+		notify(eventNewLeader, fmt.Sprintf("%s is the new number one with %d wins.", after[0].Name, after[0].Wins))
+	}
+}
+
+// hasResults reports whether results are counted for the seats of owner,
+// see playerRegistry.
+func hasResults(owner string) bool {
+	return strings.HasPrefix(owner, keyPrefix) || strings.HasPrefix(owner, tokenOwner(""))
+}
+
+// get returns the results of the player with key.
+func (r *playerRegistry) get(key string) (playerStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.players[key]
+	if !ok {
+		return playerStats{}, errNoPlayer
+	}
+	return *p, nil
+}
+
+// named returns the results of the player called name.
+func (r *playerRegistry) named(name string) (playerStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.players {
+		if p.Name == name {
+			return *p, nil
+		}
+	}
+	return playerStats{}, errNoPlayer
+}
+
+// remove forgets the results of the player with key and reports whether
+// there were any.
+func (r *playerRegistry) remove(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.players[key]
+	delete(r.players, key)
+	return ok
+}
+
+// nameTaken reports whether a player other than the one with key has
+// results under name, in any case.
+func (r *playerRegistry) nameTaken(name, key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, p := range r.players {
+		if k != key && strings.EqualFold(p.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// leaderboard returns the n players with the most wins.
+func (r *playerRegistry) leaderboard(n int) []playerStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	all := r.rankedLocked()
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// rankedLocked returns all players, most wins first.
+func (r *playerRegistry) rankedLocked() []playerStats {
+	all := make([]playerStats, 0, len(r.players))
+	for _, p := range r.players {
+		all = append(all, *p)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Wins != all[j].Wins {
+			return all[i].Wins > all[j].Wins
+		}
+		if all[i].Losses != all[j].Losses {
+			return all[i].Losses < all[j].Losses
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// rank returns the place of name in ranked, starting at 1, or 0 if name has
+// no results.
+func rank(ranked []playerStats, name string) int {
+	for i, p := range ranked {
+		if p.Name == name {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// savePlayersMu keeps saves from writing the file at the same time.
+var savePlayersMu sync.Mutex
+
+// savePlayers writes the results of all players to path.
+func savePlayers(path string) error {
+	savePlayersMu.Lock()
+	defer savePlayersMu.Unlock()
+	players.mu.Lock()
+	all := make([]savedPlayer, 0, len(players.players))
+	for _, p := range players.players {
+		all = append(all, savedPlayer{*p, p.key})
+	}
+	players.mu.Unlock()
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	b, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// loadPlayers reads the results saved in path, if there are any.
+func loadPlayers(path string) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("Could not load players: %v", err)
+		return
+	}
+	var all []savedPlayer
+	if err := json.Unmarshal(b, &all); err != nil {
+		log.Printf("Could not load players: %v", err)
+		return
+	}
+	players.mu.Lock()
+	defer players.mu.Unlock()
+	dropped := 0
+	for i := range all {
+		p := all[i].playerStats
+		p.key = all[i].Key
+		if !hasResults(p.key) {
+			// counted under a login by older versions
+			dropped++
+			continue
+		}
+		players.players[p.key] = &p
+	}
+	if dropped > 0 {
+		log.Printf("Dropped the results of %d players without a key", dropped)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// prefsFile is where the settings of all players are kept.
+const prefsFile = "prefs.json"
+
+// keyPrefix starts the fingerprints the settings are kept under, see
+// sessionKey. Anyone can log in under any name, so settings saved under
+// logins by older versions are dropped.
+const keyPrefix = "SHA256:"
+
+// userPrefs are the settings of one player.
+type userPrefs struct {
+	// Name is shown instead of the login, asked for on the first visit.
+	Name string `json:"name,omitempty"`
+	// Colors overrides the detected color profile, see parseColorProfile.
+	Colors string `json:"colors,omitempty"`
+	// Theme is the name of the color scheme, the default one if empty.
+	Theme string `json:"theme,omitempty"`
+	// Background is "light" or "dark", detected if empty.
+	Background string `json:"background,omitempty"`
+	// Display is "styled", "ascii" for plain characters without any
+	// styling or "reader" to describe the board in words for screen
+	// readers, detected from the terminal if empty.
+	Display string `json:"display,omitempty"`
+	// Bell rings the terminal bell when it is the player's turn.
+	Bell bool `json:"bell,omitempty"`
+	// Flash inverts the screen for a moment when it is the player's turn.
+	Flash bool `json:"flash,omitempty"`
+	// Selection is how objects are picked, "range" if empty or "quick".
+	Selection string `json:"selection,omitempty"`
+	// Glyph is drawn for objects, the mark of the variant if empty.
+	Glyph string `json:"glyph,omitempty"`
+	// Variant is played when the player doesn't ask for one.
+	Variant string `json:"variant,omitempty"`
+	// Labels shows the coordinates of the cells around the board.
+	Labels bool `json:"labels,omitempty"`
+	// Counts shows how many objects are left at the end of each row.
+	Counts bool `json:"counts,omitempty"`
+	// Clock shows how long both players have been thinking.
+	Clock bool `json:"clock,omitempty"`
+	// GameTime shows how long the game has been going on.
+	GameTime bool `json:"game_time,omitempty"`
+	// ReplayQR shows a QR code of the web replay after a game, to open it on
+	// a phone.
+	ReplayQR bool `json:"replay_qr,omitempty"`
+	// Thinking is how long computer opponents take before they move:
+	// instant, short, normal or slow. Empty means short.
+	Thinking string `json:"thinking,omitempty"`
+	// Analysis shows the nim-sum of the position and what it would be after
+	// the selection, to learn the strategy.
+	Analysis bool `json:"analysis,omitempty"`
+	// Keys is the name of the set of keys the player moves with, the
+	// default one if empty.
+	Keys string `json:"keys,omitempty"`
+	// TimeZone is the name of the time zone times are shown in, e.g.
+	// Europe/Berlin, taken from TZ of the client if empty.
+	TimeZone string `json:"time_zone,omitempty"`
+	// Language is the code of the language of the screens, e.g. "de", taken
+	// from the locale of the client if empty.
+	Language string `json:"language,omitempty"`
+	// Help is how much help is shown below the board, the short help if
+	// empty, "off" or "full".
+	Help string `json:"help,omitempty"`
+	// Scrollback draws the game in the normal screen of the terminal
+	// instead of the alternate one, so that it stays in the scrollback
+	// after the player left.
+	Scrollback bool `json:"scrollback,omitempty"`
+	// NoMouse leaves the mouse to the terminal, e.g. to copy text.
+	NoMouse bool `json:"no_mouse,omitempty"`
+	// NoFrame draws the board without the border and the title bar.
+	NoFrame bool `json:"no_frame,omitempty"`
+	// Campaign is how many levels of the campaign the player has beaten.
+	Campaign int `json:"campaign,omitempty"`
+	// AcceptedTerms is the version of the terms of the server the player
+	// accepted, see termsVersion.
+	AcceptedTerms string `json:"accepted_terms,omitempty"`
+}
+
+// prefRegistry holds the settings of all players by the fingerprint of the
+// key they log in with.
+type prefRegistry struct {
+	mu    sync.Mutex
+	prefs map[string]userPrefs
+}
+
+var prefs = &prefRegistry{prefs: map[string]userPrefs{}}
+
+// get returns the settings of key, the defaults if there are none.
+func (r *prefRegistry) get(key string) userPrefs {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.prefs[key]
+}
+
+// update changes the settings of key and saves them.
+func (r *prefRegistry) update(key string, f func(*userPrefs)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p := r.prefs[key]
+	f(&p)
+	if p == (userPrefs{}) {
+		delete(r.prefs, key)
+	} else {
+		r.prefs[key] = p
+	}
+	b, err := json.MarshalIndent(r.prefs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(prefsFile, b, 0o644)
+}
+
+// nameTaken reports whether a player other than the one with key chose
+// name, in any case.
+func (r *prefRegistry) nameTaken(name, key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, p := range r.prefs {
+		if k != key && strings.EqualFold(p.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadPrefs reads the settings saved in path, if there are any.
+func loadPrefs(path string) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		log.Printf("Could not load settings: %v", err)
+		return
+	}
+	all := map[string]userPrefs{}
+	if err := json.Unmarshal(b, &all); err != nil {
+		log.Printf("Could not load settings: %v", err)
+		return
+	}
+	for key := range all {
+		if !strings.HasPrefix(key, keyPrefix) {
+			delete(all, key)
+		}
+	}
+	prefs.mu.Lock()
+	defer prefs.mu.Unlock()
+	prefs.prefs = all
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+// How client addresses are written to the logs, the traces and the
+// recordings, see config.LogAddresses.
+const (
+	addressesFull     = "full"
+	addressesTruncate = "truncate"
+	addressesHash     = "hash"
+)
+
+// addressSalt makes hashed addresses impossible to find by hashing all
+// addresses. It is new with every start of the server, so the hashes can
+// only be compared until then.
+var addressSalt = func() []byte {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}()
+
+// validateLogAddresses checks the LogAddresses of c.
+func validateLogAddresses(c config) error {
+	switch c.LogAddresses {
+	case "", addressesFull, addressesTruncate, addressesHash:
+		return nil
+	}
+	return fmt.Errorf("log_addresses: %q is not full, truncate or hash", c.LogAddresses)
+}
+
+// logAddr is the address of a client as it may be written down.
+func logAddr(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	switch currentConfig().LogAddresses {
+	case addressesTruncate:
+		return truncateAddr(a)
+	case addressesHash:
+		return hashAddr(a)
+	}
+	return a.String()
+}
+
+// truncateAddr is the network of a without the port: the first 24 bits of
+// IPv4 and the first 48 bits of IPv6 addresses.
+func truncateAddr(a net.Addr) string {
+	ip := addrIP(a)
+	switch {
+	case ip == nil:
+		return "unknown"
+	case ip.To4() != nil:
+		return ip.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String() + "/48"
+}
+
+// hashAddr is a short salted hash of the IP of a, the same for all
+// connections from it.
+func hashAddr(a net.Addr) string {
+	ip := addrIP(a)
+	if ip == nil {
+		return "unknown"
+	}
+	h := sha256.New()
+	h.Write(addressSalt)
+	h.Write(ip.To16())
+	return "ip-" + hex.EncodeToString(h.Sum(nil)[:6])
+}
+
+// addrIP is the IP of a, nil if it has none.
+func addrIP(a net.Addr) net.IP {
+	host, _, err := net.SplitHostPort(a.String())
+	if err != nil {
+		host = a.String()
+	}
+	return net.ParseIP(host)
+}
+
+// loggingMiddleware logs connects with the user, the address, whether they
+// logged in with a key, the command and the terminal, and disconnects with
+// how long the session took. Addresses are logged as configured.
+func loggingMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			start := time.Now()
+			addr := logAddr(s.RemoteAddr())
+			pty, _, _ := s.Pty()
+			log.Printf("%s connect %s %v %v %s %v %v", s.User(), addr, sessionKey(s) != "", s.Command(), pty.Term, pty.Window.Width, pty.Window.Height)
+			sh(s)
+			log.Printf("%s disconnect %s", addr, time.Since(start))
+		}
+	}
+}
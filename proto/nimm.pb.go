@@ -0,0 +1,556 @@
+// The nimm game API for clients other than the terminal UI, served on
+// grpc_addr of the config. The messages mirror the JSON of the HTTP API, so
+// clients can be written against both.
+//
+// CreateGame, JoinGame and TakeObjects need an API token of the config,
+// sent as "authorization: Bearer <token>" metadata, and play as its name.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        (unknown)
+// source: proto/nimm.proto
+
+package nimmpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type CreateGameRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// opponent is the engine to play against, e.g. "computer". Without one,
+	// the game is a challenge.
+	Opponent string `protobuf:"bytes,1,opt,name=opponent,proto3" json:"opponent,omitempty"`
+	// seat is 2 to move second.
+	Seat int32 `protobuf:"varint,2,opt,name=seat,proto3" json:"seat,omitempty"`
+	// variant is the name of the game, the default one if empty.
+	Variant string `protobuf:"bytes,3,opt,name=variant,proto3" json:"variant,omitempty"`
+}
+
+func (x *CreateGameRequest) Reset() {
+	*x = CreateGameRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_nimm_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CreateGameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateGameRequest) ProtoMessage() {}
+
+func (x *CreateGameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_nimm_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateGameRequest.ProtoReflect.Descriptor instead.
+func (*CreateGameRequest) Descriptor() ([]byte, []int) {
+	return file_proto_nimm_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *CreateGameRequest) GetOpponent() string {
+	if x != nil {
+		return x.Opponent
+	}
+	return ""
+}
+
+func (x *CreateGameRequest) GetSeat() int32 {
+	if x != nil {
+		return x.Seat
+	}
+	return 0
+}
+
+func (x *CreateGameRequest) GetVariant() string {
+	if x != nil {
+		return x.Variant
+	}
+	return ""
+}
+
+type GetGameRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Game string `protobuf:"bytes,1,opt,name=game,proto3" json:"game,omitempty"`
+}
+
+func (x *GetGameRequest) Reset() {
+	*x = GetGameRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_nimm_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetGameRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetGameRequest) ProtoMessage() {}
+
+func (x *GetGameRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_nimm_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetGameRequest.ProtoReflect.Descriptor instead.
+func (*GetGameRequest) Descriptor() ([]byte, []int) {
+	return file_proto_nimm_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *GetGameRequest) GetGame() string {
+	if x != nil {
+		return x.Game
+	}
+	return ""
+}
+
+type TakeObjectsRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Game string `protobuf:"bytes,1,opt,name=game,proto3" json:"game,omitempty"`
+	// row and the inclusive column range from..to of the objects to take.
+	// Empty cells in between are skipped.
+	Row  int32 `protobuf:"varint,2,opt,name=row,proto3" json:"row,omitempty"`
+	From int32 `protobuf:"varint,3,opt,name=from,proto3" json:"from,omitempty"`
+	To   int32 `protobuf:"varint,4,opt,name=to,proto3" json:"to,omitempty"`
+	// moves is the number of moves the client has seen, so that a move based
+	// on an outdated state is rejected.
+	Moves int32 `protobuf:"varint,5,opt,name=moves,proto3" json:"moves,omitempty"`
+}
+
+func (x *TakeObjectsRequest) Reset() {
+	*x = TakeObjectsRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_nimm_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TakeObjectsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TakeObjectsRequest) ProtoMessage() {}
+
+func (x *TakeObjectsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_nimm_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TakeObjectsRequest.ProtoReflect.Descriptor instead.
+func (*TakeObjectsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_nimm_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *TakeObjectsRequest) GetGame() string {
+	if x != nil {
+		return x.Game
+	}
+	return ""
+}
+
+func (x *TakeObjectsRequest) GetRow() int32 {
+	if x != nil {
+		return x.Row
+	}
+	return 0
+}
+
+func (x *TakeObjectsRequest) GetFrom() int32 {
+	if x != nil {
+		return x.From
+	}
+	return 0
+}
+
+func (x *TakeObjectsRequest) GetTo() int32 {
+	if x != nil {
+		return x.To
+	}
+	return 0
+}
+
+func (x *TakeObjectsRequest) GetMoves() int32 {
+	if x != nil {
+		return x.Moves
+	}
+	return 0
+}
+
+type Row struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Objects []bool `protobuf:"varint,1,rep,packed,name=objects,proto3" json:"objects,omitempty"`
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_nimm_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_nimm_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_proto_nimm_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Row) GetObjects() []bool {
+	if x != nil {
+		return x.Objects
+	}
+	return nil
+}
+
+type GameState struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Game  string `protobuf:"bytes,1,opt,name=game,proto3" json:"game,omitempty"`
+	Field []*Row `protobuf:"bytes,2,rep,name=field,proto3" json:"field,omitempty"`
+	// player is the player to move, 1 or 2.
+	Player   int32 `protobuf:"varint,3,opt,name=player,proto3" json:"player,omitempty"`
+	Moves    int32 `protobuf:"varint,4,opt,name=moves,proto3" json:"moves,omitempty"`
+	Finished bool  `protobuf:"varint,5,opt,name=finished,proto3" json:"finished,omitempty"`
+	// winner is set once the game is finished.
+	Winner int32 `protobuf:"varint,6,opt,name=winner,proto3" json:"winner,omitempty"`
+	// players are the names of the players in seats 1 and 2, empty for an
+	// engine or a free seat.
+	Players []string `protobuf:"bytes,7,rep,name=players,proto3" json:"players,omitempty"`
+}
+
+func (x *GameState) Reset() {
+	*x = GameState{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_nimm_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GameState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameState) ProtoMessage() {}
+
+func (x *GameState) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_nimm_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameState.ProtoReflect.Descriptor instead.
+func (*GameState) Descriptor() ([]byte, []int) {
+	return file_proto_nimm_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GameState) GetGame() string {
+	if x != nil {
+		return x.Game
+	}
+	return ""
+}
+
+func (x *GameState) GetField() []*Row {
+	if x != nil {
+		return x.Field
+	}
+	return nil
+}
+
+func (x *GameState) GetPlayer() int32 {
+	if x != nil {
+		return x.Player
+	}
+	return 0
+}
+
+func (x *GameState) GetMoves() int32 {
+	if x != nil {
+		return x.Moves
+	}
+	return 0
+}
+
+func (x *GameState) GetFinished() bool {
+	if x != nil {
+		return x.Finished
+	}
+	return false
+}
+
+func (x *GameState) GetWinner() int32 {
+	if x != nil {
+		return x.Winner
+	}
+	return 0
+}
+
+func (x *GameState) GetPlayers() []string {
+	if x != nil {
+		return x.Players
+	}
+	return nil
+}
+
+var File_proto_nimm_proto protoreflect.FileDescriptor
+
+var file_proto_nimm_proto_rawDesc = []byte{
+	0x0a, 0x10, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x12, 0x07, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x22, 0x5d, 0x0a, 0x11, 0x43,
+	0x72, 0x65, 0x61, 0x74, 0x65, 0x47, 0x61, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x1a, 0x0a, 0x08, 0x6f, 0x70, 0x70, 0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x08, 0x6f, 0x70, 0x70, 0x6f, 0x6e, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x73, 0x65, 0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x73, 0x65, 0x61, 0x74,
+	0x12, 0x18, 0x0a, 0x07, 0x76, 0x61, 0x72, 0x69, 0x61, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x76, 0x61, 0x72, 0x69, 0x61, 0x6e, 0x74, 0x22, 0x24, 0x0a, 0x0e, 0x47, 0x65,
+	0x74, 0x47, 0x61, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x67, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x67, 0x61, 0x6d, 0x65,
+	0x22, 0x74, 0x0a, 0x12, 0x54, 0x61, 0x6b, 0x65, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x67, 0x61, 0x6d, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x67, 0x61, 0x6d, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x72, 0x6f,
+	0x77, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x03, 0x72, 0x6f, 0x77, 0x12, 0x12, 0x0a, 0x04,
+	0x66, 0x72, 0x6f, 0x6d, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d,
+	0x12, 0x0e, 0x0a, 0x02, 0x74, 0x6f, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x02, 0x74, 0x6f,
+	0x12, 0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x76, 0x65, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x05, 0x6d, 0x6f, 0x76, 0x65, 0x73, 0x22, 0x1f, 0x0a, 0x03, 0x52, 0x6f, 0x77, 0x12, 0x18, 0x0a,
+	0x07, 0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x08, 0x52, 0x07,
+	0x6f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x22, 0xbf, 0x01, 0x0a, 0x09, 0x47, 0x61, 0x6d, 0x65,
+	0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x67, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x67, 0x61, 0x6d, 0x65, 0x12, 0x22, 0x0a, 0x05, 0x66, 0x69, 0x65,
+	0x6c, 0x64, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x6e, 0x69, 0x6d, 0x6d, 0x2e,
+	0x76, 0x31, 0x2e, 0x52, 0x6f, 0x77, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x16, 0x0a,
+	0x06, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x70,
+	0x6c, 0x61, 0x79, 0x65, 0x72, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x76, 0x65, 0x73, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x6d, 0x6f, 0x76, 0x65, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x66,
+	0x69, 0x6e, 0x69, 0x73, 0x68, 0x65, 0x64, 0x18, 0x05, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x66,
+	0x69, 0x6e, 0x69, 0x73, 0x68, 0x65, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x69, 0x6e, 0x6e, 0x65,
+	0x72, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x77, 0x69, 0x6e, 0x6e, 0x65, 0x72, 0x12,
+	0x18, 0x0a, 0x07, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x07, 0x70, 0x6c, 0x61, 0x79, 0x65, 0x72, 0x73, 0x32, 0xb1, 0x02, 0x0a, 0x04, 0x4e, 0x69,
+	0x6d, 0x6d, 0x12, 0x3c, 0x0a, 0x0a, 0x43, 0x72, 0x65, 0x61, 0x74, 0x65, 0x47, 0x61, 0x6d, 0x65,
+	0x12, 0x1a, 0x2e, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x72, 0x65, 0x61, 0x74,
+	0x65, 0x47, 0x61, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x6e,
+	0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x61, 0x6d, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65,
+	0x12, 0x37, 0x0a, 0x08, 0x4a, 0x6f, 0x69, 0x6e, 0x47, 0x61, 0x6d, 0x65, 0x12, 0x17, 0x2e, 0x6e,
+	0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x47, 0x61, 0x6d, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e,
+	0x47, 0x61, 0x6d, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x36, 0x0a, 0x07, 0x47, 0x65, 0x74,
+	0x47, 0x61, 0x6d, 0x65, 0x12, 0x17, 0x2e, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47,
+	0x65, 0x74, 0x47, 0x61, 0x6d, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e,
+	0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x61, 0x6d, 0x65, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x12, 0x3e, 0x0a, 0x0b, 0x54, 0x61, 0x6b, 0x65, 0x4f, 0x62, 0x6a, 0x65, 0x63, 0x74, 0x73,
+	0x12, 0x1b, 0x2e, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x61, 0x6b, 0x65, 0x4f,
+	0x62, 0x6a, 0x65, 0x63, 0x74, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e,
+	0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x61, 0x6d, 0x65, 0x53, 0x74, 0x61, 0x74,
+	0x65, 0x12, 0x3a, 0x0a, 0x09, 0x57, 0x61, 0x74, 0x63, 0x68, 0x47, 0x61, 0x6d, 0x65, 0x12, 0x17,
+	0x2e, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x47, 0x61, 0x6d, 0x65,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x12, 0x2e, 0x6e, 0x69, 0x6d, 0x6d, 0x2e, 0x76,
+	0x31, 0x2e, 0x47, 0x61, 0x6d, 0x65, 0x53, 0x74, 0x61, 0x74, 0x65, 0x30, 0x01, 0x42, 0x25, 0x5a,
+	0x23, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x6a, 0x68, 0x65, 0x75,
+	0x65, 0x6c, 0x2f, 0x6e, 0x69, 0x6d, 0x6d, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3b, 0x6e, 0x69,
+	0x6d, 0x6d, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proto_nimm_proto_rawDescOnce sync.Once
+	file_proto_nimm_proto_rawDescData = file_proto_nimm_proto_rawDesc
+)
+
+func file_proto_nimm_proto_rawDescGZIP() []byte {
+	file_proto_nimm_proto_rawDescOnce.Do(func() {
+		file_proto_nimm_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_nimm_proto_rawDescData)
+	})
+	return file_proto_nimm_proto_rawDescData
+}
+
+var file_proto_nimm_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_proto_nimm_proto_goTypes = []any{
+	(*CreateGameRequest)(nil),  // 0: nimm.v1.CreateGameRequest
+	(*GetGameRequest)(nil),     // 1: nimm.v1.GetGameRequest
+	(*TakeObjectsRequest)(nil), // 2: nimm.v1.TakeObjectsRequest
+	(*Row)(nil),                // 3: nimm.v1.Row
+	(*GameState)(nil),          // 4: nimm.v1.GameState
+}
+var file_proto_nimm_proto_depIdxs = []int32{
+	3, // 0: nimm.v1.GameState.field:type_name -> nimm.v1.Row
+	0, // 1: nimm.v1.Nimm.CreateGame:input_type -> nimm.v1.CreateGameRequest
+	1, // 2: nimm.v1.Nimm.JoinGame:input_type -> nimm.v1.GetGameRequest
+	1, // 3: nimm.v1.Nimm.GetGame:input_type -> nimm.v1.GetGameRequest
+	2, // 4: nimm.v1.Nimm.TakeObjects:input_type -> nimm.v1.TakeObjectsRequest
+	1, // 5: nimm.v1.Nimm.WatchGame:input_type -> nimm.v1.GetGameRequest
+	4, // 6: nimm.v1.Nimm.CreateGame:output_type -> nimm.v1.GameState
+	4, // 7: nimm.v1.Nimm.JoinGame:output_type -> nimm.v1.GameState
+	4, // 8: nimm.v1.Nimm.GetGame:output_type -> nimm.v1.GameState
+	4, // 9: nimm.v1.Nimm.TakeObjects:output_type -> nimm.v1.GameState
+	4, // 10: nimm.v1.Nimm.WatchGame:output_type -> nimm.v1.GameState
+	6, // [6:11] is the sub-list for method output_type
+	1, // [1:6] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_proto_nimm_proto_init() }
+func file_proto_nimm_proto_init() {
+	if File_proto_nimm_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_proto_nimm_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*CreateGameRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_nimm_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*GetGameRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_nimm_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*TakeObjectsRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_nimm_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*Row); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_nimm_proto_msgTypes[4].Exporter = func(v any, i int) any {
+			switch v := v.(*GameState); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_nimm_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_nimm_proto_goTypes,
+		DependencyIndexes: file_proto_nimm_proto_depIdxs,
+		MessageInfos:      file_proto_nimm_proto_msgTypes,
+	}.Build()
+	File_proto_nimm_proto = out.File
+	file_proto_nimm_proto_rawDesc = nil
+	file_proto_nimm_proto_goTypes = nil
+	file_proto_nimm_proto_depIdxs = nil
+}
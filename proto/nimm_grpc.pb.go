@@ -0,0 +1,308 @@
+// The nimm game API for clients other than the terminal UI, served on
+// grpc_addr of the config. The messages mirror the JSON of the HTTP API, so
+// clients can be written against both.
+//
+// CreateGame, JoinGame and TakeObjects need an API token of the config,
+// sent as "authorization: Bearer <token>" metadata, and play as its name.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: proto/nimm.proto
+
+package nimmpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Nimm_CreateGame_FullMethodName  = "/nimm.v1.Nimm/CreateGame"
+	Nimm_JoinGame_FullMethodName    = "/nimm.v1.Nimm/JoinGame"
+	Nimm_GetGame_FullMethodName     = "/nimm.v1.Nimm/GetGame"
+	Nimm_TakeObjects_FullMethodName = "/nimm.v1.Nimm/TakeObjects"
+	Nimm_WatchGame_FullMethodName   = "/nimm.v1.Nimm/WatchGame"
+)
+
+// NimmClient is the client API for Nimm service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type NimmClient interface {
+	// CreateGame starts a new game on the server, against an engine or as a
+	// challenge that another player can join.
+	CreateGame(ctx context.Context, in *CreateGameRequest, opts ...grpc.CallOption) (*GameState, error)
+	// JoinGame takes the empty seat of a challenge.
+	JoinGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*GameState, error)
+	// GetGame returns the current state of a game.
+	GetGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*GameState, error)
+	// TakeObjects makes a move on behalf of the player to move. Against an
+	// engine, the state after its reply is returned.
+	TakeObjects(ctx context.Context, in *TakeObjectsRequest, opts ...grpc.CallOption) (*GameState, error)
+	// WatchGame streams the state of a game after every move until it is
+	// finished.
+	WatchGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (Nimm_WatchGameClient, error)
+}
+
+type nimmClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNimmClient(cc grpc.ClientConnInterface) NimmClient {
+	return &nimmClient{cc}
+}
+
+func (c *nimmClient) CreateGame(ctx context.Context, in *CreateGameRequest, opts ...grpc.CallOption) (*GameState, error) {
+	out := new(GameState)
+	err := c.cc.Invoke(ctx, Nimm_CreateGame_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nimmClient) JoinGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*GameState, error) {
+	out := new(GameState)
+	err := c.cc.Invoke(ctx, Nimm_JoinGame_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nimmClient) GetGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (*GameState, error) {
+	out := new(GameState)
+	err := c.cc.Invoke(ctx, Nimm_GetGame_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nimmClient) TakeObjects(ctx context.Context, in *TakeObjectsRequest, opts ...grpc.CallOption) (*GameState, error) {
+	out := new(GameState)
+	err := c.cc.Invoke(ctx, Nimm_TakeObjects_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nimmClient) WatchGame(ctx context.Context, in *GetGameRequest, opts ...grpc.CallOption) (Nimm_WatchGameClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Nimm_ServiceDesc.Streams[0], Nimm_WatchGame_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &nimmWatchGameClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Nimm_WatchGameClient interface {
+	Recv() (*GameState, error)
+	grpc.ClientStream
+}
+
+type nimmWatchGameClient struct {
+	grpc.ClientStream
+}
+
+func (x *nimmWatchGameClient) Recv() (*GameState, error) {
+	m := new(GameState)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NimmServer is the server API for Nimm service.
+// All implementations must embed UnimplementedNimmServer
+// for forward compatibility
+type NimmServer interface {
+	// CreateGame starts a new game on the server, against an engine or as a
+	// challenge that another player can join.
+	CreateGame(context.Context, *CreateGameRequest) (*GameState, error)
+	// JoinGame takes the empty seat of a challenge.
+	JoinGame(context.Context, *GetGameRequest) (*GameState, error)
+	// GetGame returns the current state of a game.
+	GetGame(context.Context, *GetGameRequest) (*GameState, error)
+	// TakeObjects makes a move on behalf of the player to move. Against an
+	// engine, the state after its reply is returned.
+	TakeObjects(context.Context, *TakeObjectsRequest) (*GameState, error)
+	// WatchGame streams the state of a game after every move until it is
+	// finished.
+	WatchGame(*GetGameRequest, Nimm_WatchGameServer) error
+	mustEmbedUnimplementedNimmServer()
+}
+
+// UnimplementedNimmServer must be embedded to have forward compatible implementations.
+type UnimplementedNimmServer struct {
+}
+
+func (UnimplementedNimmServer) CreateGame(context.Context, *CreateGameRequest) (*GameState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateGame not implemented")
+}
+func (UnimplementedNimmServer) JoinGame(context.Context, *GetGameRequest) (*GameState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method JoinGame not implemented")
+}
+func (UnimplementedNimmServer) GetGame(context.Context, *GetGameRequest) (*GameState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGame not implemented")
+}
+func (UnimplementedNimmServer) TakeObjects(context.Context, *TakeObjectsRequest) (*GameState, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method TakeObjects not implemented")
+}
+func (UnimplementedNimmServer) WatchGame(*GetGameRequest, Nimm_WatchGameServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchGame not implemented")
+}
+func (UnimplementedNimmServer) mustEmbedUnimplementedNimmServer() {}
+
+// UnsafeNimmServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NimmServer will
+// result in compilation errors.
+type UnsafeNimmServer interface {
+	mustEmbedUnimplementedNimmServer()
+}
+
+func RegisterNimmServer(s grpc.ServiceRegistrar, srv NimmServer) {
+	s.RegisterService(&Nimm_ServiceDesc, srv)
+}
+
+func _Nimm_CreateGame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateGameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NimmServer).CreateGame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Nimm_CreateGame_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NimmServer).CreateGame(ctx, req.(*CreateGameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Nimm_JoinGame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NimmServer).JoinGame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Nimm_JoinGame_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NimmServer).JoinGame(ctx, req.(*GetGameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Nimm_GetGame_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NimmServer).GetGame(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Nimm_GetGame_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NimmServer).GetGame(ctx, req.(*GetGameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Nimm_TakeObjects_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TakeObjectsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NimmServer).TakeObjects(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Nimm_TakeObjects_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NimmServer).TakeObjects(ctx, req.(*TakeObjectsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Nimm_WatchGame_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetGameRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NimmServer).WatchGame(m, &nimmWatchGameServer{stream})
+}
+
+type Nimm_WatchGameServer interface {
+	Send(*GameState) error
+	grpc.ServerStream
+}
+
+type nimmWatchGameServer struct {
+	grpc.ServerStream
+}
+
+func (x *nimmWatchGameServer) Send(m *GameState) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Nimm_ServiceDesc is the grpc.ServiceDesc for Nimm service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Nimm_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "nimm.v1.Nimm",
+	HandlerType: (*NimmServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateGame",
+			Handler:    _Nimm_CreateGame_Handler,
+		},
+		{
+			MethodName: "JoinGame",
+			Handler:    _Nimm_JoinGame_Handler,
+		},
+		{
+			MethodName: "GetGame",
+			Handler:    _Nimm_GetGame_Handler,
+		},
+		{
+			MethodName: "TakeObjects",
+			Handler:    _Nimm_TakeObjects_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchGame",
+			Handler:       _Nimm_WatchGame_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/nimm.proto",
+}
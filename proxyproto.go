@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// proxyHeaderTimeout bounds how long a proxy may take to send the header.
+const proxyHeaderTimeout = 5 * time.Second
+
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyConn is a connection whose remote address was announced in a PROXY
+// protocol header.
+type proxyConn struct {
+	net.Conn
+	r      *bufio.Reader
+	remote net.Addr
+}
+
+func (c *proxyConn) Read(b []byte) (int, error) { return c.r.Read(b) }
+func (c *proxyConn) RemoteAddr() net.Addr       { return c.remote }
+
+// proxyProtocolCallback returns a ConnCallback that reads the HAProxy PROXY
+// protocol header (v1 or v2) from connections made by one of the trusted
+// proxies and replaces the remote address with the one of the real client.
+// Connections from other addresses are passed through untouched. The list
+// may not be empty, or any client could claim any address.
+func proxyProtocolCallback(trusted []string) (ssh.ConnCallback, error) {
+	if len(trusted) == 0 {
+		return nil, errors.New("proxy_protocol needs the addresses of the proxies in trusted_proxies")
+	}
+	var nets []*net.IPNet
+	for _, t := range trusted {
+		if !strings.Contains(t, "/") {
+			if strings.Contains(t, ":") {
+				t += "/128"
+			} else {
+				t += "/32"
+			}
+		}
+		_, n, err := net.ParseCIDR(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", t, err)
+		}
+		nets = append(nets, n)
+	}
+	isTrusted := func(addr net.Addr) bool {
+		tcp, ok := addr.(*net.TCPAddr)
+		if !ok {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(tcp.IP) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(ctx ssh.Context, conn net.Conn) net.Conn {
+		if !isTrusted(conn.RemoteAddr()) {
+			return conn
+		}
+		if err := conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout)); err != nil {
+			return nil
+		}
+		r := bufio.NewReader(conn)
+		remote, err := readProxyHeader(r)
+		if err != nil {
+			log.Printf("Invalid PROXY header from %s: %v", conn.RemoteAddr(), err)
+			return nil
+		}
+		if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			return nil
+		}
+		if remote == nil {
+			remote = conn.RemoteAddr()
+		}
+		return &proxyConn{Conn: conn, r: r, remote: remote}
+	}, nil
+}
+
+// readProxyHeader parses a PROXY protocol header. It returns a nil address
+// if the proxy does not know the client, e.g. for health checks.
+func readProxyHeader(r *bufio.Reader) (net.Addr, error) {
+	sig, err := r.Peek(len(proxyV2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(sig, proxyV2Signature) {
+		return readProxyHeaderV2(r)
+	}
+	return readProxyHeaderV1(r)
+}
+
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, error) {
+	// a v1 header is at most 107 bytes long including the trailing CRLF
+	var line []byte
+	for len(line) < 107 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errors.New("header too long")
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 || fields[0] != "PROXY" {
+		return nil, errors.New("not a PROXY header")
+	}
+	switch fields[1] {
+	case "UNKNOWN":
+		return nil, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", fields[1])
+	}
+	if len(fields) != 6 {
+		return nil, errors.New("malformed header")
+	}
+	ip := net.ParseIP(fields[2])
+	if ip == nil {
+		return nil, fmt.Errorf("invalid source address %q", fields[2])
+	}
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source port %q", fields[4])
+	}
+	return &net.TCPAddr{IP: ip, Port: int(port)}, nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, fmt.Errorf("unsupported version %d", header[12]>>4)
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	switch cmd := header[12] & 0x0f; cmd {
+	case 0:
+		// the LOCAL command is used by the proxy itself, e.g. for health
+		// checks
+		return nil, nil
+	case 1: // PROXY
+	default:
+		return nil, fmt.Errorf("unsupported command %d", cmd)
+	}
+	// SSH only runs over streams, anything else wasn't proxied by us
+	if transport := header[13] & 0x0f; transport != 1 {
+		return nil, fmt.Errorf("unsupported transport %d", transport)
+	}
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		if len(payload) < 12 {
+			return nil, errors.New("short address block")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 2: // AF_INET6
+		if len(payload) < 36 {
+			return nil, errors.New("short address block")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// proxyV2 is a v2 header with the version and command ver, the family and
+// transport fam and the address block addr.
+func proxyV2(ver, fam byte, addr []byte) []byte {
+	b := append(append([]byte(nil), proxyV2Signature...), ver, fam, 0, 0)
+	binary.BigEndian.PutUint16(b[14:], uint16(len(addr)))
+	return append(b, addr...)
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	inet := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0x30, 0x39, 0, 22}
+	inet6 := append(append(net.ParseIP("2001:db8::1").To16(), net.ParseIP("2001:db8::2").To16()...), 0x30, 0x39, 0, 22)
+	tests := []struct {
+		name string
+		in   []byte
+		// want is the client address, empty if the proxy doesn't know it
+		want    string
+		wantErr bool
+	}{
+		{name: "v1 TCP4", in: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 12345 22\r\n"), want: "192.0.2.1:12345"},
+		{name: "v1 TCP6", in: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 12345 22\r\n"), want: "[2001:db8::1]:12345"},
+		{name: "v1 UNKNOWN", in: []byte("PROXY UNKNOWN\r\n")},
+		{name: "v1 UNKNOWN with addresses", in: []byte("PROXY UNKNOWN 192.0.2.1 198.51.100.1 12345 22\r\n")},
+		{name: "v1 longest", in: []byte("PROXY TCP6 ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff 65535 65535\r\n"), want: "[ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff]:65535"},
+		{name: "v1 too long", in: []byte("PROXY TCP4 " + strings.Repeat(" ", 100) + "192.0.2.1 198.51.100.1 12345 22\r\n"), wantErr: true},
+		{name: "v1 without CRLF", in: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 12345 22\n"), wantErr: true},
+		{name: "v1 cut off", in: []byte("PROXY TCP4 192.0.2.1"), wantErr: true},
+		{name: "v1 not PROXY", in: []byte("SSH-2.0-OpenSSH_9.0\r\n"), wantErr: true},
+		{name: "v1 protocol", in: []byte("PROXY UDP4 192.0.2.1 198.51.100.1 12345 22\r\n"), wantErr: true},
+		{name: "v1 fields missing", in: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 12345\r\n"), wantErr: true},
+		{name: "v1 source address", in: []byte("PROXY TCP4 192.0.2 198.51.100.1 12345 22\r\n"), wantErr: true},
+		{name: "v1 source port", in: []byte("PROXY TCP4 192.0.2.1 198.51.100.1 65536 22\r\n"), wantErr: true},
+		{name: "v1 empty", in: nil, wantErr: true},
+		{name: "v2 TCP4", in: proxyV2(0x21, 0x11, inet), want: "192.0.2.1:12345"},
+		{name: "v2 TCP6", in: proxyV2(0x21, 0x21, inet6), want: "[2001:db8::1]:12345"},
+		{name: "v2 TLVs after the addresses", in: proxyV2(0x21, 0x11, append(inet, 0x04, 0, 1, 0)), want: "192.0.2.1:12345"},
+		{name: "v2 LOCAL", in: proxyV2(0x20, 0x00, nil)},
+		{name: "v2 LOCAL with addresses", in: proxyV2(0x20, 0x12, inet)},
+		{name: "v2 UNIX stream", in: proxyV2(0x21, 0x31, make([]byte, 216))},
+		{name: "v2 UDP4", in: proxyV2(0x21, 0x12, inet), wantErr: true},
+		{name: "v2 UDP6", in: proxyV2(0x21, 0x22, inet6), wantErr: true},
+		{name: "v2 UNSPEC", in: proxyV2(0x21, 0x00, nil), wantErr: true},
+		{name: "v2 unknown transport", in: proxyV2(0x21, 0x13, inet), wantErr: true},
+		{name: "v2 unknown command", in: proxyV2(0x22, 0x11, inet), wantErr: true},
+		{name: "v2 version 1", in: proxyV2(0x11, 0x11, inet), wantErr: true},
+		{name: "v2 short TCP4 block", in: proxyV2(0x21, 0x11, inet[:11]), wantErr: true},
+		{name: "v2 short TCP6 block", in: proxyV2(0x21, 0x21, inet6[:35]), wantErr: true},
+		{name: "v2 header cut off", in: proxyV2(0x21, 0x11, inet)[:14], wantErr: true},
+		{name: "v2 addresses cut off", in: proxyV2(0x21, 0x11, inet)[:20], wantErr: true},
+		{name: "v2 longest", in: proxyV2(0x21, 0x11, append(inet, make([]byte, 0xffff-len(inet))...)), want: "192.0.2.1:12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.in
+			if !tt.wantErr {
+				// the client speaks SSH after the header
+				in = append(append([]byte(nil), in...), "SSH-2.0-"...)
+			}
+			r := bufio.NewReader(bytes.NewReader(in))
+			addr, err := readProxyHeader(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readProxyHeader = %v, want an error: %t", err, tt.wantErr)
+			}
+			got := ""
+			if addr != nil {
+				got = addr.String()
+			}
+			if got != tt.want {
+				t.Errorf("readProxyHeader = %q, want %q", got, tt.want)
+			}
+			if err != nil {
+				return
+			}
+			if rest, _ := io.ReadAll(r); string(rest) != "SSH-2.0-" {
+				t.Errorf("left %q after the header", rest)
+			}
+		})
+	}
+}
+
+func TestProxyProtocolTrusted(t *testing.T) {
+	if _, err := proxyProtocolCallback(nil); err == nil {
+		t.Error("no error without trusted proxies")
+	}
+	if _, err := proxyProtocolCallback([]string{"proxy.example"}); err == nil {
+		t.Error("no error for a host name")
+	}
+	cb, err := proxyProtocolCallback([]string{"127.0.0.1", "10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		header string
+		// want is the remote address of the connection, empty if it is
+		// dropped
+		want string
+	}{
+		{"trusted", "127.0.0.1:1000", "PROXY TCP4 192.0.2.1 198.51.100.1 12345 22\r\n", "192.0.2.1:12345"},
+		{"trusted net", "10.1.2.3:1000", "PROXY TCP4 192.0.2.1 198.51.100.1 12345 22\r\n", "192.0.2.1:12345"},
+		{"health check", "127.0.0.1:1000", "PROXY UNKNOWN\r\n", "127.0.0.1:1000"},
+		{"invalid header", "127.0.0.1:1000", "SSH-2.0-OpenSSH_9.0\r\n", ""},
+		{"untrusted", "192.0.2.9:1000", "PROXY TCP4 192.0.2.1 198.51.100.1 12345 22\r\n", "192.0.2.9:1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			remote, err := net.ResolveTCPAddr("tcp", tt.remote)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, server := net.Pipe()
+			defer client.Close()
+			go func() { _, _ = io.WriteString(client, tt.header) }()
+			conn := cb(nil, addrConn{server, remote})
+			got := ""
+			if conn != nil {
+				got = conn.RemoteAddr().String()
+			}
+			if got != tt.want {
+				t.Errorf("remote address %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// addrConn is a connection from remote.
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
@@ -0,0 +1,440 @@
+// Package qr encodes short texts like links as QR codes. It only knows what
+// nimm needs: byte mode, error correction level M and versions 1 to 10,
+// which hold up to 213 bytes.
+package qr
+
+import "errors"
+
+// ErrTooLong is returned for data that doesn't fit into version 10.
+var ErrTooLong = errors.New("qr: data too long")
+
+// Code is a QR code, a square of Size modules.
+type Code struct {
+	Size    int
+	modules [][]bool
+	// function marks the modules of the patterns, which hold no data
+	function [][]bool
+}
+
+// Black reports whether the module in column x and row y is dark. Modules
+// outside of the code, in its quiet zone, are light.
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y][x]
+}
+
+// block is how the codewords of a version are split up: the error
+// correction codewords per block and the data codewords of the blocks of
+// both groups, where those of the second one hold one more.
+type block struct {
+	ec             int
+	short, long    int
+	shortDataWords int
+}
+
+// blocks are the blocks of the versions 1 to 10 at level M.
+var blocks = []block{
+	{ec: 10, short: 1, shortDataWords: 16},
+	{ec: 16, short: 1, shortDataWords: 28},
+	{ec: 26, short: 1, shortDataWords: 44},
+	{ec: 18, short: 2, shortDataWords: 32},
+	{ec: 24, short: 2, shortDataWords: 43},
+	{ec: 16, short: 4, shortDataWords: 27},
+	{ec: 18, short: 4, shortDataWords: 31},
+	{ec: 22, short: 2, long: 2, shortDataWords: 38},
+	{ec: 22, short: 3, long: 2, shortDataWords: 36},
+	{ec: 26, short: 4, long: 1, shortDataWords: 43},
+}
+
+// alignment are the centers of the alignment patterns of the versions.
+var alignment = [][]int{
+	nil,
+	{6, 18},
+	{6, 22},
+	{6, 26},
+	{6, 30},
+	{6, 34},
+	{6, 22, 38},
+	{6, 24, 42},
+	{6, 26, 46},
+	{6, 28, 50},
+}
+
+func (b block) dataWords() int {
+	return b.short*b.shortDataWords + b.long*(b.shortDataWords+1)
+}
+
+// Encode returns the smallest code holding data, with the mask that is
+// easiest to scan.
+func Encode(data []byte) (*Code, error) {
+	version := 0
+	for v, b := range blocks {
+		countBits := 8
+		if v+1 >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*b.dataWords() {
+			version = v + 1
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrTooLong
+	}
+	words := codewords(version, data)
+	var best *Code
+	bestPenalty := 0
+	for mask := 0; mask < 8; mask++ {
+		c := newCode(version)
+		c.place(words)
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.penalty(); best == nil || p < bestPenalty {
+			best, bestPenalty = c, p
+		}
+	}
+	return best, nil
+}
+
+// codewords are the data of version with its error correction, interleaved
+// as they are placed.
+func codewords(version int, data []byte) []byte {
+	b := blocks[version-1]
+	var bits bitBuffer
+	bits.append(0b0100, 4)
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, d := range data {
+		bits.append(int(d), 8)
+	}
+	capacity := 8 * b.dataWords()
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xec; len(bits) < capacity; pad ^= 0xec ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	dataWords := bits.bytes()
+
+	divisor := rsDivisor(b.ec)
+	var dataBlocks, ecBlocks [][]byte
+	for i := 0; i < b.short+b.long; i++ {
+		n := b.shortDataWords
+		if i >= b.short {
+			n++
+		}
+		dataBlocks = append(dataBlocks, dataWords[:n])
+		ecBlocks = append(ecBlocks, rsRemainder(dataWords[:n], divisor))
+		dataWords = dataWords[n:]
+	}
+	var words []byte
+	for i := 0; i <= b.shortDataWords; i++ {
+		for _, d := range dataBlocks {
+			if i < len(d) {
+				words = append(words, d[i])
+			}
+		}
+	}
+	for i := 0; i < b.ec; i++ {
+		for _, e := range ecBlocks {
+			words = append(words, e[i])
+		}
+	}
+	return words
+}
+
+// newCode returns an empty code of version with its patterns drawn.
+func newCode(version int) *Code {
+	size := 17 + 4*version
+	c := &Code{Size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range c.modules {
+		c.modules[y] = make([]bool, size)
+		c.function[y] = make([]bool, size)
+	}
+	for i := 0; i < size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(size-4, 3)
+	c.drawFinder(3, size-4)
+	pos := alignment[version-1]
+	last := len(pos) - 1
+	for i := range pos {
+		for j := range pos {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				// taken by the finders
+				continue
+			}
+			c.drawAlignment(pos[i], pos[j])
+		}
+	}
+	// reserve the format, the mask is not known yet
+	c.drawFormat(0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ rem>>11*0x1f25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			c.set(a, b, bits>>i&1 == 1)
+			c.set(b, a, bits>>i&1 == 1)
+		}
+	}
+	return c
+}
+
+// set sets the module at x, y of a pattern.
+func (c *Code) set(x, y int, black bool) {
+	c.modules[y][x] = black
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.set(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+func (c *Code) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormat draws both copies of the level and the mask.
+func (c *Code) drawFormat(mask int) {
+	// level M is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ rem>>9*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.Size-15+i, bit(i))
+	}
+	c.set(8, c.Size-8, true)
+}
+
+// place fills the modules that aren't part of a pattern with words, in
+// columns of two going up and down from the bottom right corner.
+func (c *Code) place(words []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			// skip the vertical timing pattern
+			right = 5
+		}
+		for vert := 0; vert < c.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.Size - 1 - vert
+				}
+				if c.function[y][x] || i >= 8*len(words) {
+					continue
+				}
+				c.modules[y][x] = words[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip && !c.function[y][x] {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty rates how hard the code is to scan: long runs and blocks of the
+// same color, patterns that look like finders and an uneven balance of dark
+// and light modules.
+func (c *Code) penalty() int {
+	p := 0
+	dark := 0
+	for i := 0; i < c.Size; i++ {
+		p += linePenalty(func(j int) bool { return c.Black(j, i) }, c.Size)
+		p += linePenalty(func(j int) bool { return c.Black(i, j) }, c.Size)
+	}
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.Size && y+1 < c.Size {
+				b := c.modules[y][x]
+				if c.modules[y][x+1] == b && c.modules[y+1][x] == b && c.modules[y+1][x+1] == b {
+					p += 3
+				}
+			}
+		}
+	}
+	total := c.Size * c.Size
+	p += abs(dark*20-total*10) / total * 10
+	return p
+}
+
+// finderLike is the pattern of a finder with light modules on one side.
+var finderLike = []bool{true, false, true, true, true, false, true, false, false, false, false}
+
+// linePenalty rates a row or a column of n modules.
+func linePenalty(black func(int) bool, n int) int {
+	p := 0
+	run := 1
+	for i := 1; i <= n; i++ {
+		if i < n && black(i) == black(i-1) {
+			run++
+			continue
+		}
+		if run >= 5 {
+			p += run - 2
+		}
+		run = 1
+	}
+	for i := -4; i < n; i++ {
+		forward, backward := true, true
+		for j, b := range finderLike {
+			forward = forward && black(i+j) == b
+			backward = backward && black(i+len(finderLike)-1-j) == b
+		}
+		if forward {
+			p += 40
+		}
+		if backward {
+			p += 40
+		}
+	}
+	return p
+}
+
+// rsDivisor is the generator polynomial of degree for Reed-Solomon codes,
+// without its leading 1.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 2)
+	}
+	return result
+}
+
+// rsRemainder are the error correction codewords of data.
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMul(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMul(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ z>>7*0x11d
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// bitBuffer collects bits, most significant first.
+type bitBuffer []bool
+
+func (b *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return out
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,208 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		n       int
+		version int
+		err     error
+	}{
+		{0, 1, nil},
+		{14, 1, nil},
+		{15, 2, nil},
+		{26, 2, nil},
+		{27, 3, nil},
+		{62, 4, nil},
+		{106, 6, nil},
+		{122, 7, nil},
+		{180, 9, nil},
+		// the length takes two bytes from version 10 on
+		{181, 10, nil},
+		{213, 10, nil},
+		{214, 0, ErrTooLong},
+		{4096, 0, ErrTooLong},
+	}
+	for _, tt := range tests {
+		c, err := Encode(bytes.Repeat([]byte{'x'}, tt.n))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Encode of %d bytes = %v, want %v", tt.n, err, tt.err)
+			continue
+		}
+		if err == nil && c.Size != 17+4*tt.version {
+			t.Errorf("Encode of %d bytes has %d modules, want version %d", tt.n, c.Size, tt.version)
+		}
+	}
+}
+
+func TestReedSolomon(t *testing.T) {
+	// the example of version 1-M in ISO/IEC 18004, annex I
+	data := []byte{0x10, 0x20, 0x0c, 0x56, 0x61, 0x80, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11, 0xec, 0x11}
+	want := []byte{0xa5, 0x24, 0xd4, 0xc1, 0xed, 0x36, 0xc7, 0x87, 0x2c, 0x55}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Errorf("error correction = %x, want %x", got, want)
+	}
+}
+
+func TestVersionInformation(t *testing.T) {
+	// version 7 is 000111 with the BCH code 110010010100
+	const want = 0x07c94
+	c := newCode(7)
+	got := 0
+	for i := 0; i < 18; i++ {
+		a, b := c.Size-11+i%3, i/3
+		if c.Black(a, b) != c.Black(b, a) {
+			t.Fatalf("the copies of the version differ in bit %d", i)
+		}
+		if c.Black(a, b) {
+			got |= 1 << i
+		}
+	}
+	if got != want {
+		t.Errorf("version information = %018b, want %018b", got, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"https://nimm.example/replays/3f9a",
+		strings.Repeat("long link ", 18),
+		strings.Repeat("z", 213),
+	} {
+		c, err := Encode([]byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := decode(c)
+		if err != nil {
+			t.Errorf("decode of %q: %v", text, err)
+		} else if string(got) != text {
+			t.Errorf("decode = %q, want %q", got, text)
+		}
+	}
+}
+
+// decode reads the data back from c, the way a scanner would once it found
+// the modules.
+func decode(c *Code) ([]byte, error) {
+	version := (c.Size - 17) / 4
+	// the first copy of the format, see drawFormat
+	format := 0
+	for i := 0; i < 15; i++ {
+		var black bool
+		switch {
+		case i <= 5:
+			black = c.Black(8, i)
+		case i == 6:
+			black = c.Black(8, 7)
+		case i == 7:
+			black = c.Black(8, 8)
+		case i == 8:
+			black = c.Black(7, 8)
+		default:
+			black = c.Black(14-i, 8)
+		}
+		if black {
+			format |= 1 << i
+		}
+	}
+	format ^= 0x5412
+	if level := format >> 13; level != 0 {
+		return nil, errors.New("not level M")
+	}
+	mask := format >> 10 & 7
+
+	// unmask a copy with the patterns of the version
+	plain := newCode(version)
+	for y := range plain.modules {
+		copy(plain.modules[y], c.modules[y])
+	}
+	plain.applyMask(mask)
+
+	b := blocks[version-1]
+	n := b.dataWords() + b.ec*(b.short+b.long)
+	words := make([]byte, n)
+	i := 0
+	for right := plain.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < plain.Size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = plain.Size - 1 - vert
+				}
+				if plain.function[y][x] || i >= 8*n {
+					continue
+				}
+				if plain.modules[y][x] {
+					words[i/8] |= 1 << (7 - i%8)
+				}
+				i++
+			}
+		}
+	}
+
+	// undo the interleaving of the data blocks
+	dataBlocks := make([][]byte, b.short+b.long)
+	k := 0
+	for i := 0; i <= b.shortDataWords; i++ {
+		for j := range dataBlocks {
+			if i < b.shortDataWords || j >= b.short {
+				dataBlocks[j] = append(dataBlocks[j], words[k])
+				k++
+			}
+		}
+	}
+	divisor := rsDivisor(b.ec)
+	var data []byte
+	for j, d := range dataBlocks {
+		ec := make([]byte, b.ec)
+		for i := range ec {
+			ec[i] = words[b.dataWords()+i*len(dataBlocks)+j]
+		}
+		if !bytes.Equal(ec, rsRemainder(d, divisor)) {
+			return nil, errors.New("wrong error correction")
+		}
+		data = append(data, d...)
+	}
+
+	if data[0]>>4 != 0b0100 {
+		return nil, errors.New("not byte mode")
+	}
+	// the length follows the mode, shifted by its four bits
+	bits := bitBuffer{}
+	for _, d := range data {
+		bits.append(int(d), 8)
+	}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	read := func(from, n int) int {
+		v := 0
+		for _, bit := range bits[from : from+n] {
+			v <<= 1
+			if bit {
+				v |= 1
+			}
+		}
+		return v
+	}
+	length := read(4, countBits)
+	if 4+countBits+8*length > len(bits) {
+		return nil, errors.New("length beyond the data")
+	}
+	out := make([]byte, length)
+	for i := range out {
+		out[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return out, nil
+}
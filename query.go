@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+const queryUsage = `usage:
+  leaderboard [n]   show the best players
+  stats [name]      show your results, or those of name
+  replay <id>       show the moves of a game
+  colors [profile]  show or set the colors of your terminal: auto,
+                    truecolor, 256, 16 or mono
+  timezone [zone]   show or set the time zone times are shown in: auto
+                    or a name like Europe/Berlin`
+
+var (
+	errQueryUsage    = errors.New(queryUsage)
+	errQueryNeedsKey = errors.New("only players who log in with a key have settings, log in with one to change them")
+)
+
+// queryMiddleware answers `ssh host leaderboard`, `ssh host stats`,
+// `ssh host replay <id>`, `ssh host colors` and `ssh host timezone` with
+// plain text instead of starting the game.
+func queryMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			cmd := s.Command()
+			if len(cmd) == 0 || !isQuery(cmd[0]) {
+				sh(s)
+				return
+			}
+			if err := runQuery(s, s.User(), sessionKey(s), s.Environ(), cmd); err != nil {
+				wish.Fatalln(s, err)
+				return
+			}
+			_ = s.Exit(0)
+		}
+	}
+}
+
+func isQuery(name string) bool {
+	return name == "leaderboard" || name == "stats" || name == "replay" || name == "colors" || name == "timezone"
+}
+
+// runQuery answers the query args of user, who logged in with key, with
+// times in the time zone of the user or else the one in environ.
+func runQuery(w io.Writer, user, key string, environ []string, args []string) error {
+	loc := userLocation(prefs.get(key), environ)
+	l, _ := parseLanguage(prefs.get(key).Language)
+	formats := formatsFor(localeFromEnv(environ), l)
+	stamp := formats.date + " " + formats.clock + " MST"
+	switch args[0] {
+	case "leaderboard":
+		n := topPlayers
+		if len(args) > 1 {
+			v, err := strconv.Atoi(args[1])
+			if err != nil || v < 1 {
+				return errQueryUsage
+			}
+			n = v
+		}
+		if n > leaderboardSize {
+			n = leaderboardSize
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "RANK\tNAME\tWINS\tLOSSES")
+		for i, p := range players.leaderboard(n) {
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\n", i+1, p.Name, p.Wins, p.Losses)
+		}
+		return tw.Flush()
+	case "stats":
+		// others are looked up by name, the own results by key
+		name, own := user, len(args) == 1
+		p, err := players.get(key)
+		if !own {
+			name = args[1]
+			p, err = players.named(name)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "Name:\t%s\n", p.Name)
+		fmt.Fprintf(tw, "Rank:\t%d\n", rank(players.leaderboard(math.MaxInt32), p.Name))
+		fmt.Fprintf(tw, "Wins:\t%d\n", p.Wins)
+		fmt.Fprintf(tw, "Losses:\t%d\n", p.Losses)
+		fmt.Fprintf(tw, "Last played:\t%s\n", p.LastPlayed.In(loc).Format(stamp))
+		switch days, _ := p.streakAt(time.Now()); {
+		case days == 1:
+			fmt.Fprintln(tw, "Streak:\t1 day")
+		case days > 1:
+			fmt.Fprintf(tw, "Streak:\t%d days\n", days)
+		}
+		// the campaign is kept in the settings, under the key the player
+		// logged in with, so it is only known for their own results
+		if done := prefs.get(key).Campaign; own && campaignComplete(done) {
+			fmt.Fprintf(tw, "Campaign:\tcompleted %s\n", campaignBadge)
+		} else if own && done > 0 {
+			fmt.Fprintf(tw, "Campaign:\t%d of %d levels\n", done, len(campaignLevels))
+		}
+		return tw.Flush()
+	case "replay":
+		if len(args) != 2 {
+			return errQueryUsage
+		}
+		state, err := replayState(args[1])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Nimm %s: %s vs %s\n", state.id, seatName(state.players[0]), seatName(state.players[1]))
+		if len(state.history) > 0 {
+			fmt.Fprintf(w, "Played on %s\n", state.history[0].At.In(loc).Format(stamp))
+		}
+		for i, field := range replayFields(state) {
+			fmt.Fprintf(w, "\n%s\n%s", replayCaption(state, i-1), boardText(field))
+		}
+		return nil
+	case "colors":
+		if len(args) > 2 {
+			return errQueryUsage
+		}
+		if len(args) == 2 {
+			if key == "" {
+				return errQueryNeedsKey
+			}
+			if _, _, err := parseColorProfile(args[1]); err != nil {
+				return err
+			}
+			err := prefs.update(key, func(p *userPrefs) {
+				p.Colors = args[1]
+				if p.Colors == "auto" {
+					p.Colors = ""
+				}
+			})
+			if err != nil {
+				return err
+			}
+		}
+		colors := prefs.get(key).Colors
+		if colors == "" {
+			colors = "auto"
+		}
+		fmt.Fprintln(w, colors)
+		return nil
+	case "timezone":
+		if len(args) > 2 {
+			return errQueryUsage
+		}
+		if len(args) == 2 {
+			if key == "" {
+				return errQueryNeedsKey
+			}
+			zone := args[1]
+			if zone == "auto" {
+				zone = ""
+			} else if _, err := parseTimeZone(zone); err != nil {
+				return err
+			}
+			if err := prefs.update(key, func(p *userPrefs) { p.TimeZone = zone }); err != nil {
+				return err
+			}
+			loc = userLocation(prefs.get(key), environ)
+		}
+		zone := "auto"
+		if p := prefs.get(key); p.TimeZone != "" {
+			zone = p.TimeZone
+		}
+		fmt.Fprintf(w, "%s (%s)\n", zone, time.Now().In(loc).Format(formats.clock+" MST"))
+		return nil
+	}
+	return errQueryUsage
+}
+
+// replayState finds the game id among the running and the archived games.
+func replayState(id string) (gameState, error) {
+	if g, err := games.get(id); err == nil {
+		return g.state(), nil
+	}
+	sg, err := archivedGame(id)
+	if err != nil {
+		return gameState{}, err
+	}
+	return sg.state()
+}
+
+// seatName is how a seat is shown in plain text.
+func seatName(name string) string {
+	if name == "" {
+		return "the computer"
+	}
+	return name
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// boardView is the board as it is shown, described in words for screen
+// readers if the player asked for it.
+func (m model) boardView() string {
+	if m.reader {
+		return m.describeBoard()
+	}
+	return m.board.View()
+}
+
+// describeBoard tells what is left in every row, where the cursor is and
+// what is selected, one sentence per line, after the last move.
+func (m model) describeBoard() string {
+	l := m.lang
+	var b strings.Builder
+	b.WriteString(m.describeLastMove() + "\n\n")
+	for r, row := range m.state.field {
+		n := 0
+		for _, avail := range row {
+			if avail {
+				n++
+			}
+		}
+		switch n {
+		case 0:
+			b.WriteString(l.trf("Row %d: no objects", r+1))
+		case 1:
+			b.WriteString(l.trf("Row %d: 1 object at position %s", r+1, positions(row, l)))
+		default:
+			b.WriteString(l.trf("Row %d: %d objects at positions %s", r+1, n, positions(row, l)))
+		}
+		b.WriteString("\n")
+	}
+	row, col := m.board.Cursor()
+	b.WriteString("\n" + l.trf("Cursor at row %d, position %d.", row+1, col+1) + "\n")
+	if sel, ok := m.board.Selection(); ok {
+		b.WriteString(l.trf("Selected: row %d, positions %s.", sel.Row+1, positionRange(sel.From, sel.To, l)) + "\n")
+	} else {
+		b.WriteString(l.tr("Nothing selected.") + "\n")
+	}
+	return b.String()
+}
+
+// describeLastMove announces who took what in the last move.
+func (m model) describeLastMove() string {
+	l := m.lang
+	if len(m.state.history) == 0 {
+		return l.tr("No moves yet.")
+	}
+	mv := m.state.history[len(m.state.history)-1]
+	n := l.trf("%d objects", mv.count())
+	if mv.count() == 1 {
+		n = l.tr("1 object")
+	}
+	switch {
+	case m.you == 0:
+		return l.trf("Player %d took %s from row %d.", mv.Player, n, mv.Row+1)
+	case mv.Player == m.you:
+		return l.trf("You took %s from row %d.", n, mv.Row+1)
+	}
+	return l.trf("The opponent took %s from row %d.", n, mv.Row+1)
+}
+
+// positions lists the positions of the objects in row, counted from 1,
+// with runs of adjacent objects as a span, e.g. "1, 3 to 5".
+func positions(row []bool, l language) string {
+	var runs []string
+	for c := 0; c < len(row); c++ {
+		if !row[c] {
+			continue
+		}
+		from := c
+		for c+1 < len(row) && row[c+1] {
+			c++
+		}
+		runs = append(runs, positionRange(from, c, l))
+	}
+	return strings.Join(runs, ", ")
+}
+
+// positionRange names the positions from and to, counted from 0.
+func positionRange(from, to int, l language) string {
+	if from == to {
+		return strconv.Itoa(from + 1)
+	}
+	return l.trf("%d to %d", from+1, to+1)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// recordingsDir is where the activity of sessions is recorded if the
+	// operator turned recording on.
+	recordingsDir = "recordings"
+	// defaultRetentionDays is how long recordings are kept unless the
+	// config says otherwise.
+	defaultRetentionDays = 30
+	// recordingPruneInterval is how often old recordings are deleted.
+	recordingPruneInterval = time.Hour
+)
+
+// recordingConfig turns on recording what players do in their sessions, to
+// look into reports of abuse. Only what happens in the game is recorded, the
+// keys players press are not.
+type recordingConfig struct {
+	// RetentionDays is how long recordings are kept, 30 days if 0.
+	RetentionDays int `json:"retention_days"`
+}
+
+// retention is how long recordings are kept.
+func (c recordingConfig) retention() time.Duration {
+	days := c.RetentionDays
+	if days <= 0 {
+		days = defaultRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// recordedEvent is a line in a recording.
+type recordedEvent struct {
+	At    time.Time   `json:"at"`
+	Event string      `json:"event"`
+	User  string      `json:"user,omitempty"`
+	Addr  string      `json:"addr,omitempty"`
+	Game  string      `json:"game,omitempty"`
+	Move  *moveRecord `json:"move,omitempty"`
+	Text  string      `json:"text,omitempty"`
+}
+
+// recording writes the events of a session as JSON lines to its own file.
+// A nil recording records nothing, so callers don't have to check whether
+// recording is on.
+type recording struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// startRecording starts recording the session id if the operator turned
+// recording on, and returns nil otherwise.
+func startRecording(id string, start time.Time) *recording {
+	if currentConfig().Recording == nil {
+		return nil
+	}
+	if err := os.MkdirAll(recordingsDir, 0o700); err != nil {
+		log.Printf("Could not record session %s: %v", shortID(id), err)
+		return nil
+	}
+	name := start.UTC().Format("20060102-150405") + "-" + filepath.Base(id) + ".jsonl"
+	f, err := os.OpenFile(filepath.Join(recordingsDir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		log.Printf("Could not record session %s: %v", shortID(id), err)
+		return nil
+	}
+	return &recording{f: f, enc: json.NewEncoder(f)}
+}
+
+// record adds e to the recording, at the current time.
+func (r *recording) record(e recordedEvent) {
+	if r == nil {
+		return
+	}
+	e.At = time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return
+	}
+	if err := r.enc.Encode(e); err != nil {
+		log.Printf("Could not record %s: %v", e.Event, err)
+	}
+}
+
+// close ends the recording, later events are dropped.
+func (r *recording) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil {
+		_ = r.f.Close()
+		r.f = nil
+	}
+}
+
+// pruneRecordings deletes recordings in dir last written before cutoff.
+func pruneRecordings(dir string, cutoff time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	removed := 0
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// expireRecordings periodically deletes recordings older than the retention
+// limit, also after recording has been turned off.
+func expireRecordings() {
+	for range time.Tick(recordingPruneInterval) {
+		var c recordingConfig
+		if r := currentConfig().Recording; r != nil {
+			c = *r
+		}
+		removed, err := pruneRecordings(recordingsDir, time.Now().Add(-c.retention()))
+		if err != nil {
+			log.Printf("Could not delete old recordings: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("Deleted %d recordings older than %d days", removed, int(c.retention().Hours()/24))
+		}
+	}
+}
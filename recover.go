@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+)
+
+const crashMessage = "Sorry, something went wrong and your session had to be closed."
+
+// exitMessageMiddleware tells players why their session ended, e.g. because
+// they were kicked. It has to run after the bubbletea middleware has stopped
+// the program.
+func exitMessageMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			sess := sessions.get(s.Context().SessionID())
+			if sess == nil {
+				sh(s)
+				return
+			}
+			if sess.out != nil {
+				// send what is left of the program's output first
+				sess.out.close()
+			}
+			if msg := sess.exitMessage(); msg != "" {
+				wish.Fatalln(s, msg)
+				return
+			}
+			sh(s)
+		}
+	}
+}
+
+// recoverMiddleware closes a session whose handler panics instead of taking
+// down the whole server.
+func recoverMiddleware() wish.Middleware {
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Panic in session %s: %v\n%s", shortID(s.Context().SessionID()), r, debug.Stack())
+					wish.Fatalln(s, languageFromEnv(s.Environ()).tr(crashMessage))
+				}
+			}()
+			sh(s)
+		}
+	}
+}
+
+// safeModel recovers from panics in the wrapped model and its commands. The
+// session is closed with an error message instead of crashing the server.
+// It also drops input that comes too fast, see inputLimiter.
+type safeModel struct {
+	tea.Model
+	sess    *session
+	crashed *int32
+	input   *inputLimiter
+}
+
+func newSafeModel(m tea.Model, sess *session) safeModel {
+	return safeModel{Model: m, sess: sess, crashed: new(int32), input: &inputLimiter{}}
+}
+
+func (m safeModel) recovered(r interface{}) {
+	log.Printf("Panic in session %s: %v\n%s", shortID(m.sess.id), r, debug.Stack())
+	atomic.StoreInt32(m.crashed, 1)
+	m.sess.setExitMessage(m.sess.lang.tr(crashMessage))
+}
+
+func (m safeModel) Init() (cmd tea.Cmd) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.recovered(r)
+			cmd = tea.Quit
+		}
+	}()
+	return m.safeCmd(m.Model.Init())
+}
+
+func (m safeModel) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	if atomic.LoadInt32(m.crashed) == 1 {
+		return m, tea.Quit
+	}
+	drop, cmd := m.limitInput(msg)
+	m.input.dropped = drop
+	if drop {
+		return m, cmd
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			m.recovered(r)
+			model, cmd = m, tea.Quit
+		}
+	}()
+	inner, cmd := m.Model.Update(msg)
+	m.Model = inner
+	return m, m.safeCmd(cmd)
+}
+
+func (m safeModel) View() (view string) {
+	if atomic.LoadInt32(m.crashed) == 1 {
+		l := m.sess.lang
+		return "\n  " + defaultTheme.warn.Render(l.tr(crashMessage)) + "\n  " + defaultTheme.dim.Render(l.tr("Press any key to disconnect."))
+	}
+	if m.input.dropped && m.input.view != "" {
+		// dropped input changes nothing, so it isn't rendered again
+		return m.input.view
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			m.recovered(r)
+			view = m.View()
+		}
+	}()
+	m.input.view = m.Model.View()
+	return m.input.view
+}
+
+// safeCmd wraps cmd so that a panic while running it ends the session. The
+// commands of a batch are wrapped as well.
+func (m safeModel) safeCmd(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				m.recovered(r)
+				msg = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		msg = cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			wrapped := make(tea.BatchMsg, len(batch))
+			for i, c := range batch {
+				wrapped[i] = m.safeCmd(c)
+			}
+			return wrapped
+		}
+		return msg
+	}
+}
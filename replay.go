@@ -0,0 +1,232 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/qr"
+)
+
+// moveRecord is one move in the history of a game.
+type moveRecord struct {
+	Player int `json:"player"`
+	Row    int `json:"row"`
+	From   int `json:"from"`
+	To     int `json:"to"`
+	// Taken is how many objects the move took, the cells between From and
+	// To that were empty don't count.
+	Taken int       `json:"taken,omitempty"`
+	At    time.Time `json:"at"`
+}
+
+// count is how many objects mv took. Moves recorded before Taken took
+// every cell from From to To.
+func (mv moveRecord) count() int {
+	if mv.Taken > 0 {
+		return mv.Taken
+	}
+	return mv.To - mv.From + 1
+}
+
+//go:embed web/replay.html
+var replayPage string
+
+var replayTemplate = template.Must(template.New("replay").Parse(replayPage))
+
+// replayHandler serves the web replay of the finished game at
+// /replays/{id}, which the QR code after a game points at by default.
+func replayHandler(w http.ResponseWriter, r *http.Request) {
+	state, err := replayState(strings.TrimPrefix(r.URL.Path, "/replays/"))
+	if err != nil || !state.finished() {
+		http.NotFound(w, r)
+		return
+	}
+	type step struct{ Caption, Board string }
+	var steps []step
+	for i, field := range replayFields(state) {
+		steps = append(steps, step{replayCaption(state, i-1), boardText(field)})
+	}
+	played := ""
+	if len(state.history) > 0 {
+		played = state.history[0].At.UTC().Format("2 Jan 2006 15:04 MST")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = replayTemplate.Execute(w, struct {
+		ID      string
+		Players [2]string
+		Played  string
+		Steps   []step
+	}{state.id, [2]string{seatName(state.players[0]), seatName(state.players[1])}, played, steps})
+	if err != nil {
+		log.Printf("Could not write replay of %s: %v", state.id, err)
+	}
+}
+
+// replayLink is the web replay of the game id, where tmpl is the
+// ReplayURL of the config.
+func replayLink(tmpl, id string) string {
+	return strings.ReplaceAll(tmpl, "{id}", url.PathEscape(id))
+}
+
+// qrStyle draws the light modules of QR codes as white on black, so that
+// they can be scanned on dark and light terminals alike.
+var qrStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#ffffff")).Background(lipgloss.Color("#000000"))
+
+// qrText is a QR code of text with a quiet zone of two modules, two rows of
+// modules to a line.
+func qrText(text string) (string, error) {
+	code, err := qr.Encode([]byte(text))
+	if err != nil {
+		return "", err
+	}
+	const quiet = 2
+	var lines []string
+	for y := -quiet; y < code.Size+quiet; y += 2 {
+		var b strings.Builder
+		for x := -quiet; x < code.Size+quiet; x++ {
+			// the glyphs draw the light modules
+			top, bottom := !code.Black(x, y), !code.Black(x, y+1) && y+1 < code.Size+quiet
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		lines = append(lines, qrStyle.Render(b.String()))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// castIdleLimit shortens long pauses between moves in exported replays.
+const castIdleLimit = 2 * time.Second
+
+// replayFields returns the board before each move of state and after the
+// last one. Moves may span empty cells, so only the board at the start
+// tells which cells they took. For games saved without it, the start is
+// the current board with every cell of the moves put back.
+func replayFields(state gameState) [][][]bool {
+	if state.start != nil {
+		field := state.start
+		fields := [][][]bool{field}
+		for _, mv := range state.history {
+			next := make([][]bool, len(field))
+			for r, row := range field {
+				next[r] = append([]bool(nil), row...)
+			}
+			for col := mv.From; col <= mv.To; col++ {
+				next[mv.Row][col] = false
+			}
+			fields = append(fields, next)
+			field = next
+		}
+		return fields
+	}
+	field := make([][]bool, len(state.field))
+	for i, row := range state.field {
+		field[i] = append([]bool(nil), row...)
+	}
+	fields := [][][]bool{field}
+	for i := len(state.history) - 1; i >= 0; i-- {
+		mv := state.history[i]
+		prev := make([][]bool, len(field))
+		for r, row := range field {
+			prev[r] = append([]bool(nil), row...)
+		}
+		for col := mv.From; col <= mv.To; col++ {
+			prev[mv.Row][col] = true
+		}
+		fields = append([][][]bool{prev}, fields...)
+		field = prev
+	}
+	return fields
+}
+
+// boardText draws field like the game does, without styles.
+func boardText(field [][]bool) string {
+	var b strings.Builder
+	for _, row := range field {
+		for _, avail := range row {
+			mark := " "
+			if avail {
+				mark = "X"
+			}
+			b.WriteString("  " + mark)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// renderCast returns the replay of state as an asciinema v2 cast.
+func renderCast(state gameState) ([]byte, error) {
+	fields := replayFields(state)
+	var buf bytes.Buffer
+	header := struct {
+		Version       int     `json:"version"`
+		Width         int     `json:"width"`
+		Height        int     `json:"height"`
+		Timestamp     int64   `json:"timestamp,omitempty"`
+		IdleTimeLimit float64 `json:"idle_time_limit"`
+		Title         string  `json:"title"`
+	}{
+		Version:       2,
+		Width:         state.cols*3 + 40,
+		Height:        state.rows + 5,
+		IdleTimeLimit: castIdleLimit.Seconds(),
+		Title:         "Nimm game " + state.id,
+	}
+	if len(state.history) > 0 {
+		header.Timestamp = state.history[0].At.Unix()
+	}
+	enc := json.NewEncoder(&buf)
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	frame := func(at time.Duration, caption string, field [][]bool) error {
+		board := strings.ReplaceAll(boardText(field), "\n", "\r\n")
+		screen := "\x1b[2J\x1b[H" + "Nimm " + state.id + "\r\n\r\n" + board + "\r\n" + caption + "\r\n"
+		return enc.Encode([]interface{}{at.Seconds(), "o", screen})
+	}
+
+	if err := frame(0, replayCaption(state, -1), fields[0]); err != nil {
+		return nil, err
+	}
+	var start time.Time
+	for i, mv := range state.history {
+		if i == 0 {
+			// the first move comes a moment after the start
+			start = mv.At.Add(-time.Second)
+		}
+		if err := frame(mv.At.Sub(start), replayCaption(state, i), fields[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// replayCaption describes move i of state, or the start for -1.
+func replayCaption(state gameState, i int) string {
+	if i < 0 {
+		return "Player 1 starts"
+	}
+	mv := state.history[i]
+	caption := fmt.Sprintf("Move %d: player %d takes %d from row %d", i+1, mv.Player, mv.count(), mv.Row+1)
+	if i == len(state.history)-1 && state.finished() {
+		caption += fmt.Sprintf(", player %d won", state.winner())
+	}
+	return caption
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/jheuel/nimm/variant"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// rulesText explains Nim, how to play it here with the keys of ks and the
+// rules of v in language l, wrapped to width. The compact rules leave out
+// the introduction and the computer.
+func rulesText(v variant.Variant, t theme, l language, ks keySet, width int, compact bool) string {
+	sections := []struct{ title, text string }{
+		{l.tr("Nim"), l.tr("Nim is a mathematical game of strategy in which" +
+			" two players take turns removing (or \"nimming\") objects from" +
+			" distinct heaps or piles. On each turn, a player must remove at" +
+			" least one object, and may remove any number of objects provided" +
+			" they all come from the same heap or pile.")},
+		{l.trf("This game: %s", v.Name()), l.tr(v.Description())},
+		{l.tr("How to play"), l.trf("Move the cursor with the arrow keys or %s. Space"+
+			" selects the object under the cursor, selecting a second object in"+
+			" the same row selects all objects in between. Enter takes the"+
+			" selected objects. With the mouse, click an object or drag over"+
+			" a row and click %s.", ks.move, l.tr(takeButton))},
+		{l.tr("Playing the computer"), l.tr("Connect with `ssh -t host vs` to play" +
+			" against the computer, which knows the winning strategy. Who" +
+			" starts is decided by a coin toss. With `ssh -t host campaign`" +
+			" you play the levels of the campaign, one after the other.")},
+	}
+	if compact {
+		sections = sections[1:3]
+	}
+	var b strings.Builder
+	for i, s := range sections {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(t.title.Render(s.title) + "\n")
+		b.WriteString(t.text.Render(wordwrap.String(s.text, width)))
+	}
+	return b.String()
+}
+
+// rulesSize is the size of the rules page on a screen of width and height
+// with header above it, in the compact layout if compact is set.
+func rulesSize(header string, width, height int, compact bool) (int, int) {
+	// the page is indented by 4 and has a footer and the status bar below
+	// it, each with an empty line in front
+	w, h := width-8, height-strings.Count(header, "\n")-10
+	if compact {
+		// no empty lines at the top and around the help, no version
+		h += 3
+	}
+	if w < 10 {
+		w = 10
+	}
+	if h < 3 {
+		h = 3
+	}
+	return w, h
+}
+
+// rulesFooter tells players how far they have scrolled and how to leave.
+func rulesFooter(vp viewport.Model, t theme, l language, km keyMap) string {
+	return t.dim.Render(l.trf("↑/↓ scrolls • %s or esc closes • %3.f%%", km.Rules.Help().Key, vp.ScrollPercent()*100))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/variant"
+)
+
+// seasonInterval is how often the scheduler looks whether an event started
+// or ended.
+const seasonInterval = time.Minute
+
+// seasonConfig is a holiday or another event that changes how the game
+// looks while it lasts, e.g. a pumpkin glyph and an orange theme for
+// Halloween.
+type seasonConfig struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Greeting is shown to players when they connect during the event or
+	// when it starts, nothing if empty.
+	Greeting string `json:"greeting"`
+	// Theme and Glyph replace the default theme and the mark of the variant
+	// for players who didn't choose their own.
+	Theme string `json:"theme"`
+	Glyph string `json:"glyph"`
+	// Board is the field of new classic games, a row per string with o for
+	// an object and . for an empty cell, the pyramid if empty.
+	Board []string `json:"board"`
+}
+
+// seasonMsg tells a session that an event started, or ended if it is empty.
+type seasonMsg seasonConfig
+
+// activeSeason returns the first event of c that lasts at t.
+func activeSeason(c config, t time.Time) (seasonConfig, bool) {
+	for _, s := range c.Seasons {
+		if !t.Before(s.Start) && t.Before(s.End) {
+			return s, true
+		}
+	}
+	return seasonConfig{}, false
+}
+
+// seasonBoard is v with the board of the event s, if it has one and v is
+// the classic game.
+func seasonBoard(v variant.Variant, s seasonConfig) variant.Variant {
+	if len(s.Board) == 0 || v.Name() != variant.Default {
+		return v
+	}
+	b, err := parseBoard(s.Board)
+	if err != nil {
+		// checked when the config was loaded
+		return v
+	}
+	return presetVariant{Variant: v, board: b}
+}
+
+// validateSeasons checks the events of c, so that the operator learns about
+// mistakes when loading the config instead of when a game starts.
+func validateSeasons(c config) error {
+	for _, s := range c.Seasons {
+		if s.Name == "" {
+			return errors.New("an event needs a name")
+		}
+		if !s.End.After(s.Start) {
+			return fmt.Errorf("event %q ends before it starts", s.Name)
+		}
+		if s.Theme != "" && getTheme(s.Theme, false).name != s.Theme {
+			return fmt.Errorf("event %q: unknown theme %q, try one of %s", s.Name, s.Theme, strings.Join(themeNames(), ", "))
+		}
+		if w := lipgloss.Width(s.Glyph); s.Glyph != "" && w > 2 {
+			return fmt.Errorf("event %q: glyph %q is wider than two cells", s.Name, s.Glyph)
+		}
+		if len(s.Board) == 0 {
+			continue
+		}
+		if _, err := parseBoard(s.Board); err != nil {
+			return fmt.Errorf("event %q: %w", s.Name, err)
+		}
+	}
+	return nil
+}
+
+var errBoard = errors.New("a board has rows of the same length with o and .")
+
+// parseBoard reads a board with a row per string, o for an object and . for
+// an empty cell.
+func parseBoard(rows []string) (variant.Board, error) {
+	if len(rows) == 0 || len(rows[0]) == 0 {
+		return nil, errBoard
+	}
+	b := make(variant.Board, len(rows))
+	for r, row := range rows {
+		if len(row) != len(rows[0]) {
+			return nil, errBoard
+		}
+		b[r] = make([]bool, len(row))
+		for c, ch := range row {
+			switch ch {
+			case 'o':
+				b[r][c] = true
+			case '.':
+			default:
+				return nil, errBoard
+			}
+		}
+	}
+	if b.Count() < 2 {
+		return nil, errors.New("a board needs at least two objects")
+	}
+	return b, nil
+}
+
+// seasons tells sessions when an event starts or ends.
+var seasons = &seasonScheduler{}
+
+// seasonScheduler remembers the event that lasts, to notice when another
+// one starts.
+type seasonScheduler struct {
+	mu     sync.Mutex
+	active string
+}
+
+// run checks for events every seasonInterval.
+func (s *seasonScheduler) run() {
+	s.check()
+	for range time.Tick(seasonInterval) {
+		s.check()
+	}
+}
+
+// check tells all sessions about the event that lasts now, if it changed.
+func (s *seasonScheduler) check() {
+	season, _ := activeSeason(currentConfig(), time.Now())
+	s.mu.Lock()
+	changed := season.Name != s.active
+	s.active = season.Name
+	s.mu.Unlock()
+	if !changed {
+		return
+	}
+	if season.Name == "" {
+		log.Println("The event ended")
+	} else {
+		log.Printf("Event %s started", season.Name)
+	}
+	sessions.broadcast(seasonMsg(season))
+}
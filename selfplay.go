@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jheuel/nimm/variant"
+)
+
+// selfPlayExactLimit is the number of objects up to which self-play searches
+// the game tree of variants the solver doesn't know. Above it they move at
+// random.
+const selfPlayExactLimit = 16
+
+// selfPlayRecord is a position of a self-play game, the move made in it and
+// how the game ended for the player to move.
+type selfPlayRecord struct {
+	Game     int    `json:"game"`
+	Variant  string `json:"variant"`
+	Rows     int    `json:"rows"`
+	Ply      int    `json:"ply"`
+	Player   int    `json:"player"`
+	Position string `json:"position"`
+	Objects  int    `json:"objects"`
+	NimSum   int    `json:"nim_sum"`
+	Row      int    `json:"row"`
+	From     int    `json:"from"`
+	To       int    `json:"to"`
+	Winner   int    `json:"winner"`
+	// Won is whether the player to move won the game in the end
+	Won bool `json:"won"`
+}
+
+var selfPlayHeader = []string{"game", "variant", "rows", "ply", "player", "position", "objects", "nim_sum", "row", "from", "to", "winner", "won"}
+
+func (r selfPlayRecord) csv() []string {
+	return []string{
+		strconv.Itoa(r.Game), r.Variant, strconv.Itoa(r.Rows), strconv.Itoa(r.Ply), strconv.Itoa(r.Player),
+		r.Position, strconv.Itoa(r.Objects), strconv.Itoa(r.NimSum),
+		strconv.Itoa(r.Row), strconv.Itoa(r.From), strconv.Itoa(r.To), strconv.Itoa(r.Winner), strconv.FormatBool(r.Won),
+	}
+}
+
+// selfPlay lets the computer play against itself on pyramids of several
+// sizes in all variants, and writes every position with the move made and
+// the outcome, for experiments with learned evaluations.
+func selfPlay(args []string) {
+	fs := flag.NewFlagSet("selfplay", flag.ExitOnError)
+	games := fs.Int("games", 1000, "how many games to play, spread over the variants and sizes")
+	variants := fs.String("variants", strings.Join(variant.Names(), ","), "the variants to play, separated by commas")
+	rows := fs.String("rows", "3,4,5", "the sizes of the pyramids to play on, in rows, separated by commas")
+	skill := fs.Float64("skill", 0.8, "how likely the players make the best move instead of a random one")
+	format := fs.String("format", "csv", "csv, or json for a JSON object per line")
+	out := fs.String("out", "", "the file to write, standard output if empty")
+	seed := fs.Int64("seed", 0, "the seed of the random moves, the current time if 0")
+	_ = fs.Parse(args)
+
+	var vs []variant.Variant
+	for _, name := range strings.Split(*variants, ",") {
+		v, err := variant.Get(strings.TrimSpace(name))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		vs = append(vs, v)
+	}
+	sizes, err := parseHeaps(*rows)
+	if err != nil {
+		err = errors.New("rows are numbers separated by commas, e.g. 3,4,5")
+	}
+	for _, n := range sizes {
+		if n < 2 {
+			err = fmt.Errorf("a pyramid needs at least 2 rows, not %d", n)
+		}
+	}
+	if err == nil && *format != "csv" && *format != "json" {
+		err = fmt.Errorf("format %q is not csv or json", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		w = f
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	sp := &selfPlayer{rand: rand.New(rand.NewSource(*seed)), skill: *skill, memo: map[string]map[string]bool{}}
+	if err := sp.run(w, *format, *games, vs, sizes); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// selfPlayer plays both sides of self-play games.
+type selfPlayer struct {
+	rand  *rand.Rand
+	skill float64
+	// memo holds the positions searched by variant, whether the player to
+	// move wins them
+	memo map[string]map[string]bool
+}
+
+// run plays games, taking turns through the variants and sizes, and writes
+// their positions to w in format.
+func (sp *selfPlayer) run(w io.Writer, format string, games int, vs []variant.Variant, sizes []int) error {
+	var write func(selfPlayRecord) error
+	cw := csv.NewWriter(w)
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		write = func(r selfPlayRecord) error { return enc.Encode(r) }
+	} else {
+		if err := cw.Write(selfPlayHeader); err != nil {
+			return err
+		}
+		write = func(r selfPlayRecord) error { return cw.Write(r.csv()) }
+	}
+	for i := 0; i < games; i++ {
+		v, rows := vs[i%len(vs)], sizes[i/len(vs)%len(sizes)]
+		records := sp.play(v, pyramidBoard(rows))
+		for _, r := range records {
+			r.Game, r.Rows = i+1, rows
+			if err := write(r); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// pyramidBoard is a pyramid of rows rows like the one of the classic game.
+func pyramidBoard(rows int) variant.Board {
+	b := make(variant.Board, rows)
+	for r := range b {
+		b[r] = make([]bool, 2*rows-1)
+		for c := rows - 1 - r; c <= rows-1+r; c++ {
+			b[r][c] = true
+		}
+	}
+	return b
+}
+
+// play plays a game of v from b and returns its positions.
+func (sp *selfPlayer) play(v variant.Variant, b variant.Board) []selfPlayRecord {
+	var records []selfPlayRecord
+	player := 1
+	for !v.Lost(b) {
+		mv, ok := sp.move(v, b)
+		if !ok {
+			break
+		}
+		next, err := v.Apply(b, mv)
+		if err != nil {
+			break
+		}
+		records = append(records, selfPlayRecord{
+			Variant:  v.Name(),
+			Ply:      len(records) + 1,
+			Player:   player,
+			Position: formatPosition(b),
+			Objects:  b.Count(),
+			NimSum:   nimSum(b),
+			Row:      mv.Row,
+			From:     mv.From,
+			To:       mv.To,
+		})
+		b = next
+		player = 3 - player
+	}
+	// the player to move on the last board lost
+	winner := 3 - player
+	for i := range records {
+		records[i].Winner = winner
+		records[i].Won = records[i].Player == winner
+	}
+	return records
+}
+
+// move picks the best move on b with the probability skill, and a random one
+// otherwise.
+func (sp *selfPlayer) move(v variant.Variant, b variant.Board) (variant.Move, bool) {
+	if v.Name() == variant.Default {
+		// the solver knows the classic game on every board
+		mv, err := skilledMove(sp.rand, sp.skill, b)
+		return variant.Move{Row: mv.row, From: mv.from, To: mv.to}, err == nil
+	}
+	moves := v.LegalMoves(b)
+	if len(moves) == 0 {
+		return variant.Move{}, false
+	}
+	if sp.rand.Float64() < sp.skill && b.Count() <= selfPlayExactLimit {
+		memo := sp.memo[v.Name()]
+		if memo == nil {
+			memo = map[string]bool{}
+			sp.memo[v.Name()] = memo
+		}
+		for _, mv := range moves {
+			if next, err := v.Apply(b, mv); err == nil && !variantWins(v, next, memo) {
+				return mv, true
+			}
+		}
+	}
+	return moves[sp.rand.Intn(len(moves))], true
+}
+
+// variantWins reports whether the player to move on b can force a win under
+// the rules of v.
+func variantWins(v variant.Variant, b variant.Board, memo map[string]bool) bool {
+	if v.Lost(b) {
+		return false
+	}
+	key := formatPosition(b)
+	if w, ok := memo[key]; ok {
+		return w
+	}
+	w := false
+	for _, mv := range v.LegalMoves(b) {
+		if next, err := v.Apply(b, mv); err == nil && !variantWins(v, next, memo) {
+			w = true
+			break
+		}
+	}
+	memo[key] = w
+	return w
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jheuel/nimm/variant"
+	"github.com/muesli/termenv"
+)
+
+// session is a connected player and the program driving their terminal.
+type session struct {
+	// stats is first to keep its 64 bit counters aligned
+	stats sessionStats
+
+	id      string
+	user    string
+	addr    net.Addr
+	p       *tea.Program
+	out     *throttledWriter
+	started time.Time
+	// lang is the language the player is told why the session ended in
+	lang language
+	// rec records what happens in the session, nil if recording is off
+	rec *recording
+
+	mu      sync.Mutex
+	state   sessionInfo
+	exitMsg string
+}
+
+// maxTerminalSize bounds the width and height that clients may announce,
+// some screens are drawn at the full size.
+const maxTerminalSize = 1000
+
+// sessionConfig describes a new session, independent of how the player is
+// connected.
+type sessionConfig struct {
+	ctx    context.Context
+	id     string
+	user   string
+	addr   net.Addr
+	term   string
+	width  int
+	height int
+	in     io.Reader
+	out    io.Writer
+	// admin shows the admin dashboard instead of a game
+	admin bool
+	// opponent is the engine to play against, or empty to play both sides
+	opponent string
+	// variant is the name of the game to play, variant.Default if empty
+	variant string
+	// campaign plays the next level of the campaign of the player instead
+	// of opponent and variant
+	campaign bool
+	// join is the id of an open game to take the empty seat of, or to come
+	// back to, and watch the id of a game to watch, instead of a new game
+	join  string
+	watch string
+	// colors is what the terminal supports, the output is converted if it
+	// is less than true color
+	colors termenv.Profile
+	// key is the fingerprint of the key the player logged in with, empty
+	// for guests. Settings are kept under it, so only players with a key
+	// have their settings used and saved.
+	key string
+	// environ are the variables the client sent, if any
+	environ []string
+}
+
+// startSession creates a session with a new game, or the admin dashboard,
+// and registers it in the session list. The session is removed again once
+// ctx is done. The caller runs the program.
+func startSession(sc sessionConfig) (*session, error) {
+	c := currentConfig()
+	trace := tracing.startSpan("session",
+		attr{"session.id", sc.id},
+		attr{"user", sc.user},
+		attr{"net.peer.addr", logAddr(sc.addr)},
+		attr{"term", sc.term},
+	)
+	var m tea.Model
+	info := sessionInfo{screen: "game"}
+	max := c.MaxSessions
+	var g *game
+	var eng engine
+	// release undoes what the session did to the games if it can't start
+	release := func() {}
+	var p userPrefs
+	if sc.key != "" {
+		p = prefs.get(sc.key)
+	}
+	if sc.variant == "" && sc.opponent == "" && p.Variant != "" && featureEnabled(featureVariants) {
+		// the player's own default, unless it has been removed
+		if _, err := variant.Get(p.Variant); err == nil {
+			sc.variant = p.Variant
+		}
+	}
+	if sc.variant == "" {
+		sc.variant = variant.Default
+	}
+	v, err := variant.Get(sc.variant)
+	if err == nil && sc.variant != variant.Default && !featureEnabled(featureVariants) {
+		err = errVariantsDisabled
+	}
+	if err == nil && sc.opponent != "" && sc.variant != variant.Default {
+		err = errEngineVariant
+	}
+	if err != nil {
+		trace.setError(err)
+		trace.finish()
+		return nil, err
+	}
+	season, _ := activeSeason(c, time.Now())
+	v = seasonBoard(v, season)
+	if sc.opponent != "" {
+		if eng, err = newEngine(sc.opponent); err != nil {
+			trace.setError(err)
+			trace.finish()
+			return nil, err
+		}
+	}
+	level := 0
+	if sc.campaign {
+		level = campaignLevelFor(p.Campaign)
+		if v, err = newCampaignVariant(campaignLevels[level-1]); err != nil {
+			trace.setError(err)
+			trace.finish()
+			return nil, err
+		}
+		eng = newCampaignEngine(campaignLevels[level-1])
+	}
+	if sc.admin {
+		m = newDashboard(sc.width, sc.height)
+		info.screen = "admin"
+		max = 0
+	} else {
+		seat := 0
+		if sc.join != "" || sc.watch != "" {
+			var claimed bool
+			if g, seat, claimed, err = existingGame(sc, displayName(p, sc.user)); err != nil {
+				trace.setError(err)
+				trace.finish()
+				return nil, err
+			}
+			if claimed {
+				owner := sessionOwner(sc.key, sc.id)
+				release = func() { g.unclaimSeat(owner) }
+			}
+		} else {
+			g = games.create(v)
+			id := g.id
+			release = func() { games.remove(id) }
+			if eng == nil {
+				// the player plays both sides
+				g.seat(1, "", sessionOwner(sc.key, sc.id))
+				g.seat(2, "", sessionOwner(sc.key, sc.id))
+			}
+			trace.child("game.create", attr{"game.id", g.id}, attr{"variant", v.Name()}, attr{"rows", g.rows}, attr{"cols", g.cols}).finish()
+		}
+		info.gameID = g.id
+		gm := newModel(g, sc.term, sc.width, sc.height)
+		gm.id = sc.id
+		gm.trace = trace
+		gm.banner = c.Banner
+		gm.replayURL = c.replayURL()
+		gm.user = sc.user
+		gm.owner = sessionOwner(sc.key, sc.id)
+		if p.Name == "" {
+			// guests are asked every time
+			gm.name = newNamePrompt(sc.user)
+		}
+		gm.key = sc.key
+		if needsTerms(c, p.AcceptedTerms) {
+			// guests are asked every time
+			gm.terms = c.Terms
+		}
+		gm.light, _ = colorFGBG(sc.environ)
+		gm.noColor = sc.colors == termenv.Ascii
+		gm.asciiLocale = asciiLocale(sc.environ)
+		gm.envLang = languageFromEnv(sc.environ)
+		gm.envLocale = localeFromEnv(sc.environ)
+		gm.envLocation = envLocation(sc.environ)
+		gm.season = season
+		gm.maintenance = currentMaintenance()
+		gm.you = seat
+		gm.watching = sc.watch != ""
+		gm.stats, _ = players.get(sc.key)
+		gm.setPrefs(p)
+		// screen readers would only read the logo out
+		gm.splash = !c.NoSplash && !gm.reader
+		gm.tagline = c.Tagline
+		if eng != nil {
+			// toss a coin for who starts
+			gm.you = 1 + int(time.Now().UnixNano()%2)
+			gm.opponent = eng
+			if p, ok := eng.(*personalityEngine); ok {
+				gm.said = p.remark(remarkGreeting)
+			}
+			if level != 0 {
+				gm.level = level
+				gm.you = campaignLevels[level-1].playerSeat()
+			}
+			g.seat(gm.you, gm.displayName(), gm.owner)
+		}
+		m = gm
+	}
+	sess := &session{
+		id:      sc.id,
+		user:    sc.user,
+		addr:    sc.addr,
+		started: time.Now(),
+		state:   info,
+		lang:    languageFromEnv(sc.environ),
+	}
+	if g != nil {
+		sess.rec = startRecording(sc.id, sess.started)
+	}
+	if l, ok := parseLanguage(p.Language); ok {
+		sess.lang = l
+	}
+	out := newThrottledWriter(countingWriter{w: sc.out, stats: &sess.stats}, c.MaxOutputBuffer, c.MaxFPS)
+	sess.out = out
+	if gm, ok := m.(model); ok {
+		// the bell is rung outside of the rendered frames
+		gm.bell = out
+		gm.rec = sess.rec
+		m = gm
+	}
+	in := sc.in
+	if g != nil && p.Background == "" {
+		// ask the terminal for its background, the reply comes as input
+		in = newBackgroundFilter(in, func(light bool) {
+			go sess.p.Send(backgroundMsg(light))
+		})
+		_, _ = io.WriteString(out, queryBackground)
+	}
+	opts := []tea.ProgramOption{
+		tea.WithContext(sc.ctx),
+		tea.WithInput(in),
+		tea.WithOutput(frameWriter{w: newColorWriter(out, sc.colors), stats: &sess.stats}),
+		tea.WithoutCatchPanics(),
+	}
+	if !p.Scrollback {
+		opts = append(opts, tea.WithAltScreen())
+	}
+	sess.p = tea.NewProgram(newSafeModel(m, sess), opts...)
+	out.setProgram(sess.p)
+	if err := sessions.add(sess, max); err != nil {
+		out.close()
+		sess.rec.close()
+		release()
+		if eng != nil {
+			eng.close()
+		}
+		trace.setError(err)
+		trace.finish()
+		return nil, err
+	}
+	if g != nil && sc.watch != "" {
+		// watchers are counted as spectators of the game
+		updates, stop, err := g.watch()
+		if err != nil {
+			log.Printf("Could not watch game %s: %v", g.id, err)
+			updates, stop = nil, func() {}
+		}
+		go func() {
+			defer stop()
+			for {
+				select {
+				case state := <-updates:
+					sess.p.Send(gameUpdateMsg(state))
+				case <-sc.ctx.Done():
+					return
+				}
+			}
+		}()
+	} else if g != nil {
+		if _, err := games.join(g.id, sess.id, sess.p); err != nil {
+			log.Printf("Could not join game %s: %v", g.id, err)
+		}
+		fireEvent(hookGameStarted, newAPIGame(g.state()))
+	}
+	if g != nil {
+		sess.rec.record(recordedEvent{Event: "start", User: sc.user, Addr: logAddr(sc.addr), Game: g.id, Text: p.Name})
+	}
+	go func() {
+		<-sc.ctx.Done()
+		out.close()
+		sess.rec.record(recordedEvent{Event: "end", Text: sess.exitMessage()})
+		sess.rec.close()
+		sessions.remove(sess.id)
+		if g != nil {
+			games.leave(g.id, sess.id)
+		}
+		if eng != nil {
+			eng.close()
+		}
+		trace.finish()
+	}()
+	return sess, nil
+}
+
+// existingGame returns the game a session asked for by id instead of a new
+// one, and the seat the player called name took in it, 0 to watch it.
+// Players who own a seat in the game come back to it, claimed is set if they
+// took the seat just now.
+func existingGame(sc sessionConfig, name string) (g *game, seat int, claimed bool, err error) {
+	id := sc.join
+	if sc.watch != "" {
+		id = sc.watch
+	}
+	g, err = games.get(id)
+	if err != nil || sc.watch != "" {
+		return g, 0, false, err
+	}
+	owner := sessionOwner(sc.key, sc.id)
+	state, err := g.claimSeat(name, owner)
+	for i, o := range state.owners {
+		if o == owner {
+			seat = i + 1
+		}
+	}
+	if errors.Is(err, errNotOpen) && seat != 0 {
+		if state.owners[0] == state.owners[1] {
+			// they play both sides
+			seat = 0
+		}
+		return g, seat, false, nil
+	}
+	return g, seat, err == nil, err
+}
+
+// sessionInfo describes what a session is currently doing.
+type sessionInfo struct {
+	screen string
+	gameID string
+}
+
+func (s *session) info() sessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+func (s *session) setInfo(info sessionInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = info
+}
+
+// kick ends the session. The reason, if any, is shown to the player once
+// their program has stopped.
+func (s *session) kick(reason string) {
+	msg := s.lang.tr("You have been disconnected by the operator.")
+	if reason != "" {
+		msg += " " + reason
+	}
+	s.setExitMessage(msg)
+	s.rec.record(recordedEvent{Event: "kick", Text: reason})
+	s.p.Quit()
+}
+
+// setExitMessage sets a message that is shown to the player after their
+// program has stopped.
+func (s *session) setExitMessage(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exitMsg = msg
+}
+
+func (s *session) exitMessage() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitMsg
+}
+
+// sessionList keeps track of all active sessions so that the server can talk
+// to them, e.g. when shutting down.
+type sessionList struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	closed   bool
+	// closedErr is what new sessions are told once the list is closed
+	closedErr error
+}
+
+var sessions = &sessionList{sessions: map[string]*session{}}
+
+var (
+	errShuttingDown     = errors.New("server is shutting down, please try again later")
+	errTooManySessions  = errors.New("server is full, please try again later")
+	errVariantsDisabled = errors.New("variants are disabled on this server")
+	errEngineVariant    = errors.New("engines only play the classic variant")
+)
+
+// add registers a new session unless the list has been closed or already
+// holds max sessions. A max of 0 means no limit.
+func (l *sessionList) add(sess *session, max int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return l.closedErr
+	}
+	if max > 0 && len(l.sessions) >= max {
+		return errTooManySessions
+	}
+	l.sessions[sess.id] = sess
+	return nil
+}
+
+func (l *sessionList) remove(id string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, id)
+}
+
+// close stops accepting new sessions.
+func (l *sessionList) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		l.closed, l.closedErr = true, errShuttingDown
+	}
+}
+
+// closeWith stops accepting new sessions, which are told err. It reports
+// false if the list was already closed.
+func (l *sessionList) closeWith(err error) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return false
+	}
+	l.closed, l.closedErr = true, err
+	return true
+}
+
+func (l *sessionList) isClosed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closed
+}
+
+func (l *sessionList) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.sessions)
+}
+
+// all returns a snapshot of the active sessions, oldest first.
+func (l *sessionList) all() []*session {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	all := make([]*session, 0, len(l.sessions))
+	for _, sess := range l.sessions {
+		all = append(all, sess)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].started.Before(all[j].started) })
+	return all
+}
+
+func (l *sessionList) get(id string) *session {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sessions[id]
+}
+
+// find returns the session whose id starts with prefix.
+func (l *sessionList) find(prefix string) (*session, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found *session
+	for id, sess := range l.sessions {
+		if !strings.HasPrefix(id, prefix) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("session id %q is ambiguous", prefix)
+		}
+		found = sess
+	}
+	if found == nil {
+		return nil, fmt.Errorf("no session %q", prefix)
+	}
+	return found, nil
+}
+
+// broadcast sends msg to the programs of all active sessions.
+func (l *sessionList) broadcast(msg tea.Msg) {
+	for _, sess := range l.all() {
+		sess.p.Send(msg)
+	}
+}
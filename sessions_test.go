@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jheuel/nimm/variant"
+)
+
+func TestStartSessionOverLimit(t *testing.T) {
+	withConfig(t, func(c *config) { c.MaxSessions = 1 })
+	// another player took the only session
+	if err := sessions.add(&session{id: "other"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer sessions.remove("other")
+
+	open := games.create(variant.MustGet(variant.Default))
+	open.seat(1, "alice", tokenOwner("alice"))
+	open.challenge("")
+	tests := []struct {
+		name string
+		join string
+	}{
+		{"new game", ""},
+		{"join", open.id},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(games.all())
+			_, err := startSession(sessionConfig{
+				ctx:    context.Background(),
+				id:     "bob",
+				user:   "bob",
+				addr:   &net.TCPAddr{},
+				term:   "xterm",
+				width:  80,
+				height: 24,
+				in:     strings.NewReader(""),
+				out:    io.Discard,
+				join:   tt.join,
+			})
+			if !errors.Is(err, errTooManySessions) {
+				t.Fatalf("startSession = %v, want %v", err, errTooManySessions)
+			}
+			if n := len(games.all()); n != before {
+				t.Errorf("%d games after the session was rejected, want %d", n, before)
+			}
+			if state := open.state(); !open.isOpen() || state.owners[1] != "" {
+				t.Errorf("the seat of the rejected session wasn't given back, owners %q", state.owners)
+			}
+		})
+	}
+}
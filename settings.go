@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/variant"
+)
+
+// setting is one line of the settings screen.
+type setting struct {
+	name   string
+	values func() []string
+	get    func(userPrefs) string
+	set    func(*userPrefs, string)
+	// custom sets a value typed by the player, if the setting takes one
+	custom func(*userPrefs, string) bool
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+var settingsList = []setting{
+	{
+		name:   "Theme",
+		values: themeNames,
+		get:    func(p userPrefs) string { return getTheme(p.Theme, false).name },
+		set: func(p *userPrefs, v string) {
+			if v == defaultTheme.name {
+				v = ""
+			}
+			p.Theme = v
+		},
+	},
+	{
+		name:   "Background",
+		values: func() []string { return []string{"auto", "dark", "light"} },
+		get: func(p userPrefs) string {
+			if p.Background == "" {
+				return "auto"
+			}
+			return p.Background
+		},
+		set: func(p *userPrefs, v string) {
+			if v == "auto" {
+				v = ""
+			}
+			p.Background = v
+		},
+	},
+	{
+		name:   "Display",
+		values: func() []string { return []string{"auto", "styled", "ascii", "reader"} },
+		get: func(p userPrefs) string {
+			if p.Display == "" {
+				return "auto"
+			}
+			return p.Display
+		},
+		set: func(p *userPrefs, v string) {
+			if v == "auto" {
+				v = ""
+			}
+			p.Display = v
+		},
+	},
+	{
+		name:   "Bell",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.Bell) },
+		set:    func(p *userPrefs, v string) { p.Bell = v == "on" },
+	},
+	{
+		name:   "Flash",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.Flash) },
+		set:    func(p *userPrefs, v string) { p.Flash = v == "on" },
+	},
+	{
+		name:   "Selection",
+		values: func() []string { return []string{"range", "quick"} },
+		get: func(p userPrefs) string {
+			if p.Selection == "" {
+				return "range"
+			}
+			return p.Selection
+		},
+		set: func(p *userPrefs, v string) {
+			if v == "range" {
+				v = ""
+			}
+			p.Selection = v
+		},
+	},
+	{
+		name:   "Glyph",
+		values: func() []string { return []string{"auto", "X", "●", "█", "|"} },
+		get: func(p userPrefs) string {
+			if p.Glyph == "" {
+				return "auto"
+			}
+			return p.Glyph
+		},
+		set: func(p *userPrefs, v string) {
+			if v == "auto" {
+				v = ""
+			}
+			p.Glyph = v
+		},
+		custom: func(p *userPrefs, v string) bool {
+			// one printable character that fits into a cell
+			r, _ := utf8.DecodeRuneInString(v)
+			if utf8.RuneCountInString(v) != 1 || !unicode.IsPrint(r) || unicode.IsSpace(r) {
+				return false
+			}
+			if w := lipgloss.Width(v); w < 1 || w > 2 {
+				return false
+			}
+			p.Glyph = v
+			return true
+		},
+	},
+	{
+		name:   "Labels",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.Labels) },
+		set:    func(p *userPrefs, v string) { p.Labels = v == "on" },
+	},
+	{
+		name:   "Counts",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.Counts) },
+		set:    func(p *userPrefs, v string) { p.Counts = v == "on" },
+	},
+	{
+		name:   "Frame",
+		values: func() []string { return []string{"on", "off"} },
+		get:    func(p userPrefs) string { return onOff(!p.NoFrame) },
+		set:    func(p *userPrefs, v string) { p.NoFrame = v == "off" },
+	},
+	{
+		name: "Variant",
+		values: func() []string {
+			if !featureEnabled(featureVariants) {
+				return []string{variant.Default}
+			}
+			return variant.Names()
+		},
+		get: func(p userPrefs) string {
+			if p.Variant == "" || !featureEnabled(featureVariants) {
+				// it isn't played while variants are disabled
+				return variant.Default
+			}
+			return p.Variant
+		},
+		set: func(p *userPrefs, v string) {
+			if v == variant.Default {
+				v = ""
+			}
+			p.Variant = v
+		},
+	},
+	{
+		name: "Language",
+		values: func() []string {
+			values := []string{"auto"}
+			for _, l := range languages {
+				values = append(values, string(l))
+			}
+			return values
+		},
+		get: func(p userPrefs) string {
+			if p.Language == "" {
+				return "auto"
+			}
+			return p.Language
+		},
+		set: func(p *userPrefs, v string) {
+			if v == "auto" {
+				v = ""
+			}
+			p.Language = v
+		},
+	},
+	{
+		name:   "Keys",
+		values: keySetNames,
+		get:    func(p userPrefs) string { return getKeySet(p.Keys).name },
+		set: func(p *userPrefs, v string) {
+			if v == keySets[0].name {
+				v = ""
+			}
+			p.Keys = v
+		},
+	},
+	{
+		name:   "Help",
+		values: func() []string { return []string{"short", "off", "full"} },
+		get: func(p userPrefs) string {
+			if p.Help == "" {
+				return "short"
+			}
+			return p.Help
+		},
+		set: func(p *userPrefs, v string) {
+			if v == "short" {
+				v = ""
+			}
+			p.Help = v
+		},
+	},
+	{
+		name:   "Mouse",
+		values: func() []string { return []string{"on", "off"} },
+		get:    func(p userPrefs) string { return onOff(!p.NoMouse) },
+		set:    func(p *userPrefs, v string) { p.NoMouse = v == "off" },
+	},
+	{
+		name:   "Scrollback",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.Scrollback) },
+		set:    func(p *userPrefs, v string) { p.Scrollback = v == "on" },
+	},
+	{
+		name:   "Clock",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.Clock) },
+		set:    func(p *userPrefs, v string) { p.Clock = v == "on" },
+	},
+	{
+		name:   "Game time",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.GameTime) },
+		set:    func(p *userPrefs, v string) { p.GameTime = v == "on" },
+	},
+	{
+		name:   "Replay QR",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.ReplayQR) },
+		set:    func(p *userPrefs, v string) { p.ReplayQR = v == "on" },
+	},
+	{
+		name:   "Thinking",
+		values: func() []string { return []string{"instant", "short", "normal", "slow"} },
+		get: func(p userPrefs) string {
+			if p.Thinking == "" {
+				return "short"
+			}
+			return p.Thinking
+		},
+		set: func(p *userPrefs, v string) {
+			if v == "short" {
+				v = ""
+			}
+			p.Thinking = v
+		},
+	},
+	{
+		name:   "Analysis",
+		values: func() []string { return []string{"off", "on"} },
+		get:    func(p userPrefs) string { return onOff(p.Analysis) },
+		set:    func(p *userPrefs, v string) { p.Analysis = v == "on" },
+	},
+}
+
+// settingHints explain the settings on the screen.
+var settingHints = map[string]string{
+	"Theme":      "the colors of the game",
+	"Background": "auto asks your terminal",
+	"Display":    "ascii shows plain characters, reader describes the board",
+	"Bell":       "ring the terminal bell when it is your turn",
+	"Flash":      "flash the screen when it is your turn",
+	"Selection":  "quick takes a single object with enter alone",
+	"Glyph":      "how objects are drawn, type a character for your own",
+	"Labels":     "letters and numbers around the board to name cells",
+	"Counts":     "how many objects are left at the end of each row",
+	"Frame":      "a border around the board with the game and the clock",
+	"Variant":    "the game you get when you connect",
+	"Language":   "auto follows LANG of your terminal",
+	"Keys":       "wasd or hjkl where they are on a Dvorak keyboard",
+	"Help":       "the keys below the board, full shows all of them",
+	"Mouse":      "off lets your terminal select text again",
+	"Scrollback": "on keeps the game in your terminal after you leave",
+	"Clock":      "show how long both players have been thinking",
+	"Game time":  "show how long the game has been going on",
+	"Thinking":   "how long the computer takes before it moves",
+	"Replay QR":  "show a code of the replay for your phone after a game",
+	"Analysis":   "show the nim-sum before and after your selection",
+}
+
+// settingsScreen lets players change their settings during a session.
+type settingsScreen struct {
+	prefs  userPrefs
+	cursor int
+}
+
+// update handles a key on the settings screen, where km are the keys of the
+// player. It reports whether a setting changed and whether the screen should
+// close.
+func (s settingsScreen) update(msg tea.KeyMsg, km keyMap) (settingsScreen, bool, bool) {
+	switch {
+	case msg.Type == tea.KeyRunes && settingsList[s.cursor].custom != nil && !key.Matches(msg, km.Settings, km.Up, km.Down, km.Left, km.Right, km.Select):
+		// the setting takes what the player types
+		return s, settingsList[s.cursor].custom(&s.prefs, string(msg.Runes)), false
+	case key.Matches(msg, km.Settings), msg.Type == tea.KeyEsc:
+		return s, false, true
+	case key.Matches(msg, km.Up):
+		if s.cursor > 0 {
+			s.cursor--
+		}
+	case key.Matches(msg, km.Down):
+		if s.cursor < len(settingsList)-1 {
+			s.cursor++
+		}
+	case key.Matches(msg, km.Left):
+		return s.cycle(-1), true, false
+	case key.Matches(msg, km.Right), key.Matches(msg, km.Select), key.Matches(msg, km.Submit):
+		return s.cycle(1), true, false
+	}
+	return s, false, false
+}
+
+// cycle switches the setting under the cursor to the next or previous value.
+func (s settingsScreen) cycle(dir int) settingsScreen {
+	st := settingsList[s.cursor]
+	values, cur := st.values(), st.get(s.prefs)
+	i := 0
+	for j, v := range values {
+		if v == cur {
+			i = j
+		}
+	}
+	i = (i + dir + len(values)) % len(values)
+	st.set(&s.prefs, values[i])
+	return s
+}
+
+// minSettingsRows is the least number of settings shown at once.
+const minSettingsRows = 3
+
+// view shows the settings in language l with the keys km. Only rows of them
+// are shown if there are more, scrolled to the cursor.
+func (s settingsScreen) view(t theme, l language, km keyMap, saved bool, rows int) string {
+	var b strings.Builder
+	b.WriteString(t.title.Render(l.tr("Settings")) + "\n\n")
+	nameWidth, width := 0, 0
+	for _, st := range settingsList {
+		if w := lipgloss.Width(l.tr(st.name)); w > nameWidth {
+			nameWidth = w
+		}
+		for _, v := range st.values() {
+			if w := lipgloss.Width(l.tr(v)); w > width {
+				width = w
+			}
+		}
+	}
+	top := 0
+	if rows < len(settingsList) {
+		top = s.cursor - rows/2
+		if top > len(settingsList)-rows {
+			top = len(settingsList) - rows
+		}
+		if top < 0 {
+			top = 0
+		}
+	} else {
+		rows = len(settingsList)
+	}
+	for i := top; i < top+rows; i++ {
+		st := settingsList[i]
+		name, v := l.tr(st.name), l.tr(st.get(s.prefs))
+		// pad by width, some values are wider than one byte per cell
+		line := fmt.Sprintf("%s%s < %s%s >", name, strings.Repeat(" ", nameWidth-lipgloss.Width(name)), v, strings.Repeat(" ", width-lipgloss.Width(v)))
+		if i == s.cursor {
+			b.WriteString("> " + t.accent.Render(line))
+		} else {
+			b.WriteString("  " + t.text.Render(line))
+		}
+		b.WriteString("  " + t.dim.Render(l.tr(settingHints[st.name])) + "\n")
+	}
+	b.WriteString("\n")
+	if saved {
+		b.WriteString(t.dim.Render(l.tr("Changes are saved for your next visit.")))
+	} else {
+		b.WriteString(t.dim.Render(l.tr("These settings last until you leave.")))
+	}
+	b.WriteString("\n" + t.dim.Render(l.trf("←/→ changes • %s or esc closes", km.Settings.Help().Key)) + "\n")
+	return b.String()
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// A read-only SFTP server (version 3, draft-ietf-secsh-filexfer-02) for a
+// virtual tree of the data of the key the player logged in with:
+//
+//	/replays/<id>.cast   asciinema recordings of their finished games
+//	/replays/<id>.json   the same games as JSON
+//	/exports/player.json their results
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpLstat    = 7
+	sftpFstat    = 8
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+
+	sftpOK               = 0
+	sftpEOF              = 1
+	sftpNoSuchFile       = 2
+	sftpPermissionDenied = 3
+	sftpFailure          = 4
+	sftpBadMessage       = 5
+	sftpOpUnsupported    = 8
+
+	sftpAttrSize        = 0x1
+	sftpAttrPermissions = 0x4
+	sftpAttrModTime     = 0x8
+
+	sftpOpenRead = 0x1
+
+	// sftpMaxPacket bounds the requests we accept and the data we send.
+	sftpMaxPacket = 256 << 10
+	sftpMaxRead   = 32 << 10
+	// sftpMaxHandles bounds the open files and directories of a session.
+	sftpMaxHandles = 64
+)
+
+var (
+	errSFTPBadMessage = errors.New("malformed sftp packet")
+	errSFTPNeedsKey   = errors.New("only players who log in with a key have files")
+)
+
+// sftpFile is a file or directory in the virtual tree.
+type sftpFile struct {
+	name    string
+	dir     bool
+	data    []byte
+	modTime time.Time
+	// children are the names of the entries of a directory
+	children []string
+}
+
+func (f *sftpFile) attrs() []byte {
+	mode := uint32(0o100444)
+	if f.dir {
+		mode = 0o40555
+	}
+	var b []byte
+	b = appendUint32(b, sftpAttrSize|sftpAttrPermissions|sftpAttrModTime)
+	b = appendUint64(b, uint64(len(f.data)))
+	b = appendUint32(b, mode)
+	b = appendUint32(b, uint32(f.modTime.Unix()))
+	b = appendUint32(b, uint32(f.modTime.Unix()))
+	return b
+}
+
+// longname is the ls -l style line some clients show.
+func (f *sftpFile) longname() string {
+	mode := "-r--r--r--"
+	if f.dir {
+		mode = "dr-xr-xr-x"
+	}
+	return fmt.Sprintf("%s 1 nimm nimm %8d %s %s", mode, len(f.data), f.modTime.Format("Jan _2 15:04"), f.name)
+}
+
+// sftpTree builds the virtual tree of user, who logged in with key, by
+// absolute path.
+func sftpTree(user, key string) (map[string]*sftpFile, error) {
+	now := time.Now()
+	tree := map[string]*sftpFile{
+		"/":        {name: "/", dir: true, modTime: now, children: []string{"exports", "replays"}},
+		"/replays": {name: "replays", dir: true, modTime: now},
+		"/exports": {name: "exports", dir: true, modTime: now, children: []string{"player.json"}},
+	}
+
+	p, err := players.get(key)
+	if err != nil {
+		p = playerStats{Name: user}
+	}
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tree["/exports/player.json"] = &sftpFile{name: "player.json", data: append(b, '\n'), modTime: now}
+
+	saved, err := archivedGames(key)
+	if err != nil {
+		return nil, err
+	}
+	replays := tree["/replays"]
+	for _, sg := range saved {
+		state, err := sg.state()
+		if err != nil {
+			continue
+		}
+		cast, err := renderCast(state)
+		if err != nil {
+			continue
+		}
+		js, err := json.MarshalIndent(sg, "", "  ")
+		if err != nil {
+			continue
+		}
+		for name, data := range map[string][]byte{sg.ID + ".cast": cast, sg.ID + ".json": append(js, '\n')} {
+			tree["/replays/"+name] = &sftpFile{name: name, data: data, modTime: sg.SavedAt}
+			replays.children = append(replays.children, name)
+		}
+	}
+	sort.Strings(replays.children)
+	return tree, nil
+}
+
+// sftpHandler serves the sftp subsystem.
+func sftpHandler(s ssh.Session) {
+	key := sessionKey(s)
+	if key == "" {
+		fmt.Fprintln(s.Stderr(), errSFTPNeedsKey)
+		_ = s.Exit(1)
+		return
+	}
+	srv := &sftpServer{user: s.User(), key: key, w: s, handles: map[string]*sftpOpenFile{}}
+	if err := srv.serve(bufio.NewReader(s)); err != nil && !errors.Is(err, io.EOF) {
+		log.Printf("SFTP session of %s: %v", s.User(), err)
+	}
+	_ = s.Exit(0)
+}
+
+// sftpOpenFile is an open file or a directory being listed.
+type sftpOpenFile struct {
+	file *sftpFile
+	// listed is set once a directory has been sent
+	listed bool
+	// dir is a snapshot of the directory's entries
+	dir []*sftpFile
+}
+
+type sftpServer struct {
+	user string
+	key  string
+	// tree is built on the first request and kept for the session
+	tree    map[string]*sftpFile
+	w       io.Writer
+	handles map[string]*sftpOpenFile
+	next    int
+}
+
+func (srv *sftpServer) serve(r io.Reader) error {
+	for {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return err
+		}
+		if length == 0 || length > sftpMaxPacket {
+			return errSFTPBadMessage
+		}
+		pkt := make([]byte, length)
+		if _, err := io.ReadFull(r, pkt); err != nil {
+			return err
+		}
+		if err := srv.handle(pkt[0], pkt[1:]); err != nil {
+			return err
+		}
+	}
+}
+
+func (srv *sftpServer) send(typ byte, payload []byte) error {
+	b := appendUint32(nil, uint32(len(payload)+1))
+	b = append(b, typ)
+	_, err := srv.w.Write(append(b, payload...))
+	return err
+}
+
+func (srv *sftpServer) status(id uint32, code uint32, msg string) error {
+	b := appendUint32(nil, id)
+	b = appendUint32(b, code)
+	b = appendString(b, msg)
+	b = appendString(b, "en")
+	return srv.send(sftpStatus, b)
+}
+
+func (srv *sftpServer) handle(typ byte, p []byte) error {
+	if typ == sftpInit {
+		return srv.send(sftpVersion, appendUint32(nil, 3))
+	}
+	id, p, ok := readUint32(p)
+	if !ok {
+		return errSFTPBadMessage
+	}
+	switch typ {
+	case sftpRealpath:
+		name, _, ok := readString(p)
+		if !ok {
+			return srv.status(id, sftpBadMessage, "bad message")
+		}
+		b := appendUint32(appendUint32(nil, id), 1)
+		b = appendString(b, cleanSFTPPath(name))
+		b = appendString(b, "")
+		b = appendUint32(b, 0)
+		return srv.send(sftpName, b)
+
+	case sftpStat, sftpLstat:
+		name, _, ok := readString(p)
+		if !ok {
+			return srv.status(id, sftpBadMessage, "bad message")
+		}
+		f, err := srv.lookup(name)
+		if err != nil {
+			return srv.status(id, sftpNoSuchFile, err.Error())
+		}
+		return srv.send(sftpAttrs, append(appendUint32(nil, id), f.attrs()...))
+
+	case sftpOpen, sftpOpendir:
+		name, rest, ok := readString(p)
+		if !ok {
+			return srv.status(id, sftpBadMessage, "bad message")
+		}
+		if typ == sftpOpen {
+			if flags, _, ok := readUint32(rest); !ok || flags != sftpOpenRead {
+				return srv.status(id, sftpPermissionDenied, "read-only file system")
+			}
+		}
+		f, err := srv.lookup(name)
+		if err != nil {
+			return srv.status(id, sftpNoSuchFile, err.Error())
+		}
+		if f.dir && typ == sftpOpen {
+			return srv.status(id, sftpFailure, "is a directory")
+		}
+		if !f.dir && typ == sftpOpendir {
+			return srv.status(id, sftpFailure, "not a directory")
+		}
+		if len(srv.handles) >= sftpMaxHandles {
+			return srv.status(id, sftpFailure, "too many open files")
+		}
+		of := &sftpOpenFile{file: f}
+		if f.dir {
+			dir := cleanSFTPPath(name)
+			for _, child := range f.children {
+				if c, ok := srv.tree[path.Join(dir, child)]; ok {
+					of.dir = append(of.dir, c)
+				}
+			}
+		}
+		srv.next++
+		handle := strconv.Itoa(srv.next)
+		srv.handles[handle] = of
+		return srv.send(sftpHandle, appendString(appendUint32(nil, id), handle))
+
+	case sftpReaddir:
+		handle, _, ok := readString(p)
+		of := srv.handles[handle]
+		if !ok || of == nil || !of.file.dir {
+			return srv.status(id, sftpFailure, "invalid handle")
+		}
+		if of.listed || len(of.dir) == 0 {
+			return srv.status(id, sftpEOF, "")
+		}
+		of.listed = true
+		b := appendUint32(appendUint32(nil, id), uint32(len(of.dir)))
+		for _, f := range of.dir {
+			b = appendString(b, f.name)
+			b = appendString(b, f.longname())
+			b = append(b, f.attrs()...)
+		}
+		return srv.send(sftpName, b)
+
+	case sftpRead:
+		handle, rest, ok := readString(p)
+		offset, rest, ok2 := readUint64(rest)
+		length, _, ok3 := readUint32(rest)
+		of := srv.handles[handle]
+		if !ok || !ok2 || !ok3 || of == nil || of.file.dir {
+			return srv.status(id, sftpFailure, "invalid handle")
+		}
+		data := of.file.data
+		if offset >= uint64(len(data)) {
+			return srv.status(id, sftpEOF, "")
+		}
+		if length > sftpMaxRead {
+			length = sftpMaxRead
+		}
+		end := offset + uint64(length)
+		if end > uint64(len(data)) {
+			end = uint64(len(data))
+		}
+		return srv.send(sftpData, appendString(appendUint32(nil, id), string(data[offset:end])))
+
+	case sftpFstat:
+		handle, _, ok := readString(p)
+		of := srv.handles[handle]
+		if !ok || of == nil {
+			return srv.status(id, sftpFailure, "invalid handle")
+		}
+		return srv.send(sftpAttrs, append(appendUint32(nil, id), of.file.attrs()...))
+
+	case sftpClose:
+		handle, _, ok := readString(p)
+		if _, open := srv.handles[handle]; !ok || !open {
+			return srv.status(id, sftpFailure, "invalid handle")
+		}
+		delete(srv.handles, handle)
+		return srv.status(id, sftpOK, "")
+	}
+	return srv.status(id, sftpOpUnsupported, "read-only file system")
+}
+
+// lookup finds name in the tree of the session. Games finished after it was
+// built show up in the next session.
+func (srv *sftpServer) lookup(name string) (*sftpFile, error) {
+	if srv.tree == nil {
+		tree, err := sftpTree(srv.user, srv.key)
+		if err != nil {
+			return nil, err
+		}
+		srv.tree = tree
+	}
+	f, ok := srv.tree[cleanSFTPPath(name)]
+	if !ok {
+		return nil, errors.New("no such file")
+	}
+	return f, nil
+}
+
+// cleanSFTPPath makes name absolute. Relative paths start at the root.
+func cleanSFTPPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return appendUint32(appendUint32(b, uint32(v>>32)), uint32(v))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func readUint32(b []byte) (uint32, []byte, bool) {
+	if len(b) < 4 {
+		return 0, b, false
+	}
+	return binary.BigEndian.Uint32(b), b[4:], true
+}
+
+func readUint64(b []byte) (uint64, []byte, bool) {
+	if len(b) < 8 {
+		return 0, b, false
+	}
+	return binary.BigEndian.Uint64(b), b[8:], true
+}
+
+func readString(b []byte) (string, []byte, bool) {
+	n, b, ok := readUint32(b)
+	if !ok || uint32(len(b)) < n {
+		return "", b, false
+	}
+	return string(b[:n]), b[n:], true
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jheuel/nimm/variant"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestReadUint32(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want uint32
+		rest []byte
+		ok   bool
+	}{
+		{"empty", nil, 0, nil, false},
+		{"short", []byte{0, 0, 1}, 0, []byte{0, 0, 1}, false},
+		{"exact", []byte{0, 0, 1, 2}, 258, []byte{}, true},
+		{"rest", []byte{0xff, 0xff, 0xff, 0xff, 7}, 0xffffffff, []byte{7}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, ok := readUint32(tt.in)
+			if got != tt.want || ok != tt.ok || !bytes.Equal(rest, tt.rest) {
+				t.Errorf("readUint32(%v) = %d, %v, %v, want %d, %v, %v", tt.in, got, rest, ok, tt.want, tt.rest, tt.ok)
+			}
+		})
+	}
+}
+
+func TestReadString(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+		rest []byte
+		ok   bool
+	}{
+		{"empty", nil, "", nil, false},
+		{"short length", []byte{0, 0}, "", []byte{0, 0}, false},
+		{"zero length", []byte{0, 0, 0, 0}, "", []byte{}, true},
+		{"exact", append([]byte{0, 0, 0, 2}, "hi"...), "hi", []byte{}, true},
+		{"rest", append([]byte{0, 0, 0, 1}, "hi"...), "h", []byte("i"), true},
+		{"length past the end", append([]byte{0, 0, 0, 3}, "hi"...), "", []byte("hi"), false},
+		{"huge length", append([]byte{0xff, 0xff, 0xff, 0xff}, "hi"...), "", []byte("hi"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, ok := readString(tt.in)
+			if got != tt.want || ok != tt.ok || !bytes.Equal(rest, tt.rest) {
+				t.Errorf("readString(%v) = %q, %v, %v, want %q, %v, %v", tt.in, got, rest, ok, tt.want, tt.rest, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSFTPServeBadLength(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want error
+	}{
+		{"zero length", []byte{0, 0, 0, 0}, errSFTPBadMessage},
+		{"too long", appendUint32(nil, sftpMaxPacket+1), errSFTPBadMessage},
+		{"cut off", []byte{0, 0, 0, 9, sftpInit}, io.ErrUnexpectedEOF},
+		{"no id", []byte{0, 0, 0, 3, sftpStat, 0, 0}, errSFTPBadMessage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			srv := &sftpServer{w: &out, handles: map[string]*sftpOpenFile{}}
+			if err := srv.serve(bytes.NewReader(tt.in)); !errors.Is(err, tt.want) {
+				t.Errorf("serve = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// sftpReply splits the first packet in b into its type and payload.
+func sftpReply(t *testing.T, b *bytes.Buffer) (byte, []byte) {
+	t.Helper()
+	n, _, ok := readUint32(b.Next(4))
+	if !ok || n == 0 || int(n) > b.Len() {
+		t.Fatalf("no reply")
+	}
+	pkt := b.Next(int(n))
+	return pkt[0], pkt[1:]
+}
+
+func TestSFTPHandle(t *testing.T) {
+	now := time.Now()
+	srv := &sftpServer{handles: map[string]*sftpOpenFile{}, tree: map[string]*sftpFile{
+		"/":      {name: "/", dir: true, modTime: now, children: []string{"a.txt"}},
+		"/a.txt": {name: "a.txt", data: []byte("hello"), modTime: now},
+	}}
+	var out bytes.Buffer
+	srv.w = &out
+	open := appendUint32(appendString(appendUint32(nil, 1), "/a.txt"), sftpOpenRead)
+	open = appendUint32(open, 0)
+	if err := srv.handle(sftpOpen, open); err != nil {
+		t.Fatal(err)
+	}
+	typ, p := sftpReply(t, &out)
+	if typ != sftpHandle {
+		t.Fatalf("open replied with %d", typ)
+	}
+	_, p, _ = readUint32(p)
+	handle, _, _ := readString(p)
+
+	read := func(offset uint64, length uint32) []byte {
+		b := appendString(appendUint32(nil, 2), handle)
+		return appendUint32(appendUint64(b, offset), length)
+	}
+	tests := []struct {
+		name   string
+		typ    byte
+		packet []byte
+		// want is the data read, or the status code if the reply is a
+		// status
+		want     string
+		wantCode uint32
+	}{
+		{"read", sftpRead, read(0, 3), "hel", 0},
+		{"read to the end", sftpRead, read(3, 100), "lo", 0},
+		{"read at the end", sftpRead, read(5, 1), "", sftpEOF},
+		{"read past the end", sftpRead, read(6, 1), "", sftpEOF},
+		{"read far past the end", sftpRead, read(1<<63, 1), "", sftpEOF},
+		{"read without length", sftpRead, appendUint64(appendString(appendUint32(nil, 2), handle), 0), "", sftpFailure},
+		{"read unknown handle", sftpRead, appendUint32(appendUint64(appendString(appendUint32(nil, 2), "9"), 0), 1), "", sftpFailure},
+		{"stat without path", sftpStat, appendUint32(nil, 3), "", sftpBadMessage},
+		{"stat with bad length", sftpStat, append(appendUint32(appendUint32(nil, 3), 10), "/a"...), "", sftpBadMessage},
+		{"stat missing file", sftpStat, appendString(appendUint32(nil, 3), "/b.txt"), "", sftpNoSuchFile},
+		{"open for writing", sftpOpen, appendUint32(appendString(appendUint32(nil, 4), "/a.txt"), 0x2), "", sftpPermissionDenied},
+		{"open a directory", sftpOpen, appendUint32(appendString(appendUint32(nil, 4), "/"), sftpOpenRead), "", sftpFailure},
+		{"write", 6, appendUint32(nil, 5), "", sftpOpUnsupported},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			if err := srv.handle(tt.typ, tt.packet); err != nil {
+				t.Fatal(err)
+			}
+			typ, p := sftpReply(t, &out)
+			_, p, _ = readUint32(p)
+			switch typ {
+			case sftpData:
+				data, _, _ := readString(p)
+				if tt.wantCode != 0 || data != tt.want {
+					t.Errorf("got data %q, want %q, status %d", data, tt.want, tt.wantCode)
+				}
+			case sftpStatus:
+				code, _, _ := readUint32(p)
+				if code != tt.wantCode {
+					t.Errorf("got status %d, want %d", code, tt.wantCode)
+				}
+			default:
+				t.Errorf("got reply %d", typ)
+			}
+		})
+	}
+}
+
+// inTempDir runs the rest of the test in a new directory, where the server
+// keeps its files.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
+// testSSHServer starts the SSH server on a free port and returns its
+// address.
+func testSSHServer(t *testing.T) string {
+	t.Helper()
+	c := defaultConfig()
+	c.HostKeyPath = "host_key"
+	srv, err := newSSHServer(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() { _ = srv.Serve(l) }()
+	t.Cleanup(func() { _ = srv.Close() })
+	return l.Addr().String()
+}
+
+// testSigner returns a new client key.
+func testSigner(t *testing.T) gossh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// sftpClient speaks just enough SFTP to list and read files.
+type sftpClient struct {
+	t   *testing.T
+	in  io.Writer
+	out io.Reader
+	id  uint32
+}
+
+func dialSFTP(t *testing.T, addr, user string, signer gossh.Signer) *sftpClient {
+	t.Helper()
+	conn, err := gossh.Dial("tcp", addr, &gossh.ClientConfig{
+		User:            user,
+		Auth:            []gossh.AuthMethod{gossh.PublicKeys(signer)},
+		HostKeyCallback: gossh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	sess, err := conn.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	in, err := sess.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := sess.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.RequestSubsystem("sftp"); err != nil {
+		t.Fatal(err)
+	}
+	c := &sftpClient{t: t, in: in, out: out}
+	if typ, _ := c.request(sftpInit, appendUint32(nil, 3)); typ != sftpVersion {
+		t.Fatalf("init replied with %d", typ)
+	}
+	return c
+}
+
+// request sends a packet of type typ and returns the reply without its id.
+func (c *sftpClient) request(typ byte, payload []byte) (byte, []byte) {
+	c.t.Helper()
+	if typ != sftpInit {
+		c.id++
+		payload = append(appendUint32(nil, c.id), payload...)
+	}
+	if _, err := c.in.Write(append(append(appendUint32(nil, uint32(len(payload)+1)), typ), payload...)); err != nil {
+		c.t.Fatal(err)
+	}
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.out, header); err != nil {
+		c.t.Fatal(err)
+	}
+	n, _, _ := readUint32(header)
+	pkt := make([]byte, n)
+	if _, err := io.ReadFull(c.out, pkt); err != nil {
+		c.t.Fatal(err)
+	}
+	if pkt[0] == sftpVersion {
+		return pkt[0], pkt[1:]
+	}
+	return pkt[0], pkt[5:]
+}
+
+// handle opens name with the request typ and returns its handle.
+func (c *sftpClient) handle(typ byte, name string) string {
+	c.t.Helper()
+	payload := appendString(nil, name)
+	if typ == sftpOpen {
+		payload = appendUint32(appendUint32(payload, sftpOpenRead), 0)
+	}
+	reply, p := c.request(typ, payload)
+	if reply != sftpHandle {
+		c.t.Fatalf("opening %s replied with %d", name, reply)
+	}
+	h, _, _ := readString(p)
+	return h
+}
+
+// list returns the names in the directory name.
+func (c *sftpClient) list(name string) []string {
+	c.t.Helper()
+	h := c.handle(sftpOpendir, name)
+	var names []string
+	for {
+		typ, p := c.request(sftpReaddir, appendString(nil, h))
+		if typ != sftpName {
+			break
+		}
+		n, p, _ := readUint32(p)
+		for i := uint32(0); i < n; i++ {
+			var name string
+			name, p, _ = readString(p)
+			_, p, _ = readString(p)
+			// size, permissions and times
+			p = p[4+8+4+8:]
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// read returns the contents of the file name.
+func (c *sftpClient) read(name string) string {
+	c.t.Helper()
+	h := c.handle(sftpOpen, name)
+	var b strings.Builder
+	for {
+		typ, p := c.request(sftpRead, appendUint32(appendUint64(appendString(nil, h), uint64(b.Len())), sftpMaxRead))
+		if typ != sftpData {
+			return b.String()
+		}
+		data, _, _ := readString(p)
+		b.WriteString(data)
+	}
+}
+
+func TestSFTPOverSSH(t *testing.T) {
+	inTempDir(t)
+	alice, bob := testSigner(t), testSigner(t)
+	key := gossh.FingerprintSHA256(alice.PublicKey())
+
+	g := games.create(variant.MustGet(variant.Default))
+	g.seat(1, "alice", key)
+	g.seat(2, "", "engine")
+	playOut(t, g)
+
+	addr := testSSHServer(t)
+	c := dialSFTP(t, addr, "alice", alice)
+	want := []string{g.id + ".cast", g.id + ".json"}
+	if got := c.list("replays/"); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("replays of alice = %v, want %v", got, want)
+	}
+	if got := c.read("replays/" + g.id + ".json"); !strings.Contains(got, `"id": "`+g.id+`"`) {
+		t.Errorf("replay of alice = %q", got)
+	}
+	if got := c.read("/exports/player.json"); !strings.Contains(got, `"name": "alice"`) {
+		t.Errorf("results of alice = %q", got)
+	}
+
+	// the same login with another key sees none of it
+	c = dialSFTP(t, addr, "alice", bob)
+	if got := c.list("replays"); len(got) != 0 {
+		t.Errorf("replays of another key = %v", got)
+	}
+	if got := c.read("/exports/player.json"); !strings.Contains(got, `"wins": 0`) || !strings.Contains(got, `"losses": 0`) {
+		t.Errorf("results of another key = %q", got)
+	}
+}
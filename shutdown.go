@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/charmbracelet/ssh"
+)
+
+// shutdownMsg tells a session how much time is left until the server stops.
+type shutdownMsg time.Duration
+
+type savedGame struct {
+	ID      string       `json:"id"`
+	Variant string       `json:"variant"`
+	Field   [][]bool     `json:"field"`
+	Player  int          `json:"player"`
+	Moves   int          `json:"moves"`
+	Players [2]string    `json:"players"`
+	Owners  [2]string    `json:"owners,omitempty"`
+	Start   [][]bool     `json:"start,omitempty"`
+	History []moveRecord `json:"history"`
+	SavedAt time.Time    `json:"saved_at"`
+}
+
+// saveGame writes g to dir.
+func saveGame(dir string, g savedGame) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, g.ID+".json"), b, 0o644)
+}
+
+// saveGames writes all games in progress to dir.
+func saveGames(dir string) {
+	for _, g := range games.all() {
+		state := g.state()
+		if state.finished() {
+			continue
+		}
+		sg := savedGame{
+			ID:      state.id,
+			Variant: state.variant.Name(),
+			Field:   state.field,
+			Player:  state.player,
+			Moves:   state.moves,
+			Players: state.players,
+			Owners:  state.owners,
+			Start:   state.start,
+			History: state.history,
+			SavedAt: time.Now(),
+		}
+		if err := saveGame(dir, sg); err != nil {
+			log.Printf("Could not save game %s: %v", state.id, err)
+		}
+	}
+}
+
+// loadGames restores the games saved in dir and removes their files.
+func loadGames(dir string) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		log.Printf("Could not load saved games: %v", err)
+		return
+	}
+	restored := 0
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Could not load saved game %s: %v", path, err)
+			continue
+		}
+		var sg savedGame
+		if err := json.Unmarshal(b, &sg); err != nil {
+			log.Printf("Could not load saved game %s: %v", path, err)
+			continue
+		}
+		if _, err := games.restore(sg); err != nil {
+			log.Printf("Could not restore saved game %s: %v", path, err)
+			continue
+		}
+		restored++
+		if err := os.Remove(path); err != nil {
+			log.Printf("Could not remove saved game %s: %v", path, err)
+		}
+	}
+	if restored > 0 {
+		log.Printf("Restored %d saved games", restored)
+	}
+}
+
+// gracefulShutdown stops accepting new sessions, counts down in all active
+// sessions, saves the games in progress and finally shuts the server down.
+// Another signal on done skips the countdown.
+func gracefulShutdown(s *ssh.Server, done <-chan os.Signal) {
+	sessions.close()
+
+	deadline := time.Now().Add(shutdownCountdown)
+	ticker := time.NewTicker(time.Second)
+countdown:
+	for remaining := shutdownCountdown; remaining > 0 && sessions.len() > 0; remaining = time.Until(deadline) {
+		sessions.broadcast(shutdownMsg(remaining))
+		select {
+		case <-ticker.C:
+		case <-done:
+			log.Println("Skipping shutdown countdown")
+			break countdown
+		}
+	}
+	ticker.Stop()
+
+	saveGames(stateDir)
+	if err := savePlayers(playersFile); err != nil {
+		log.Printf("Could not save players: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer func() { cancel() }()
+	err := s.Shutdown(ctx)
+	tracing.flush()
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
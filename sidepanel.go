@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+const (
+	// sidePanelWidth is the width of the moves next to the board.
+	sidePanelWidth = 34
+	// sidePanelGap is the space between the board and the moves.
+	sidePanelGap = 6
+)
+
+// sidePanel reports whether the screen is wide enough to show the moves
+// and the analysis next to the board instead of only the board.
+func (m model) sidePanel() bool {
+	if m.reader {
+		return false
+	}
+	return m.contentWidth() >= lipgloss.Width(m.boardBlock())+sidePanelGap+sidePanelWidth
+}
+
+// mainBlock is what is shown between the header and the status bar while
+// playing: the board, and on wide screens the moves to the right of it.
+func (m model) mainBlock() string {
+	board := m.boardBlock()
+	if !m.sidePanel() {
+		return board
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, board, strings.Repeat(" ", sidePanelGap), m.sidePanelView(lipgloss.Height(board)))
+}
+
+// sidePanelView lists the last moves that fit in height lines with the time
+// they were made, the newest at the bottom, and the nim-sum below them if
+// the player wants the analysis.
+func (m model) sidePanelView(height int) string {
+	l := m.lang
+	var analysis []string
+	if m.prefs.Analysis && !m.state.finished() {
+		analysis = []string{"", m.theme.title.Render(l.tr("Analysis")), m.theme.text.Render(m.nimSumText())}
+	}
+	var lines []string
+	if opponent := m.opponentView(); opponent != "" {
+		lines = append(strings.Split(opponent, "\n"), "")
+	}
+	lines = append(lines, m.theme.title.Render(l.tr("Moves")))
+	history := m.state.history
+	if len(history) == 0 {
+		lines = append(lines, m.theme.dim.Render(l.tr("No moves yet.")))
+	}
+	if n := height - len(lines) - len(analysis); len(history) > n && n > 0 {
+		history = history[len(history)-n:]
+	}
+	first := len(m.state.history) - len(history)
+	// the time first keeps the moves aligned, also with hours of one digit
+	clockWidth := len(time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC).Format(m.formats.clock))
+	for i, mv := range history {
+		at := mv.At.In(m.location).Format(m.formats.clock)
+		lines = append(lines, m.theme.dim.Render(fmt.Sprintf("%3d %*s ", first+i+1, clockWidth, at))+m.theme.text.Render(m.describeMove(mv)))
+	}
+	lines = append(lines, analysis...)
+	return lipgloss.NewStyle().MaxWidth(sidePanelWidth).Render(strings.Join(lines, "\n"))
+}
+
+// describeMove is a move in the list next to the board, e.g. "You  b2–d2".
+func (m model) describeMove(mv moveRecord) string {
+	l := m.lang
+	who := l.trf("Player %d", mv.Player)
+	switch {
+	case m.you == 0:
+	case mv.Player == m.you:
+		who = l.tr("You")
+	default:
+		who = l.tr("Opponent")
+	}
+	what := l.trf("%d from row %d", mv.count(), mv.Row+1)
+	if m.board.Labels {
+		what = cellRange(mv.Row, mv.From, mv.To)
+	}
+	return who + "  " + what
+}
@@ -0,0 +1,115 @@
+package main
+
+// move takes the objects in columns from to to (inclusive) of row, skipping
+// empty cells.
+type move struct {
+	row  int
+	from int
+	to   int
+}
+
+// count returns how many objects mv takes from field.
+func (mv move) count(field [][]bool) int {
+	n := 0
+	for col := mv.from; col <= mv.to; col++ {
+		if field[mv.row][col] {
+			n++
+		}
+	}
+	return n
+}
+
+// legalMoves returns all moves in field. A move takes the objects between
+// two objects of one row and may not take the last object on the board.
+func legalMoves(field [][]bool) []move {
+	left := available(field)
+	var moves []move
+	for row, cols := range field {
+		for from, avail := range cols {
+			if !avail {
+				continue
+			}
+			n := 0
+			for to := from; to < len(cols); to++ {
+				if !cols[to] {
+					continue
+				}
+				n++
+				if n < left {
+					moves = append(moves, move{row, from, to})
+				}
+			}
+		}
+	}
+	return moves
+}
+
+// heaps returns the number of objects in each row of field. Since a move
+// can take any objects of a row, rows are the heaps of Nim.
+func heaps(field [][]bool) []int {
+	hs := make([]int, len(field))
+	for row, cols := range field {
+		for _, avail := range cols {
+			if avail {
+				hs[row]++
+			}
+		}
+	}
+	return hs
+}
+
+// nimSum is the XOR of all heap sizes.
+func nimSum(field [][]bool) int {
+	sum := 0
+	for _, h := range heaps(field) {
+		sum ^= h
+	}
+	return sum
+}
+
+// solve returns the best move for the player to move and whether that
+// player can force a win. The player who has to take the last object loses,
+// so this is misère Nim on the rows.
+// ok is false if there is no legal move, i.e. the game is over.
+func solve(field [][]bool) (best move, winning bool, ok bool) {
+	if len(legalMoves(field)) == 0 {
+		return move{}, false, false
+	}
+	row, take, winning := nimMove(heaps(field), true)
+	return takeFromEnd(field, row, take), winning, true
+}
+
+// takeFromEnd returns the move that takes the last n objects of row.
+func takeFromEnd(field [][]bool, row, n int) move {
+	mv := move{row: row, from: -1, to: -1}
+	for col := len(field[row]) - 1; col >= 0 && n > 0; col-- {
+		if !field[row][col] {
+			continue
+		}
+		if mv.to < 0 {
+			mv.to = col
+		}
+		mv.from = col
+		n--
+	}
+	return mv
+}
+
+// winningMoves returns a move for every number of objects that can be taken
+// from a row such that the opponent can't force a win, none if the position
+// is lost.
+func winningMoves(field [][]bool) []move {
+	hs := heaps(field)
+	left := available(field)
+	var won []move
+	for row, h := range hs {
+		for take := 1; take <= h && take < left; take++ {
+			hs[row] -= take
+			if _, _, winning := nimMove(hs, true); !winning {
+				won = append(won, takeFromEnd(field, row, take))
+			}
+			hs[row] += take
+		}
+	}
+	return won
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// splashDuration is how long the splash screen is shown if no key is
+// pressed.
+const splashDuration = 2 * time.Second
+
+// splashDoneMsg ends the splash screen.
+type splashDoneMsg struct{}
+
+// logo is "NIMM" in the standard font of figlet.
+const logo = ` _   _ ___ __  __ __  __
+| \ | |_ _|  \/  |  \/  |
+|  \| || || |\/| | |\/| |
+| |\  || || |  | | |  | |
+|_| \_|___|_|  |_|_|  |_|`
+
+// splashView shows the logo and the tagline of the server in the middle of
+// the screen.
+func (m model) splashView() string {
+	// the lines of the logo are padded to the same width, so that they stay
+	// in place when they are centered
+	blocks := []string{m.theme.title.Copy().Width(lipgloss.Width(logo)).Render(logo), ""}
+	if m.tagline != "" {
+		blocks = append(blocks, m.theme.text.Render(m.tagline), "")
+	}
+	blocks = append(blocks, m.theme.dim.Render(m.lang.tr("press any key")))
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Center, blocks...))
+}
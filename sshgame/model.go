@@ -0,0 +1,105 @@
+package sshgame
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/board"
+	"github.com/jheuel/nimm/variant"
+)
+
+var (
+	titleStyle = lipgloss.NewStyle().Bold(true)
+	helpStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("#626262"))
+)
+
+// moveMsg is a move submitted on the board.
+type moveMsg variant.Move
+
+// Model is a game of Nim for two players on one keyboard. It can also be
+// embedded in other Bubble Tea programs.
+type Model struct {
+	v      variant.Variant
+	field  variant.Board
+	board  board.Model
+	player int
+	width  int
+	height int
+	err    error
+}
+
+// New returns a new game of v for a screen of the given size.
+func New(v variant.Variant, width, height int) Model {
+	field := v.Setup()
+	b := board.New(field, v.Hints())
+	b.OnMove = func(mv variant.Move) tea.Cmd {
+		return func() tea.Msg { return moveMsg(mv) }
+	}
+	return Model{v: v, field: field, board: b, player: 1, width: width, height: height}
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+// Finished reports whether the game is over. The player to move has lost.
+func (m Model) Finished() bool {
+	return m.v.Lost(m.field)
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case moveMsg:
+		field, err := m.v.Apply(m.field, variant.Move(msg))
+		if err != nil {
+			m.err = err
+			return m, nil
+		}
+		m.field = field
+		m.board.SetBoard(field)
+		m.board.Reset()
+		m.player = m.player%2 + 1
+	case tea.KeyMsg:
+		m.err = nil
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "enter":
+			if m.Finished() {
+				return New(m.v, m.width, m.height), nil
+			}
+		}
+		if m.Finished() {
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.board, cmd = m.board.Update(msg)
+		return m, cmd
+	}
+	return m, nil
+}
+
+func (m Model) status() string {
+	if m.Finished() {
+		return fmt.Sprintf("Player %d lost, enter starts a new game", m.player)
+	}
+	if m.err != nil {
+		return fmt.Sprintf("Player %d: %v", m.player, m.err)
+	}
+	return fmt.Sprintf("Player %d's turn", m.player)
+}
+
+func (m Model) View() string {
+	s := lipgloss.JoinVertical(lipgloss.Center,
+		titleStyle.Render("== Nim =="),
+		"",
+		m.status(),
+		"",
+		m.board.View(),
+		helpStyle.Render("arrows move • space selects • enter takes • q quits"),
+	)
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, s)
+}
@@ -0,0 +1,81 @@
+// Package sshgame serves Nim over SSH as a wish middleware, so that it can be
+// mounted next to other apps on the same server:
+//
+//	s, err := wish.NewServer(
+//		wish.WithAddress(":2222"),
+//		wish.WithMiddleware(
+//			sshgame.Middleware(sshgame.Options{Command: "nim"}),
+//			myAppMiddleware(),
+//		),
+//	)
+//
+// Sessions running `ssh -t host nim` then play, all others are passed on.
+// Both players share the keyboard. The nimm server builds shared games,
+// engines and accounts on top of the same rules.
+package sshgame
+
+import (
+	"errors"
+	"log"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish"
+	"github.com/jheuel/nimm/variant"
+)
+
+// ErrNoTerminal is shown to sessions that start the game without a terminal.
+var ErrNoTerminal = errors.New("the game needs a terminal, try ssh -t")
+
+// Options configure the middleware.
+type Options struct {
+	// Command starts the game, e.g. "nim" for `ssh -t host nim`. If it is
+	// empty, sessions without a command play.
+	Command string
+	// Variant is the name of the rules, variant.Default if empty. The
+	// middleware panics if it is not registered.
+	Variant string
+}
+
+// Middleware plays Nim in the sessions that ask for it and passes all other
+// sessions on to the next handler.
+func Middleware(opts Options) wish.Middleware {
+	if opts.Variant == "" {
+		opts.Variant = variant.Default
+	}
+	v := variant.MustGet(opts.Variant)
+	return func(sh ssh.Handler) ssh.Handler {
+		return func(s ssh.Session) {
+			cmd := s.Command()
+			if opts.Command == "" && len(cmd) > 0 || opts.Command != "" && (len(cmd) == 0 || cmd[0] != opts.Command) {
+				sh(s)
+				return
+			}
+			pty, windows, active := s.Pty()
+			if !active {
+				wish.Fatalln(s, ErrNoTerminal)
+				return
+			}
+			p := tea.NewProgram(New(v, pty.Window.Width, pty.Window.Height),
+				tea.WithInput(s),
+				tea.WithOutput(s),
+				tea.WithAltScreen(),
+			)
+			go func() {
+				for {
+					select {
+					case <-s.Context().Done():
+						p.Quit()
+						return
+					case w := <-windows:
+						p.Send(tea.WindowSizeMsg{Width: w.Width, Height: w.Height})
+					}
+				}
+			}()
+			if _, err := p.Run(); err != nil {
+				log.Printf("Nim session of %s: %v", s.User(), err)
+			}
+			_ = s.Exit(0)
+		}
+	}
+}
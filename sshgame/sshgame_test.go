@@ -0,0 +1,135 @@
+package sshgame
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/ssh"
+	"github.com/charmbracelet/wish/testsession"
+	"github.com/jheuel/nimm/variant"
+)
+
+// testServer serves the middleware with opts in front of a handler that
+// writes "next".
+func testServer(t *testing.T, opts Options) string {
+	t.Helper()
+	srv := &ssh.Server{Handler: Middleware(opts)(func(s ssh.Session) {
+		_, _ = io.WriteString(s, "next")
+	})}
+	return testsession.Listen(t, srv)
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		cmd     string
+		// wantNext is set if the session goes on to the next handler
+		wantNext bool
+	}{
+		{"no command", "", "", false},
+		{"other command without one configured", "", "ls", true},
+		{"command", "nim", "nim", false},
+		{"other command", "nim", "ls", true},
+		{"no command with one configured", "nim", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := testServer(t, Options{Command: tt.command})
+			sess, err := testsession.NewClientSession(t, addr, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out bytes.Buffer
+			sess.Stdout, sess.Stderr = &out, &out
+			if tt.cmd == "" {
+				err = sess.Shell()
+				if err == nil {
+					err = sess.Wait()
+				}
+			} else {
+				err = sess.Run(tt.cmd)
+			}
+			if tt.wantNext {
+				if err != nil || out.String() != "next" {
+					t.Errorf("session wrote %q, %v, want it passed on", out.String(), err)
+				}
+				return
+			}
+			// without a terminal the game can't start
+			if err == nil || !strings.Contains(out.String(), ErrNoTerminal.Error()) {
+				t.Errorf("session wrote %q, %v, want %v", out.String(), err, ErrNoTerminal)
+			}
+		})
+	}
+}
+
+func TestMiddlewarePlays(t *testing.T) {
+	addr := testServer(t, Options{Command: "nim"})
+	sess, err := testsession.NewClientSession(t, addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.RequestPty("xterm", 24, 80, nil); err != nil {
+		t.Fatal(err)
+	}
+	in, err := sess.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	sess.Stdout = &out
+	if err := sess.Start("nim"); err != nil {
+		t.Fatal(err)
+	}
+	// let the game draw before leaving it
+	time.Sleep(100 * time.Millisecond)
+	if _, err := io.WriteString(in, "q"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sess.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "== Nim ==") {
+		t.Errorf("the game wasn't shown: %q", out.String())
+	}
+}
+
+func TestModel(t *testing.T) {
+	m := New(variant.MustGet(variant.Default), 80, 24)
+	update := func(msg tea.Msg) {
+		t.Helper()
+		next, _ := m.Update(msg)
+		m = next.(Model)
+	}
+
+	update(moveMsg{Row: 0, From: 0, To: 0})
+	if m.err == nil || !strings.Contains(m.status(), "Player 1: ") {
+		t.Errorf("status after an invalid move = %q", m.status())
+	}
+	// the error stays until the next key
+	update(tea.KeyMsg{Type: tea.KeyRight})
+	update(moveMsg{Row: 0, From: 3, To: 3})
+	if m.player != 2 || m.status() != "Player 2's turn" {
+		t.Errorf("status after a move = %q", m.status())
+	}
+
+	// take everything but the last object
+	update(moveMsg{Row: 1, From: 2, To: 4})
+	update(moveMsg{Row: 2, From: 1, To: 5})
+	update(moveMsg{Row: 3, From: 1, To: 6})
+	if !m.Finished() || m.status() != "Player 1 lost, enter starts a new game" {
+		t.Fatalf("status at the end = %q", m.status())
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if m = next.(Model); m.Finished() || m.player != 1 {
+		t.Errorf("enter didn't start a new game: %q", m.status())
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")}); cmd == nil {
+		t.Error("q didn't quit")
+	}
+}
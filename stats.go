@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// statsWindow is the interval over which session rates are compared against
+// the configured thresholds.
+const statsWindow = time.Minute
+
+// sessionStats accounts for the resources a session uses. Every write of the
+// program is one rendered frame, even if the throttled output merges or
+// drops it.
+type sessionStats struct {
+	bytesSent int64
+	renders   int64
+	maxFrame  int64
+	lastFrame int64
+
+	// counters for the current window
+	windowBytes   int64
+	windowRenders int64
+	flagged       int32
+}
+
+// totalBytesSent and totalRenders count the bytes and frames of all
+// sessions since the start, for the metrics.
+var totalBytesSent, totalRenders int64
+
+// sent counts n bytes sent to the terminal.
+func (st *sessionStats) sent(n int) {
+	atomic.AddInt64(&st.bytesSent, int64(n))
+	atomic.AddInt64(&st.windowBytes, int64(n))
+	atomic.AddInt64(&totalBytesSent, int64(n))
+}
+
+// rendered counts a frame of n bytes written by the program.
+func (st *sessionStats) rendered(n int) {
+	atomic.AddInt64(&st.renders, 1)
+	atomic.AddInt64(&st.windowRenders, 1)
+	atomic.AddInt64(&totalRenders, 1)
+	atomic.StoreInt64(&st.lastFrame, int64(n))
+	for {
+		max := atomic.LoadInt64(&st.maxFrame)
+		if int64(n) <= max || atomic.CompareAndSwapInt64(&st.maxFrame, max, int64(n)) {
+			return
+		}
+	}
+}
+
+// statsSnapshot is a consistent copy of sessionStats.
+type statsSnapshot struct {
+	bytesSent int64
+	renders   int64
+	maxFrame  int64
+	flagged   bool
+	// memory is an estimate of what the session holds, see session.memory
+	memory int64
+}
+
+func (st *sessionStats) snapshot() statsSnapshot {
+	return statsSnapshot{
+		bytesSent: atomic.LoadInt64(&st.bytesSent),
+		renders:   atomic.LoadInt64(&st.renders),
+		maxFrame:  atomic.LoadInt64(&st.maxFrame),
+		flagged:   atomic.LoadInt32(&st.flagged) == 1,
+	}
+}
+
+// statsSnapshot returns a snapshot of the resources sess uses.
+func (sess *session) statsSnapshot() statsSnapshot {
+	st := sess.stats.snapshot()
+	st.memory = sess.memory()
+	return st
+}
+
+// memory estimates the bytes sess holds beyond the model: the output that
+// waits for a slow client and the last frame, which the renderer keeps to
+// compare the next one against.
+func (sess *session) memory() int64 {
+	n := atomic.LoadInt64(&sess.stats.lastFrame)
+	if sess.out != nil {
+		n += int64(sess.out.queued())
+	}
+	return n
+}
+
+// countingWriter counts everything written to the session.
+type countingWriter struct {
+	w     io.Writer
+	stats *sessionStats
+}
+
+func (c countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.stats.sent(n)
+	return n, err
+}
+
+// frameWriter counts the frames the program writes, before the throttled
+// output merges them.
+type frameWriter struct {
+	w     io.Writer
+	stats *sessionStats
+}
+
+func (f frameWriter) Write(b []byte) (int, error) {
+	f.stats.rendered(len(b))
+	return f.w.Write(b)
+}
+
+// monitorSessions flags sessions that exceed the configured per-minute
+// thresholds for bytes sent or renders, or the one for their memory. A
+// flagged session stays flagged.
+func monitorSessions() {
+	for range time.Tick(statsWindow) {
+		checkSessions(currentConfig())
+	}
+}
+
+// checkSessions flags the sessions that exceeded a threshold of c in the
+// last window and starts the next one.
+func checkSessions(c config) {
+	for _, sess := range sessions.all() {
+		st := &sess.stats
+		bytes := atomic.SwapInt64(&st.windowBytes, 0)
+		renders := atomic.SwapInt64(&st.windowRenders, 0)
+		memory := sess.memory()
+		exceeded := (c.FlagBytesPerMinute > 0 && bytes > c.FlagBytesPerMinute) ||
+			(c.FlagRendersPerMinute > 0 && renders > c.FlagRendersPerMinute) ||
+			(c.FlagMemoryBytes > 0 && memory > c.FlagMemoryBytes)
+		if exceeded && atomic.CompareAndSwapInt32(&st.flagged, 0, 1) {
+			log.Printf("Flagged session %s (%s): %d bytes and %d renders in the last minute, holding about %d bytes",
+				shortID(sess.id), logAddr(sess.addr), bytes, renders, memory)
+		}
+	}
+}
+
+// metricsHandler serves server metrics in the Prometheus text format. It
+// shares the listener with the public status page, so the metrics of single
+// sessions and their players are only in the admin dashboard.
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	all := sessions.all()
+	var flagged, memory, maxFrame int64
+	for _, sess := range all {
+		st := sess.statsSnapshot()
+		if st.flagged {
+			flagged++
+		}
+		memory += st.memory
+		if st.maxFrame > maxFrame {
+			maxFrame = st.maxFrame
+		}
+	}
+
+	metrics := []struct {
+		name, help, kind string
+		value            int64
+	}{
+		{"nimm_sessions", "Number of connected sessions.", "gauge", int64(len(all))},
+		{"nimm_sessions_flagged", "Connected sessions that exceeded a resource threshold.", "gauge", flagged},
+		{"nimm_sessions_memory_bytes", "Estimated memory held by the connected sessions.", "gauge", memory},
+		{"nimm_sessions_max_frame_bytes", "Size of the largest frame rendered for a connected session.", "gauge", maxFrame},
+		{"nimm_bytes_sent_total", "Bytes sent to all sessions.", "counter", atomic.LoadInt64(&totalBytesSent)},
+		{"nimm_renders_total", "Frames rendered for all sessions.", "counter", atomic.LoadInt64(&totalRenders)},
+	}
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+		fmt.Fprintf(w, "%s %d\n", m.name, m.value)
+	}
+}
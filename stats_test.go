@@ -0,0 +1,49 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFlagSessionMemory(t *testing.T) {
+	sess := &session{id: "memory", user: "alice", addr: &net.TCPAddr{}}
+	// a client that doesn't read keeps the output queued
+	r, w := io.Pipe()
+	sess.out = newThrottledWriter(w, 1<<20, 1)
+	defer sess.out.close()
+	defer r.Close()
+	if err := sessions.add(sess, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer sessions.remove(sess.id)
+
+	frame := strings.Repeat("x", 1000)
+	frameWriter{w: sess.out, stats: &sess.stats}.Write([]byte(frame))
+	frameWriter{w: sess.out, stats: &sess.stats}.Write([]byte(frame))
+	// the first frame may already be on its way
+	if got := sess.memory(); got < 2000 || got > 3000 {
+		t.Errorf("memory = %d, want the queued output and the last frame", got)
+	}
+
+	checkSessions(config{FlagMemoryBytes: 10000})
+	if sess.stats.snapshot().flagged {
+		t.Error("flagged below the threshold")
+	}
+	checkSessions(config{FlagMemoryBytes: 1500})
+	if !sess.statsSnapshot().flagged {
+		t.Error("not flagged above the threshold")
+	}
+
+	rec := httptest.NewRecorder()
+	metricsHandler(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, "nimm_sessions_flagged ") {
+		t.Errorf("metrics without the flagged sessions:\n%s", body)
+	}
+	if strings.Contains(body, "{") || strings.Contains(body, "alice") {
+		t.Errorf("metrics with labels of the sessions:\n%s", body)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+)
+
+//go:embed web/status.html
+var statusPage string
+
+var statusTemplate = template.Must(template.New("status").Parse(statusPage))
+
+// serverStarted is when the server was started, for the uptime.
+var serverStarted = time.Now()
+
+// serverStatus is what the status page shows about the server.
+type serverStatus struct {
+	// Up is set while the server accepts new sessions.
+	Up bool `json:"up"`
+	// Players is how many sessions are connected.
+	Players int `json:"players"`
+	// Games is how many games are being played.
+	Games   int       `json:"games"`
+	Started time.Time `json:"started"`
+	// Uptime is how long the server has been running, in seconds.
+	Uptime  int64  `json:"uptime"`
+	Version string `json:"version"`
+}
+
+func currentStatus() serverStatus {
+	playing := 0
+	for _, g := range games.all() {
+		// games are created when players connect, they count from the
+		// first move
+		if s := g.state(); s.moves > 0 && !s.finished() {
+			playing++
+		}
+	}
+	return serverStatus{
+		Up:      !sessions.isClosed(),
+		Players: sessions.len(),
+		Games:   playing,
+		Started: serverStarted,
+		Uptime:  int64(time.Since(serverStarted).Seconds()),
+		Version: version,
+	}
+}
+
+// statusHandler serves the status page, so that players can see whether
+// the server is up before connecting.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	s := currentStatus()
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := statusTemplate.Execute(w, struct {
+		serverStatus
+		Tagline string
+		Uptime  time.Duration
+	}{s, currentConfig().Tagline, time.Since(serverStarted).Round(time.Minute)})
+	if err != nil {
+		log.Printf("Could not write status page: %v", err)
+	}
+}
+
+// statusJSONHandler serves the status as JSON for bots and status
+// trackers.
+func statusJSONHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, currentStatus())
+}
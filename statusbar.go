@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/board"
+	"github.com/jheuel/nimm/variant"
+)
+
+// statusBar shows the state of the game in a line that is width wide: whose
+// turn it is, the move, the selection on the left and who is playing and
+// the clock on the right. The right side is left out if both don't fit.
+// While the opponent thinks, a spinner shows how long they have taken.
+func (m model) statusBar(width int) string {
+	l := m.lang
+	left := []string{m.status()}
+	if m.state.finished() {
+		left = append(left, l.trf("%d moves", m.state.moves))
+	} else {
+		left = append(left, l.trf("move %d", m.state.moves+1))
+	}
+	if m.level != 0 {
+		left = append(left, l.trf("level %d: %s", m.level, l.tr(campaignLevels[m.level-1].name)))
+	}
+	if m.opponentsTurn() {
+		// the spinner isn't styled, which would end the style of the bar
+		left[0] = m.spinner.View() + " " + left[0]
+		left = append(left, formatClock(m.waited()), l.trf("%s stops waiting", m.keys.Cancel.Help().Key))
+	}
+	if m.prefs.Analysis && !m.state.finished() && !m.sidePanel() {
+		// otherwise it is next to the board
+		left = append(left, m.nimSumText())
+	}
+	if sel, ok := m.board.Selection(); ok {
+		if m.board.Labels {
+			left = append(left, l.trf("taking %s", cellRange(sel.Row, sel.From, sel.To)))
+		} else {
+			left = append(left, l.trf("taking %d from row %d", variant.Taken(m.state.field, sel), sel.Row+1))
+		}
+	}
+	var right []string
+	if n := m.state.spectators; n > 0 {
+		right = append(right, l.trf("%d watching", n))
+	}
+	if name := m.displayName(); name != "" {
+		if campaignComplete(m.prefs.Campaign) {
+			name += " " + campaignBadge
+		}
+		right = append(right, name)
+	}
+	if m.opponent != nil {
+		right = append(right, l.trf("vs %s", m.opponent.name()))
+	} else if other := m.state.players[m.you%2]; m.you != 0 && other != "" {
+		right = append(right, l.trf("vs %s", other))
+	}
+	if m.gameTime {
+		right = append(right, m.gameTimeText())
+	}
+	if m.clock && !m.frameClock() {
+		right = append(right, m.clockText())
+	}
+
+	ls := " " + strings.Join(left, " • ") + " "
+	rs := ""
+	if len(right) > 0 {
+		rs = " " + strings.Join(right, " • ") + " "
+	}
+	gap := width - lipgloss.Width(ls) - lipgloss.Width(rs)
+	if gap < 0 {
+		rs, gap = "", width-lipgloss.Width(ls)
+	}
+	if gap < 0 {
+		gap = 0
+	}
+	return m.theme.bar.Copy().MaxWidth(width).Render(ls + strings.Repeat(" ", gap) + rs)
+}
+
+// cellRange names the cells from to to of row the way players do with the
+// labels, e.g. "b2–d2".
+func cellRange(row, from, to int) string {
+	cells := fmt.Sprintf("%s%d", board.ColumnName(from), row+1)
+	if to > from {
+		cells += fmt.Sprintf("–%s%d", board.ColumnName(to), row+1)
+	}
+	return cells
+}
+
+// nimSumText tells the nim-sum of the position and, if objects are
+// selected, the one after taking them.
+func (m model) nimSumText() string {
+	sum := nimSum(m.state.field)
+	sel, ok := m.board.Selection()
+	if !ok {
+		return m.lang.trf("nim-sum %d", sum)
+	}
+	field := make([][]bool, len(m.state.field))
+	for r, row := range m.state.field {
+		field[r] = append([]bool(nil), row...)
+	}
+	for c := sel.From; c <= sel.To; c++ {
+		field[sel.Row][c] = false
+	}
+	return m.lang.trf("nim-sum %d → %d", sum, nimSum(field))
+}
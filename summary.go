@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// sessionSummary sums up what the player did in their session.
+type sessionSummary struct {
+	games, wins, losses int
+	// moves are the moves of the player, of both players if they share the
+	// keyboard
+	moves int
+	// moveTime is how long the player took for a move on average
+	moveTime  time.Duration
+	connected time.Duration
+}
+
+// summary sums up the session until now.
+func (m model) summary() sessionSummary {
+	var s sessionSummary
+	if m.state.finished() && m.state.moves > 0 {
+		s.games = 1
+		switch winner := m.state.winner(); {
+		case m.you == 0 || m.watching:
+		case winner == m.you:
+			s.wins = 1
+		default:
+			s.losses = 1
+		}
+	}
+	var thinking time.Duration
+	since := m.game.created
+	for _, mv := range m.state.history {
+		if !m.watching && (m.you == 0 || mv.Player == m.you) {
+			s.moves++
+			thinking += mv.At.Sub(since)
+		}
+		since = mv.At
+	}
+	if s.moves > 0 {
+		s.moveTime = thinking / time.Duration(s.moves)
+	}
+	if s.connected = m.time.Sub(m.connected); s.connected < 0 {
+		s.connected = 0
+	}
+	return s
+}
+
+// openSummary shows the summary of the session, and quits with the next key
+// if leaving is set.
+func (m model) openSummary(leaving bool) (tea.Model, tea.Cmd) {
+	m.showSummary = true
+	m.leaving = leaving
+	m.time = time.Now()
+	return m, nil
+}
+
+// updateSummary handles a key on the summary: any key closes it, or quits
+// if the player is leaving.
+func (m model) updateSummary(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.showSummary = false
+	if m.leaving || msg.Type == tea.KeyCtrlC {
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// summaryView shows the summary of the session in a box.
+func (m model) summaryView() string {
+	t, l := m.theme, m.lang
+	s := m.summary()
+	rows := [][2]string{
+		{l.tr("Games played"), fmt.Sprint(s.games)},
+		{l.tr("Won / lost"), fmt.Sprintf("%d / %d", s.wins, s.losses)},
+		{l.tr("Moves"), fmt.Sprint(s.moves)},
+		{l.tr("Time per move"), formatClock(s.moveTime)},
+		{l.tr("Connected"), formatClock(s.connected)},
+	}
+	width := 0
+	for _, r := range rows {
+		if w := lipgloss.Width(r[0]); w > width {
+			width = w
+		}
+	}
+	var b strings.Builder
+	b.WriteString(t.title.Render(l.tr("Your session")) + "\n\n")
+	for _, r := range rows {
+		b.WriteString(t.text.Render(r[0]+strings.Repeat(" ", width-lipgloss.Width(r[0])+2)+r[1]) + "\n")
+	}
+	footer := l.tr("any key closes the summary")
+	if m.leaving {
+		footer = l.tr("Thanks for playing! Any key leaves.")
+	}
+	b.WriteString("\n" + t.dim.Render(footer))
+	return lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 2).Render(b.String()) + "\n"
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// telnet commands and options, see RFC 854, 1073 and 1091.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	telnetOptEcho  = 1
+	telnetOptSGA   = 3
+	telnetOptTType = 24
+	telnetOptNAWS  = 31
+
+	telnetTTypeIs   = 0
+	telnetTTypeSend = 1
+)
+
+// telnetNegotiationTimeout is how long we wait for the client to tell us
+// its terminal size and type before starting the game anyway.
+const telnetNegotiationTimeout = time.Second
+
+// serveTelnet accepts telnet connections on addr and plays a game on each.
+func serveTelnet(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go handleTelnet(c)
+	}
+}
+
+// telnetConn strips telnet commands from the input of a connection and
+// escapes its output. The client is asked to leave echo to us and to send
+// characters right away, which is what a raw terminal does over SSH.
+type telnetConn struct {
+	net.Conn
+	r *bufio.Reader
+
+	// the client's terminal, as far as it told us
+	mu      sync.Mutex
+	term    string
+	width   int
+	height  int
+	gotTerm bool
+	gotSize bool
+	resize  func(width, height int)
+
+	pending []byte
+	cr      bool
+}
+
+func newTelnetConn(c net.Conn) *telnetConn {
+	return &telnetConn{Conn: c, r: bufio.NewReader(c), term: "xterm", width: 80, height: 24}
+}
+
+// negotiate sets up the client's terminal and waits a moment for its size
+// and type.
+func (t *telnetConn) negotiate() error {
+	_, err := t.Conn.Write([]byte{
+		telnetIAC, telnetWILL, telnetOptEcho,
+		telnetIAC, telnetWILL, telnetOptSGA,
+		telnetIAC, telnetDO, telnetOptSGA,
+		telnetIAC, telnetDO, telnetOptNAWS,
+		telnetIAC, telnetDO, telnetOptTType,
+	})
+	if err != nil {
+		return err
+	}
+	if err := t.Conn.SetReadDeadline(time.Now().Add(telnetNegotiationTimeout)); err != nil {
+		return err
+	}
+	for !t.negotiated() {
+		b, ok, err := t.next()
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if ok {
+			// keep what the player typed early
+			t.pending = append(t.pending, b)
+		}
+	}
+	return t.Conn.SetReadDeadline(time.Time{})
+}
+
+func (t *telnetConn) negotiated() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.gotTerm && t.gotSize
+}
+
+// size returns the terminal type and size.
+func (t *telnetConn) size() (string, int, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.term, t.width, t.height
+}
+
+// onResize calls f whenever the client's window size changes.
+func (t *telnetConn) onResize(f func(width, height int)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.resize = f
+}
+
+func (t *telnetConn) Read(p []byte) (int, error) {
+	n := copy(p, t.pending)
+	t.pending = t.pending[n:]
+	for n < len(p) && (n == 0 || t.r.Buffered() > 0) {
+		b, ok, err := t.next()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if ok {
+			p[n] = b
+			n++
+		}
+	}
+	return n, nil
+}
+
+// next reads the next byte from the client. ok is false if it was part of
+// a command.
+func (t *telnetConn) next() (b byte, ok bool, err error) {
+	b, err = t.r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	if b != telnetIAC {
+		// enter is sent as CR NUL or CR LF
+		cr := t.cr
+		t.cr = b == '\r'
+		if cr && (b == 0 || b == '\n') {
+			return 0, false, nil
+		}
+		return b, true, nil
+	}
+	t.cr = false
+
+	cmd, err := t.r.ReadByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch cmd {
+	case telnetIAC:
+		return telnetIAC, true, nil
+	case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+		opt, err := t.r.ReadByte()
+		if err != nil {
+			return 0, false, err
+		}
+		if cmd == telnetWILL && opt == telnetOptTType {
+			_, err = t.Conn.Write([]byte{telnetIAC, telnetSB, telnetOptTType, telnetTTypeSend, telnetIAC, telnetSE})
+		}
+		return 0, false, err
+	case telnetSB:
+		sub, err := t.readSubnegotiation()
+		if err != nil {
+			return 0, false, err
+		}
+		t.subnegotiation(sub)
+	}
+	return 0, false, nil
+}
+
+// readSubnegotiation reads up to IAC SE and unescapes IAC IAC.
+func (t *telnetConn) readSubnegotiation() ([]byte, error) {
+	var sub []byte
+	for len(sub) < 256 {
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != telnetIAC {
+			sub = append(sub, b)
+			continue
+		}
+		b, err = t.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == telnetSE {
+			return sub, nil
+		}
+		sub = append(sub, b)
+	}
+	return nil, errors.New("telnet subnegotiation too long")
+}
+
+func (t *telnetConn) subnegotiation(sub []byte) {
+	if len(sub) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch sub[0] {
+	case telnetOptNAWS:
+		if len(sub) != 5 {
+			return
+		}
+		width := int(sub[1])<<8 | int(sub[2])
+		height := int(sub[3])<<8 | int(sub[4])
+		if width <= 0 || height <= 0 || width > maxTerminalSize || height > maxTerminalSize {
+			return
+		}
+		t.width, t.height = width, height
+		t.gotSize = true
+		if t.resize != nil {
+			t.resize(width, height)
+		}
+	case telnetOptTType:
+		if len(sub) > 2 && sub[1] == telnetTTypeIs {
+			t.term = strings.ToLower(string(sub[2:]))
+			t.gotTerm = true
+		}
+	}
+}
+
+// Write escapes IAC in the output.
+func (t *telnetConn) Write(p []byte) (int, error) {
+	if _, err := t.Conn.Write(bytes.ReplaceAll(p, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func handleTelnet(c net.Conn) {
+	defer c.Close()
+	if !featureEnabled(featureGuests) {
+		_, _ = io.WriteString(c, "guest access is disabled\r\n")
+		return
+	}
+	t := newTelnetConn(c)
+	if err := t.negotiate(); err != nil {
+		return
+	}
+	term, width, height := t.size()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	id := make([]byte, 32)
+	_, _ = rand.Read(id)
+	g := gateClient{ctx: ctx, w: t, read: keyReader(t), lang: languageFromEnv(nil), id: hex.EncodeToString(id), addr: c.RemoteAddr()}
+	if !gate.pass(g, "") {
+		return
+	}
+	in, input := io.Pipe()
+	sess, err := startSession(sessionConfig{
+		ctx:    ctx,
+		id:     hex.EncodeToString(id),
+		user:   "telnet",
+		addr:   c.RemoteAddr(),
+		term:   term,
+		width:  width,
+		height: height,
+		in:     in,
+		out:    t,
+		colors: detectColorProfile(term, nil),
+	})
+	if err != nil {
+		_, _ = t.Write([]byte(err.Error() + "\r\n"))
+		return
+	}
+	t.onResize(func(width, height int) {
+		go sess.p.Send(tea.WindowSizeMsg{Width: width, Height: height})
+	})
+
+	go func() {
+		// stop the session once the client hangs up
+		defer cancel()
+		_, _ = io.Copy(input, t)
+		input.Close()
+	}()
+
+	if _, err := sess.p.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		log.Printf("Telnet session %s: %v", shortID(sess.id), err)
+	}
+	sess.out.close()
+	if msg := sess.exitMessage(); msg != "" {
+		_, _ = t.Write([]byte("\r\n" + msg + "\r\n"))
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// telnetTestConn is a client that sends in and collects what is written
+// to it.
+type telnetTestConn struct {
+	net.Conn
+	in  io.Reader
+	out bytes.Buffer
+}
+
+func (c *telnetTestConn) Read(b []byte) (int, error)  { return c.in.Read(b) }
+func (c *telnetTestConn) Write(b []byte) (int, error) { return c.out.Write(b) }
+
+func TestTelnetRead(t *testing.T) {
+	naws := func(size ...byte) []byte {
+		return append(append([]byte{telnetIAC, telnetSB, telnetOptNAWS}, size...), telnetIAC, telnetSE)
+	}
+	tests := []struct {
+		name string
+		in   []byte
+		want string
+		// wantTerm and wantSize are what the client told about its terminal
+		wantTerm string
+		wantSize [2]int
+		// wantOut is what was sent to the client
+		wantOut []byte
+		wantErr bool
+	}{
+		{name: "plain", in: []byte("abc"), want: "abc"},
+		{name: "escaped IAC", in: []byte{'a', telnetIAC, telnetIAC, 'b'}, want: "a\xffb"},
+		{name: "CR NUL", in: []byte("a\r\x00b"), want: "a\rb"},
+		{name: "CR LF", in: []byte("a\r\nb"), want: "a\rb"},
+		{name: "LF", in: []byte("a\nb"), want: "a\nb"},
+		{name: "options", in: []byte{telnetIAC, telnetDO, telnetOptEcho, 'a', telnetIAC, telnetWONT, telnetOptSGA, telnetIAC, telnetDONT, 7}, want: "a"},
+		{name: "unknown command", in: []byte{telnetIAC, 241, 'a'}, want: "a"},
+		{
+			name:    "terminal type offered",
+			in:      []byte{telnetIAC, telnetWILL, telnetOptTType},
+			wantOut: []byte{telnetIAC, telnetSB, telnetOptTType, telnetTTypeSend, telnetIAC, telnetSE},
+		},
+		{
+			name:     "terminal type",
+			in:       append(append([]byte{telnetIAC, telnetSB, telnetOptTType, telnetTTypeIs}, "XTERM-256COLOR"...), telnetIAC, telnetSE),
+			wantTerm: "xterm-256color",
+		},
+		{name: "empty terminal type", in: []byte{telnetIAC, telnetSB, telnetOptTType, telnetTTypeIs, telnetIAC, telnetSE}},
+		{name: "window size", in: naws(0, 100, 0, 40), wantSize: [2]int{100, 40}},
+		{name: "escaped IAC in window size", in: naws(0, telnetIAC, telnetIAC, 0, 40), wantSize: [2]int{255, 40}},
+		{name: "largest window", in: naws(maxTerminalSize>>8, maxTerminalSize&0xff, maxTerminalSize>>8, maxTerminalSize&0xff), wantSize: [2]int{maxTerminalSize, maxTerminalSize}},
+		{name: "window too large", in: naws(telnetIAC, telnetIAC, telnetIAC, telnetIAC, 0, 40)},
+		{name: "unescaped IAC in window size", in: naws(telnetIAC, telnetIAC, telnetIAC, telnetIAC)},
+		{name: "zero width", in: naws(0, 0, 0, 40)},
+		{name: "short window size", in: naws(0, 100, 0)},
+		{name: "long window size", in: naws(0, 100, 0, 40, 0)},
+		{name: "empty subnegotiation", in: []byte{telnetIAC, telnetSB, telnetIAC, telnetSE, 'a'}, want: "a"},
+		{name: "cut off after IAC", in: []byte{'a', telnetIAC}, want: "a"},
+		{name: "cut off option", in: []byte{'a', telnetIAC, telnetWILL}, want: "a"},
+		{name: "cut off subnegotiation", in: []byte{'a', telnetIAC, telnetSB, telnetOptNAWS, 0, 100}, want: "a"},
+		{name: "cut off after IAC in subnegotiation", in: []byte{'a', telnetIAC, telnetSB, telnetOptNAWS, telnetIAC}, want: "a"},
+		{
+			name:    "subnegotiation too long",
+			in:      append([]byte{telnetIAC, telnetSB, telnetOptTType, telnetTTypeIs}, strings.Repeat("x", 300)...),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &telnetTestConn{in: bytes.NewReader(tt.in)}
+			tc := newTelnetConn(conn)
+			var resized [2]int
+			tc.onResize(func(width, height int) { resized = [2]int{width, height} })
+			var got []byte
+			buf := make([]byte, 4)
+			var err error
+			for err == nil {
+				var n int
+				n, err = tc.Read(buf)
+				got = append(got, buf[:n]...)
+			}
+			if (err != io.EOF) != tt.wantErr {
+				t.Errorf("Read = %v, want an error: %t", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Read = %q, want %q", got, tt.want)
+			}
+			wantTerm := tt.wantTerm
+			if wantTerm == "" {
+				wantTerm = "xterm"
+			}
+			wantSize := tt.wantSize
+			if wantSize == [2]int{} {
+				wantSize = [2]int{80, 24}
+			} else if resized != wantSize {
+				t.Errorf("resized to %v, want %v", resized, wantSize)
+			}
+			if term, width, height := tc.size(); term != wantTerm || [2]int{width, height} != wantSize {
+				t.Errorf("terminal %s %dx%d, want %s %v", term, width, height, wantTerm, wantSize)
+			}
+			if !bytes.Equal(conn.out.Bytes(), tt.wantOut) {
+				t.Errorf("sent %x, want %x", conn.out.Bytes(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestTelnetWrite(t *testing.T) {
+	conn := &telnetTestConn{}
+	tc := newTelnetConn(conn)
+	in := []byte{'a', telnetIAC, 'b', telnetIAC, telnetIAC}
+	n, err := tc.Write(in)
+	if err != nil || n != len(in) {
+		t.Fatalf("Write = %d, %v, want %d", n, err, len(in))
+	}
+	if want := []byte{'a', telnetIAC, telnetIAC, 'b', telnetIAC, telnetIAC, telnetIAC, telnetIAC}; !bytes.Equal(conn.out.Bytes(), want) {
+		t.Errorf("sent %x, want %x", conn.out.Bytes(), want)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// termsVersion identifies the text of the terms, so that players are asked
+// again when the operator changes them.
+func termsVersion(terms string) string {
+	sum := sha256.Sum256([]byte(terms))
+	return hex.EncodeToString(sum[:8])
+}
+
+// needsTerms reports whether a player who accepted the version accepted of
+// the terms of c has to accept them again before playing.
+func needsTerms(c config, accepted string) bool {
+	return c.Terms != "" && accepted != termsVersion(c.Terms)
+}
+
+// updateTerms handles a key on the terms screen: y and enter accept them,
+// which is saved in the settings of the player, q and esc leave.
+func (m model) updateTerms(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "enter":
+		m.prefs.AcceptedTerms = termsVersion(m.terms)
+		m.terms = ""
+		m.rec.record(recordedEvent{Event: "terms", Text: m.prefs.AcceptedTerms})
+		return m, m.savePrefs()
+	case "q", "esc", "ctrl+c":
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+// termsView shows the terms the player has to accept.
+func (m model) termsView() string {
+	t, l := m.theme, m.lang
+	return t.title.Render(l.tr("Rules of conduct")) + "\n\n" +
+		t.text.Render(wordwrap.String(l.tr("Please read and accept the rules of this server before you play."), m.textWidth())) + "\n\n" +
+		t.text.Render(wordwrap.String(m.terms, m.textWidth())) + "\n\n" +
+		t.dim.Render(l.tr("y accepts • q leaves"))
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jheuel/nimm/board"
+)
+
+// theme is a color scheme for the screens of a session.
+type theme struct {
+	name string
+	// title is used for headings, text for the rest of the screen
+	title lipgloss.Style
+	text  lipgloss.Style
+	// dim is for explanations and other text that is less important
+	dim    lipgloss.Style
+	warn   lipgloss.Style
+	accent lipgloss.Style
+	// bar is the status bar, toast the box of notifications and frame the
+	// border around the board
+	bar   lipgloss.Style
+	toast lipgloss.Style
+	frame lipgloss.Style
+	board board.Styles
+	keys  help.Styles
+}
+
+// palette are the colors of a theme.
+type palette struct {
+	text     lipgloss.TerminalColor
+	dim      lipgloss.TerminalColor
+	faint    lipgloss.TerminalColor
+	warn     lipgloss.TerminalColor
+	accent   lipgloss.TerminalColor
+	onAccent lipgloss.TerminalColor
+	selected lipgloss.TerminalColor
+}
+
+func newTheme(name string, p palette) theme {
+	text := lipgloss.NewStyle().Foreground(p.text)
+	dim := lipgloss.NewStyle().Foreground(p.dim)
+	faint := lipgloss.NewStyle().Foreground(p.faint)
+	return theme{
+		name:   name,
+		title:  text.Copy().Bold(true),
+		text:   text,
+		dim:    dim,
+		warn:   lipgloss.NewStyle().Bold(true).Foreground(p.warn),
+		accent: lipgloss.NewStyle().Foreground(p.onAccent).Background(p.accent),
+		bar:    lipgloss.NewStyle().Foreground(p.onAccent).Background(p.faint),
+		toast:  text.Copy().Border(lipgloss.RoundedBorder()).BorderForeground(p.accent).Padding(0, 1),
+		frame:  lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(p.faint).Padding(0, 1),
+		board: board.Styles{
+			Cell:     text.Copy(),
+			Cursor:   lipgloss.NewStyle().Bold(true).Foreground(p.onAccent).Background(p.accent),
+			Selected: lipgloss.NewStyle().Foreground(p.selected),
+			Taken:    lipgloss.NewStyle().Bold(true).Foreground(p.warn),
+			Label:    dim.Copy(),
+			Remote:   lipgloss.NewStyle().Foreground(p.onAccent).Background(p.selected),
+		},
+		keys: help.Styles{
+			ShortKey:       dim.Copy(),
+			ShortDesc:      faint.Copy(),
+			ShortSeparator: faint.Copy(),
+			Ellipsis:       faint.Copy(),
+			FullKey:        dim.Copy(),
+			FullDesc:       faint.Copy(),
+			FullSeparator:  faint.Copy(),
+		},
+	}
+}
+
+// monochromeTheme gets by without colors, for terminals and players that
+// don't like them.
+func monochromeTheme() theme {
+	plain := lipgloss.NewStyle()
+	faint := lipgloss.NewStyle().Faint(true)
+	return theme{
+		name:   "monochrome",
+		title:  lipgloss.NewStyle().Bold(true),
+		text:   plain,
+		dim:    faint,
+		warn:   lipgloss.NewStyle().Bold(true),
+		accent: lipgloss.NewStyle().Reverse(true),
+		bar:    lipgloss.NewStyle().Reverse(true),
+		toast:  plain.Copy().Border(lipgloss.RoundedBorder()).Padding(0, 1),
+		frame:  faint.Copy().Border(lipgloss.RoundedBorder()).Padding(0, 1),
+		board: board.Styles{
+			Cell:     plain.Copy(),
+			Cursor:   lipgloss.NewStyle().Bold(true).Reverse(true),
+			Selected: lipgloss.NewStyle().Underline(true),
+			Taken:    lipgloss.NewStyle().Bold(true),
+			Label:    faint.Copy(),
+			Remote:   lipgloss.NewStyle().Underline(true),
+		},
+		keys: help.Styles{
+			ShortKey:       plain.Copy(),
+			ShortDesc:      faint.Copy(),
+			ShortSeparator: faint.Copy(),
+			Ellipsis:       faint.Copy(),
+			FullKey:        plain.Copy(),
+			FullDesc:       faint.Copy(),
+			FullSeparator:  faint.Copy(),
+		},
+	}
+}
+
+// markSelection makes the selection of t bold and underlined, so that it
+// doesn't depend on telling colors apart.
+func markSelection(t theme) theme {
+	t.board.Selected = t.board.Selected.Copy().Bold(true).Underline(true)
+	return t
+}
+
+// themes are the color schemes players can choose from, each for dark and
+// light backgrounds. The first is the default.
+var themes = [][2]theme{
+	{
+		newTheme("default", palette{
+			text:     lipgloss.NoColor{},
+			dim:      lipgloss.Color("#626262"),
+			faint:    lipgloss.Color("#4A4A4A"),
+			warn:     lipgloss.Color("#FF5F87"),
+			accent:   lipgloss.Color("#7D56F4"),
+			onAccent: lipgloss.NoColor{},
+			selected: lipgloss.Color("5"),
+		}),
+		newTheme("default", palette{
+			text:     lipgloss.NoColor{},
+			dim:      lipgloss.Color("#5C5C5C"),
+			faint:    lipgloss.Color("#8A8A8A"),
+			warn:     lipgloss.Color("#D7005F"),
+			accent:   lipgloss.Color("#7D56F4"),
+			onAccent: lipgloss.Color("#FFFFFF"),
+			selected: lipgloss.Color("5"),
+		}),
+	},
+	{
+		newTheme("solarized", palette{
+			text:     lipgloss.Color("#839496"),
+			dim:      lipgloss.Color("#586E75"),
+			faint:    lipgloss.Color("#586E75"),
+			warn:     lipgloss.Color("#DC322F"),
+			accent:   lipgloss.Color("#268BD2"),
+			onAccent: lipgloss.Color("#FDF6E3"),
+			selected: lipgloss.Color("#D33682"),
+		}),
+		newTheme("solarized", palette{
+			text:     lipgloss.Color("#657B83"),
+			dim:      lipgloss.Color("#93A1A1"),
+			faint:    lipgloss.Color("#93A1A1"),
+			warn:     lipgloss.Color("#DC322F"),
+			accent:   lipgloss.Color("#268BD2"),
+			onAccent: lipgloss.Color("#FDF6E3"),
+			selected: lipgloss.Color("#D33682"),
+		}),
+	},
+	{
+		newTheme("dracula", palette{
+			text:     lipgloss.Color("#F8F8F2"),
+			dim:      lipgloss.Color("#6272A4"),
+			faint:    lipgloss.Color("#6272A4"),
+			warn:     lipgloss.Color("#FF5555"),
+			accent:   lipgloss.Color("#BD93F9"),
+			onAccent: lipgloss.Color("#282A36"),
+			selected: lipgloss.Color("#FF79C6"),
+		}),
+		// the light version of dracula is called alucard
+		newTheme("dracula", palette{
+			text:     lipgloss.Color("#1F1F1F"),
+			dim:      lipgloss.Color("#635D97"),
+			faint:    lipgloss.Color("#635D97"),
+			warn:     lipgloss.Color("#CB3A2A"),
+			accent:   lipgloss.Color("#644AC9"),
+			onAccent: lipgloss.Color("#FFFBEB"),
+			selected: lipgloss.Color("#A3144D"),
+		}),
+	},
+	{monochromeTheme(), monochromeTheme()},
+}
+
+// defaultTheme is used where there is no player to ask, e.g. for the admin
+// dashboard.
+var defaultTheme = themes[0][0]
+
+func themeNames() []string {
+	names := make([]string, len(themes))
+	for i, t := range themes {
+		names[i] = t[0].name
+	}
+	return names
+}
+
+// getTheme returns the theme called name for a light or dark background,
+// the default theme if there is none.
+func getTheme(name string, light bool) theme {
+	i := 0
+	if light {
+		i = 1
+	}
+	for _, t := range themes {
+		if t[0].name == name {
+			return t[i]
+		}
+	}
+	return themes[0][i]
+}
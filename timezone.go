@@ -0,0 +1,46 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var errTimeZone = errors.New("unknown time zone, try a name like Europe/Berlin or auto")
+
+// parseTimeZone returns the location called name, e.g. Europe/Berlin or UTC.
+// A colon in front is allowed like in TZ.
+func parseTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimPrefix(name, ":")
+	// an empty name or "Local" would be the zone of the server
+	if name == "" || name == "Local" {
+		return nil, errTimeZone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errTimeZone
+	}
+	return loc, nil
+}
+
+// envLocation is the time zone in TZ of the variables the client sent, the
+// one of the server if there is none or it is unknown here.
+func envLocation(environ []string) *time.Location {
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, "TZ=") {
+			if loc, err := parseTimeZone(strings.TrimPrefix(kv, "TZ=")); err == nil {
+				return loc
+			}
+		}
+	}
+	return time.Local
+}
+
+// userLocation is the time zone times are shown in to a player: their own
+// choice, or else the one of their client.
+func userLocation(p userPrefs, environ []string) *time.Location {
+	if loc, err := parseTimeZone(p.TimeZone); err == nil {
+		return loc
+	}
+	return envLocation(environ)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// toastDuration is how long a toast is shown, broadcastDuration how long
+// messages of the operator are.
+const (
+	toastDuration     = 4 * time.Second
+	broadcastDuration = 15 * time.Second
+)
+
+// toast is a short notification in the top right corner of the screen that
+// goes away by itself.
+type toast struct {
+	id   int
+	text string
+}
+
+// toastMsg shows a toast, e.g. for events outside of the session.
+type toastMsg string
+
+// dismissToastMsg removes the toast with the id.
+type dismissToastMsg int
+
+// notify shows text in a toast for d.
+func (m *model) notify(text string, d time.Duration) tea.Cmd {
+	m.lastToast++
+	id := m.lastToast
+	m.toasts = append(m.toasts, toast{id: id, text: text})
+	return tea.Tick(d, func(time.Time) tea.Msg {
+		return dismissToastMsg(id)
+	})
+}
+
+// dismissToast removes the toast with id, if it is still shown.
+func (m *model) dismissToast(id int) {
+	for i, t := range m.toasts {
+		if t.id == id {
+			m.toasts = append(m.toasts[:i:i], m.toasts[i+1:]...)
+			return
+		}
+	}
+}
+
+// toastsView draws the toasts below each other, the newest at the bottom.
+func (m model) toastsView() string {
+	// toasts keep to the right quarter of the screen, next to the board
+	width := m.width / 4
+	if width < 16 {
+		width = 16
+	}
+	boxes := make([]string, len(m.toasts))
+	for i, t := range m.toasts {
+		boxes[i] = m.theme.toast.Render(wordwrap.String(t.text, width))
+	}
+	return lipgloss.JoinVertical(lipgloss.Right, boxes...)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Spans are exported with OTLP over HTTP using the JSON encoding, which every
+// OpenTelemetry collector understands, so no SDK is needed.
+
+const (
+	spanKindInternal = 1
+	spanKindServer   = 2
+
+	statusCodeError = 2
+
+	// slowRender is the render time above which a render span is recorded
+	slowRender = 10 * time.Millisecond
+)
+
+// attr is a span attribute. Values can be strings, ints, or bools.
+type attr struct {
+	key   string
+	value interface{}
+}
+
+// span is a single timed operation. A nil span is valid and does nothing,
+// which is what you get when tracing is disabled.
+type span struct {
+	t        *tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	end      time.Time
+	attrs    []attr
+	err      error
+}
+
+// child starts a new span below s.
+func (s *span) child(name string, attrs ...attr) *span {
+	if s == nil {
+		return nil
+	}
+	c := s.t.newSpan(name, spanKindInternal, attrs...)
+	c.traceID = s.traceID
+	c.parentID = s.spanID
+	return c
+}
+
+// record adds an already finished operation below s.
+func (s *span) record(name string, start, end time.Time, attrs ...attr) {
+	if s == nil {
+		return
+	}
+	c := s.child(name, attrs...)
+	c.start = start
+	c.end = end
+	s.t.export(c)
+}
+
+func (s *span) setAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attr{key, value})
+}
+
+func (s *span) setError(err error) {
+	if s == nil {
+		return
+	}
+	s.err = err
+}
+
+// finish ends the span and queues it for export.
+func (s *span) finish() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	s.t.export(s)
+}
+
+// tracer batches finished spans and sends them to an OTLP/HTTP endpoint.
+type tracer struct {
+	endpoint string
+	client   *http.Client
+
+	mu    sync.Mutex
+	batch []*span
+}
+
+// tracing is the global tracer, nil if tracing is disabled.
+var tracing *tracer
+
+// newTracer returns a tracer sending spans to endpoint, e.g.
+// http://localhost:4318/v1/traces, and starts its export loop.
+func newTracer(endpoint string) *tracer {
+	t := &tracer{
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			t.flush()
+		}
+	}()
+	return t
+}
+
+// startSpan starts a new root span. It returns nil if t is nil.
+func (t *tracer) startSpan(name string, attrs ...attr) *span {
+	if t == nil {
+		return nil
+	}
+	s := t.newSpan(name, spanKindServer, attrs...)
+	_, _ = rand.Read(s.traceID[:])
+	return s
+}
+
+func (t *tracer) newSpan(name string, kind int, attrs ...attr) *span {
+	s := &span{t: t, name: name, kind: kind, start: time.Now(), attrs: attrs}
+	_, _ = rand.Read(s.spanID[:])
+	return s
+}
+
+func (t *tracer) export(s *span) {
+	t.mu.Lock()
+	t.batch = append(t.batch, s)
+	full := len(t.batch) >= 512
+	t.mu.Unlock()
+	if full {
+		go t.flush()
+	}
+}
+
+// flush sends all queued spans.
+func (t *tracer) flush() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	batch := t.batch
+	t.batch = nil
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+
+	spans := make([]map[string]interface{}, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.otlp())
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttrs([]attr{{"service.name", "nimm"}}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]string{"name": "github.com/jheuel/nimm"},
+				"spans": spans,
+			}},
+		}},
+	})
+	if err != nil {
+		log.Printf("Could not encode spans: %v", err)
+		return
+	}
+	resp, err := t.client.Post(t.endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Could not export spans: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("Could not export spans: %s", resp.Status)
+	}
+}
+
+func (s *span) otlp() map[string]interface{} {
+	o := map[string]interface{}{
+		"traceId":           hex.EncodeToString(s.traceID[:]),
+		"spanId":            hex.EncodeToString(s.spanID[:]),
+		"name":              s.name,
+		"kind":              s.kind,
+		"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+		"attributes":        otlpAttrs(s.attrs),
+	}
+	if s.parentID != [8]byte{} {
+		o["parentSpanId"] = hex.EncodeToString(s.parentID[:])
+	}
+	if s.err != nil {
+		o["status"] = map[string]interface{}{"code": statusCodeError, "message": s.err.Error()}
+	}
+	return o
+}
+
+func otlpAttrs(attrs []attr) []interface{} {
+	out := make([]interface{}, 0, len(attrs))
+	for _, a := range attrs {
+		var v map[string]interface{}
+		switch value := a.value.(type) {
+		case string:
+			v = map[string]interface{}{"stringValue": value}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(value)}
+		case bool:
+			v = map[string]interface{}{"boolValue": value}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(value)}
+		}
+		out = append(out, map[string]interface{}{"key": a.key, "value": v})
+	}
+	return out
+}
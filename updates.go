@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// updateCheckInterval is how often the release endpoint is asked for a
+	// newer version.
+	updateCheckInterval = 24 * time.Hour
+	// updateCheckTimeout bounds how long asking it may take.
+	updateCheckTimeout = 10 * time.Second
+)
+
+// release is the answer of a release endpoint, in the format of the
+// releases API of GitHub.
+type release struct {
+	Tag string `json:"tag_name"`
+	URL string `json:"html_url"`
+}
+
+var (
+	updateMu sync.Mutex
+	// newerRelease is the newest release if it is newer than this server
+	newerRelease *release
+)
+
+// availableUpdate returns the newer release, if one was found.
+func availableUpdate() (release, bool) {
+	updateMu.Lock()
+	defer updateMu.Unlock()
+	if newerRelease == nil {
+		return release{}, false
+	}
+	return *newerRelease, true
+}
+
+// checkUpdates asks the configured release endpoint for a newer version
+// now and then daily, and logs when there is one. It never updates the
+// server.
+func checkUpdates() {
+	check := func() {
+		url := currentConfig().UpdateCheckURL
+		if url == "" {
+			return
+		}
+		r, err := latestRelease(url)
+		if err != nil {
+			log.Printf("Could not check for updates: %v", err)
+			return
+		}
+		if !newerVersion(r.Tag, version) {
+			return
+		}
+		updateMu.Lock()
+		defer updateMu.Unlock()
+		if newerRelease == nil || newerRelease.Tag != r.Tag {
+			log.Printf("A newer nimm version is available: %s %s", r.Tag, r.URL)
+		}
+		newerRelease = &r
+	}
+	check()
+	for range time.Tick(updateCheckInterval) {
+		check()
+	}
+}
+
+func latestRelease(url string) (release, error) {
+	var r release
+	ctx, cancel := context.WithTimeout(context.Background(), updateCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return r, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "nimm/"+version)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return r, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return r, errors.New(resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&r)
+	if err == nil && r.Tag == "" {
+		err = errors.New("the answer has no tag_name")
+	}
+	return r, err
+}
+
+// newerVersion reports whether the version latest, e.g. v1.2.0, is newer
+// than current. Builds without a version, like dev, are never outdated.
+func newerVersion(latest, current string) bool {
+	l, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+	c, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	for i := range l {
+		if l[i] != c[i] {
+			return l[i] > c[i]
+		}
+	}
+	return false
+}
+
+// parseVersion returns the numbers of a version like v1.2.3, ignoring what
+// follows a - or +.
+func parseVersion(v string) ([3]int, bool) {
+	var n [3]int
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return n, false
+	}
+	for i, p := range parts {
+		x, err := strconv.Atoi(p)
+		if err != nil || x < 0 {
+			return n, false
+		}
+		n[i] = x
+	}
+	return n, true
+}
@@ -0,0 +1,78 @@
+package variant
+
+func init() {
+	Register(classic{})
+	Register(normal{})
+}
+
+func pyramid() Board {
+	return Board{
+		{false, false, false, true, false, false, false},
+		{false, false, true, true, true, false, false},
+		{false, true, true, true, true, true, false},
+		{true, true, true, true, true, true, true},
+	}
+}
+
+// classic is the game nimm started with: a pyramid of 16 objects, players
+// take any objects between two objects of one row, and whoever is left with
+// the last object loses.
+type classic struct{}
+
+func (classic) Name() string { return Default }
+
+func (classic) Description() string {
+	return "Take any number of objects from one row. Whoever has to take the last object loses."
+}
+
+func (classic) Setup() Board { return pyramid() }
+
+func (classic) LegalMoves(b Board) []Move {
+	left := b.Count()
+	var moves []Move
+	for _, m := range ranges(b) {
+		if Taken(b, m) < left {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+func (classic) Apply(b Board, m Move) (Board, error) {
+	if err := checkRange(b, m); err != nil {
+		return b, err
+	}
+	if b.Count() == Taken(b, m) {
+		return b, ErrLastObject
+	}
+	return take(b, m), nil
+}
+
+func (classic) Lost(b Board) bool { return b.Count() <= 1 }
+
+func (classic) Hints() Hints { return Hints{Object: "X", Empty: " "} }
+
+// normal is classic with the opposite goal: whoever takes the last object
+// wins.
+type normal struct{}
+
+func (normal) Name() string { return "normal" }
+
+func (normal) Description() string {
+	return "Take any number of objects from one row. Whoever takes the last object wins."
+}
+
+func (normal) Setup() Board { return pyramid() }
+
+func (normal) LegalMoves(b Board) []Move { return ranges(b) }
+
+func (normal) Apply(b Board, m Move) (Board, error) {
+	if err := checkRange(b, m); err != nil {
+		return b, err
+	}
+	return take(b, m), nil
+}
+
+func (normal) Lost(b Board) bool { return b.Count() == 0 }
+
+func (normal) Hints() Hints { return Hints{Object: "O", Empty: " "} }
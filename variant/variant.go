@@ -0,0 +1,177 @@
+// Package variant defines the rules of the games nimm can host. The classic
+// game is built in; other Nim-family games register themselves in an init
+// function, like database/sql drivers, so adding one only takes a package
+// and a blank import of it.
+package variant
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Default is the name of the variant played unless another one is chosen.
+const Default = "classic"
+
+var (
+	ErrInvalidMove = errors.New("invalid move")
+	ErrLastObject  = errors.New("move would take the last object")
+)
+
+// Board is a grid of objects, true where an object is left.
+type Board [][]bool
+
+// Clone returns a deep copy of b.
+func (b Board) Clone() Board {
+	c := make(Board, len(b))
+	for i, row := range b {
+		c[i] = append([]bool(nil), row...)
+	}
+	return c
+}
+
+// Count returns the number of objects left.
+func (b Board) Count() int {
+	n := 0
+	for _, row := range b {
+		for _, avail := range row {
+			if avail {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Move takes the objects in columns From to To (inclusive) of Row, skipping
+// empty cells.
+type Move struct {
+	Row  int
+	From int
+	To   int
+}
+
+// Hints tell renderers how to draw a variant.
+type Hints struct {
+	// Object is drawn for an object, Empty for a cell without one.
+	Object string
+	Empty  string
+}
+
+// Variant is the set of rules of a game for two players who move in turns.
+type Variant interface {
+	Name() string
+	// Description explains the rules to players in a sentence or two.
+	Description() string
+	// Setup returns the board at the start of a game.
+	Setup() Board
+	// LegalMoves returns all moves the player to move can make on b.
+	LegalMoves(b Board) []Move
+	// Apply returns the board after m, or an error if m is not allowed.
+	// It must not modify b.
+	Apply(b Board, m Move) (Board, error)
+	// Lost reports whether the player to move on b has lost the game.
+	Lost(b Board) bool
+	Hints() Hints
+}
+
+var (
+	mu       sync.RWMutex
+	variants = map[string]Variant{}
+)
+
+// Register makes v available under its name. It panics if the name is
+// taken, since that is a programming error.
+func Register(v Variant) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := variants[v.Name()]; ok {
+		panic(fmt.Sprintf("variant %q registered twice", v.Name()))
+	}
+	variants[v.Name()] = v
+}
+
+// Get returns the variant called name.
+func Get(name string) (Variant, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	v, ok := variants[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown variant %q", name)
+	}
+	return v, nil
+}
+
+// Names returns the names of all registered variants in order.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Taken returns how many objects m takes from b. Empty cells between From
+// and To are skipped, so that each row is a heap of Nim.
+func Taken(b Board, m Move) int {
+	n := 0
+	for col := m.From; col <= m.To; col++ {
+		if b[m.Row][col] {
+			n++
+		}
+	}
+	return n
+}
+
+// checkRange reports whether m takes any objects from a single row of b.
+func checkRange(b Board, m Move) error {
+	if m.Row < 0 || m.Row >= len(b) || m.From < 0 || m.To >= len(b[m.Row]) || m.From > m.To {
+		return ErrInvalidMove
+	}
+	if Taken(b, m) == 0 {
+		return ErrInvalidMove
+	}
+	return nil
+}
+
+// ranges returns all moves that take objects from a single row, from one
+// object to another. Moves that start or end on empty cells take the same
+// objects as one of them.
+func ranges(b Board) []Move {
+	var moves []Move
+	for row, cols := range b {
+		for from, avail := range cols {
+			if !avail {
+				continue
+			}
+			for to := from; to < len(cols); to++ {
+				if cols[to] {
+					moves = append(moves, Move{row, from, to})
+				}
+			}
+		}
+	}
+	return moves
+}
+
+func take(b Board, m Move) Board {
+	c := b.Clone()
+	for col := m.From; col <= m.To; col++ {
+		c[m.Row][col] = false
+	}
+	return c
+}
+
+// MustGet is like Get but panics if there is no variant called name. It is
+// meant for built-in names like Default.
+func MustGet(name string) Variant {
+	v, err := Get(name)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
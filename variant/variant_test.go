@@ -0,0 +1,112 @@
+package variant
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	// the last row of the pyramid with a gap
+	row := Board{{true, false, true, true}}
+	single := Board{{false, true, false}}
+	tests := []struct {
+		name    string
+		variant string
+		b       Board
+		m       Move
+		want    Board
+		wantErr error
+	}{
+		{"one object", Default, row, Move{0, 2, 2}, Board{{true, false, false, true}}, nil},
+		{"over a gap", Default, row, Move{0, 0, 2}, Board{{false, false, false, true}}, nil},
+		{"from a gap", Default, row, Move{0, 1, 2}, Board{{true, false, false, true}}, nil},
+		{"only the gap", Default, row, Move{0, 1, 1}, nil, ErrInvalidMove},
+		{"backwards", Default, row, Move{0, 3, 2}, nil, ErrInvalidMove},
+		{"negative row", Default, row, Move{-1, 0, 0}, nil, ErrInvalidMove},
+		{"row beyond", Default, row, Move{1, 0, 0}, nil, ErrInvalidMove},
+		{"negative column", Default, row, Move{0, -1, 0}, nil, ErrInvalidMove},
+		{"column beyond", Default, row, Move{0, 0, 4}, nil, ErrInvalidMove},
+		{"last object", Default, single, Move{0, 1, 1}, nil, ErrLastObject},
+		{"all objects", Default, row, Move{0, 0, 3}, nil, ErrLastObject},
+		{"last object in normal", "normal", single, Move{0, 1, 1}, Board{{false, false, false}}, nil},
+		{"only the gap in normal", "normal", row, Move{0, 1, 1}, nil, ErrInvalidMove},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := tt.b.Clone()
+			got, err := MustGet(tt.variant).Apply(tt.b, tt.m)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.b, before) {
+				t.Errorf("Apply changed the board to %v", tt.b)
+			}
+		})
+	}
+}
+
+func TestLegalMoves(t *testing.T) {
+	tests := []struct {
+		variant string
+		b       Board
+		want    int
+	}{
+		// every pair of objects in a row of 1, 3, 5 and 7
+		{Default, pyramid(), 1 + 6 + 15 + 28},
+		{"normal", pyramid(), 1 + 6 + 15 + 28},
+		{Default, Board{{true, false, true}}, 2},
+		{"normal", Board{{true, false, true}}, 3},
+		{Default, Board{{false, true}}, 0},
+		{"normal", Board{{false, false}}, 0},
+	}
+	for _, tt := range tests {
+		v := MustGet(tt.variant)
+		moves := v.LegalMoves(tt.b)
+		if len(moves) != tt.want {
+			t.Errorf("%s has %d moves on %v, want %d", tt.variant, len(moves), tt.b, tt.want)
+		}
+		for _, m := range moves {
+			if _, err := v.Apply(tt.b, m); err != nil {
+				t.Errorf("%s: legal move %v: %v", tt.variant, m, err)
+			}
+		}
+	}
+}
+
+func TestLost(t *testing.T) {
+	tests := []struct {
+		variant string
+		b       Board
+		want    bool
+	}{
+		{Default, pyramid(), false},
+		{Default, Board{{true, true}}, false},
+		{Default, Board{{false, true}}, true},
+		{"normal", Board{{false, true}}, false},
+		{"normal", Board{{false, false}}, true},
+	}
+	for _, tt := range tests {
+		if got := MustGet(tt.variant).Lost(tt.b); got != tt.want {
+			t.Errorf("%s: Lost(%v) = %t, want %t", tt.variant, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	if got := Names(); !reflect.DeepEqual(got, []string{Default, "normal"}) {
+		t.Errorf("Names = %v", got)
+	}
+	if _, err := Get("misere"); err == nil {
+		t.Error("Get of an unknown variant didn't fail")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice didn't panic")
+		}
+	}()
+	Register(normal{})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// These are set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=v1.0.0 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -I)"
+//
+// and otherwise filled in from the build info where possible.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if commit == "" {
+				commit = s.Value
+			}
+		case "vcs.time":
+			if date == "" {
+				date = s.Value
+			}
+		}
+	}
+	if version == "" {
+		version = "dev"
+	}
+}
+
+// versionString describes the build in one line.
+func versionString() string {
+	s := "nimm " + version
+	if commit != "" {
+		c := commit
+		if len(c) > 7 {
+			c = c[:7]
+		}
+		s += fmt.Sprintf(" (%s", c)
+		if date != "" {
+			s += ", " + date
+		}
+		s += ")"
+	}
+	return s
+}
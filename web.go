@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/muesli/termenv"
+)
+
+//go:embed web/index.html
+var webIndex []byte
+
+// webResize is sent by the browser whenever the terminal size changes.
+type webResize struct {
+	Cols int `json:"cols"`
+	Rows int `json:"rows"`
+}
+
+// webHandler serves the browser terminal, which plays over a WebSocket on
+// /ws, the replays of finished games and the status page. Web players are always guests.
+func webHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(webIndex)
+	})
+	mux.HandleFunc("/ws", webSocketHandler)
+	mux.HandleFunc("/replays/", replayHandler)
+	mux.HandleFunc("/status", statusHandler)
+	mux.HandleFunc("/status.json", statusJSONHandler)
+	return mux
+}
+
+func webSocketHandler(w http.ResponseWriter, r *http.Request) {
+	if !featureEnabled(featureGuests) {
+		http.Error(w, "guest access is disabled", http.StatusForbidden)
+		return
+	}
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.close()
+
+	// the browser tells us its size first
+	size, err := readWebResize(ws)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	id := make([]byte, 32)
+	_, _ = rand.Read(id)
+	var addr net.Addr = &net.TCPAddr{}
+	if a, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		addr = a
+	}
+	c := gateClient{ctx: ctx, w: ws, read: ws.readKey, lang: languageFromEnv(nil), id: hex.EncodeToString(id), addr: addr}
+	if !gate.pass(c, "") {
+		return
+	}
+	in, input := io.Pipe()
+	sess, err := startSession(sessionConfig{
+		ctx:    ctx,
+		id:     hex.EncodeToString(id),
+		user:   "web",
+		addr:   addr,
+		term:   "xterm-256color",
+		width:  size.Cols,
+		height: size.Rows,
+		in:     in,
+		out:    ws,
+		// xterm.js
+		colors: termenv.TrueColor,
+	})
+	if err != nil {
+		_, _ = ws.Write([]byte(err.Error() + "\r\n"))
+		return
+	}
+
+	go func() {
+		defer cancel()
+		defer input.Close()
+		for {
+			_, msg, err := ws.readMessage()
+			if err != nil {
+				return
+			}
+			if len(msg) == 0 {
+				continue
+			}
+			switch msg[0] {
+			case '0':
+				if _, err := input.Write(msg[1:]); err != nil {
+					return
+				}
+			case '1':
+				if size, err := parseWebResize(msg[1:]); err == nil {
+					sess.p.Send(tea.WindowSizeMsg{Width: size.Cols, Height: size.Rows})
+				}
+			}
+		}
+	}()
+
+	if _, err := sess.p.Run(); err != nil && !errors.Is(err, tea.ErrProgramKilled) {
+		log.Printf("Web session %s: %v", shortID(sess.id), err)
+	}
+	sess.out.close()
+	if msg := sess.exitMessage(); msg != "" {
+		_, _ = ws.Write([]byte("\r\n" + msg + "\r\n"))
+	}
+}
+
+// readKey returns the first key pressed in the next input from the
+// browser, skipping resizes.
+func (ws *wsConn) readKey() (byte, error) {
+	for {
+		_, msg, err := ws.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		if len(msg) > 1 && msg[0] == '0' {
+			return msg[1], nil
+		}
+	}
+}
+
+func readWebResize(ws *wsConn) (webResize, error) {
+	_, msg, err := ws.readMessage()
+	if err != nil {
+		return webResize{}, err
+	}
+	if len(msg) == 0 || msg[0] != '1' {
+		return webResize{}, errors.New("expected the terminal size")
+	}
+	return parseWebResize(msg[1:])
+}
+
+// parseWebResize reads the terminal size the browser sent in b.
+func parseWebResize(b []byte) (webResize, error) {
+	var size webResize
+	if err := json.Unmarshal(b, &size); err != nil {
+		return size, err
+	}
+	if size.Cols <= 0 || size.Rows <= 0 || size.Cols > maxTerminalSize || size.Rows > maxTerminalSize {
+		return size, errors.New("invalid terminal size")
+	}
+	return size, nil
+}
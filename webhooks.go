@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// lifecycle events sent to webhooks
+const (
+	hookGameStarted      = "game.started"
+	hookGameFinished     = "game.finished"
+	hookPlayerRegistered = "player.registered"
+)
+
+// webhookAttempts is how often a webhook is tried before the event is
+// dropped.
+const webhookAttempts = 3
+
+// webhookConfig is an endpoint that is told about lifecycle events.
+type webhookConfig struct {
+	URL string `json:"url"`
+	// Secret signs the body with HMAC-SHA256 in the X-Nimm-Signature
+	// header, as "sha256=<hex>". Requests are unsigned if it is empty.
+	Secret string `json:"secret"`
+	// Events are the events to send, all if empty.
+	Events []string `json:"events"`
+}
+
+func (h webhookConfig) wants(event string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookEvent is the body of a webhook request or a streamed event.
+type webhookEvent struct {
+	Event string      `json:"event"`
+	Time  time.Time   `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// fireEvent tells webhooks and the event stream about event.
+func fireEvent(event string, data interface{}) {
+	fireWebhooks(event, data)
+	publishEvent(event, data)
+}
+
+// fireWebhooks sends event with data to all webhooks that want it. It
+// doesn't wait for the requests.
+func fireWebhooks(event string, data interface{}) {
+	hooks := currentConfig().Webhooks
+	if len(hooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(webhookEvent{Event: event, Time: time.Now(), Data: data})
+	if err != nil {
+		log.Printf("Could not encode %s webhook: %v", event, err)
+		return
+	}
+	for _, h := range hooks {
+		if !h.wants(event) {
+			continue
+		}
+		go func(h webhookConfig) {
+			if err := deliverWebhook(h, event, body); err != nil {
+				log.Printf("Could not deliver %s webhook to %s: %v", event, h.URL, err)
+			}
+		}(h)
+	}
+}
+
+// deliverWebhook posts body to h, retrying with backoff.
+func deliverWebhook(h webhookConfig, event string, body []byte) error {
+	var err error
+	for attempt := 0; attempt < webhookAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * 5 * time.Second)
+		}
+		if err = postWebhook(h, event, body); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func postWebhook(h webhookConfig, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Nimm-Event", event)
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Nimm-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := notifyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
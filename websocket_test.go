@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// wsTestConn collects what is written to a WebSocket.
+type wsTestConn struct {
+	net.Conn
+	out bytes.Buffer
+}
+
+func (c *wsTestConn) Write(b []byte) (int, error) { return c.out.Write(b) }
+func (c *wsTestConn) Close() error                { return nil }
+
+// wsFrame is a frame as a client sends it, masked unless unmasked is set.
+func wsFrame(fin bool, op byte, payload []byte, unmasked bool) []byte {
+	b := []byte{op}
+	if fin {
+		b[0] |= 0x80
+	}
+	maskBit := byte(0x80)
+	if unmasked {
+		maskBit = 0
+	}
+	switch n := len(payload); {
+	case n < 126:
+		b = append(b, maskBit|byte(n))
+	case n <= 0xffff:
+		b = append(b, maskBit|126, byte(n>>8), byte(n))
+	default:
+		var l [8]byte
+		binary.BigEndian.PutUint64(l[:], uint64(n))
+		b = append(append(b, maskBit|127), l[:]...)
+	}
+	if unmasked {
+		return append(b, payload...)
+	}
+	mask := []byte{1, 2, 3, 4}
+	b = append(b, mask...)
+	for i, p := range payload {
+		b = append(b, p^mask[i%4])
+	}
+	return b
+}
+
+func TestWebSocketReadMessage(t *testing.T) {
+	long := bytes.Repeat([]byte{'x'}, 300)
+	half := bytes.Repeat([]byte{'y'}, wsMaxMessage/2+1)
+	tests := []struct {
+		name    string
+		in      [][]byte
+		wantOp  byte
+		want    []byte
+		wantErr error
+		// wantOut is what the server answered
+		wantOut []byte
+	}{
+		{"text", [][]byte{wsFrame(true, wsOpText, []byte("0a"), false)}, wsOpText, []byte("0a"), nil, nil},
+		{"16 bit length", [][]byte{wsFrame(true, wsOpBinary, long, false)}, wsOpBinary, long, nil, nil},
+		{"fragments", [][]byte{
+			wsFrame(false, wsOpText, []byte("ab"), false),
+			wsFrame(true, wsOpContinuation, []byte("cd"), false),
+		}, wsOpText, []byte("abcd"), nil, nil},
+		{"ping between fragments", [][]byte{
+			wsFrame(false, wsOpText, []byte("ab"), false),
+			wsFrame(true, wsOpPing, []byte("hi"), false),
+			wsFrame(true, wsOpContinuation, []byte("cd"), false),
+		}, wsOpText, []byte("abcd"), nil, []byte{0x80 | wsOpPong, 2, 'h', 'i'}},
+		{"pong", [][]byte{
+			wsFrame(true, wsOpPong, nil, false),
+			wsFrame(true, wsOpText, []byte("1"), false),
+		}, wsOpText, []byte("1"), nil, nil},
+		{"close", [][]byte{wsFrame(true, wsOpClose, nil, false)}, 0, nil, io.EOF, []byte{0x80 | wsOpClose, 0}},
+		{"empty", nil, 0, nil, io.EOF, nil},
+		{"header cut off", [][]byte{{0x81}}, 0, nil, io.ErrUnexpectedEOF, nil},
+		{"length cut off", [][]byte{{0x81, 0x80 | 126, 1}}, 0, nil, io.ErrUnexpectedEOF, nil},
+		{"mask cut off", [][]byte{{0x81, 0x82, 1, 2}}, 0, nil, io.ErrUnexpectedEOF, nil},
+		{"payload cut off", [][]byte{wsFrame(true, wsOpText, []byte("abc"), false)[:8]}, 0, nil, io.ErrUnexpectedEOF, nil},
+		{"frame too large", [][]byte{{0x82, 0x80 | 127, 0, 0, 0, 1, 0, 0, 0, 0}}, 0, nil, errWSMessageTooLarge, nil},
+		{"64 bit length overflow", [][]byte{{0x82, 0x80 | 127, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}, 0, nil, errWSMessageTooLarge, nil},
+		{"message too large", [][]byte{
+			wsFrame(false, wsOpBinary, half, false),
+			wsFrame(true, wsOpContinuation, half, false),
+		}, 0, nil, errWSMessageTooLarge, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &wsTestConn{}
+			ws := &wsConn{conn: conn, r: bufio.NewReader(bytes.NewReader(bytes.Join(tt.in, nil)))}
+			op, msg, err := ws.readMessage()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readMessage = %v, want %v", err, tt.wantErr)
+			}
+			if op != tt.wantOp || !bytes.Equal(msg, tt.want) {
+				t.Errorf("readMessage = %d %q, want %d %q", op, msg, tt.wantOp, tt.want)
+			}
+			if !bytes.Equal(conn.out.Bytes(), tt.wantOut) {
+				t.Errorf("answered %x, want %x", conn.out.Bytes(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestWebSocketMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		in   [][]byte
+	}{
+		{"unmasked", [][]byte{wsFrame(true, wsOpText, []byte("a"), true)}},
+		{"continuation first", [][]byte{wsFrame(true, wsOpContinuation, []byte("a"), false)}},
+		{"unknown opcode", [][]byte{wsFrame(true, 0x3, []byte("a"), false)}},
+		{"fragmented ping", [][]byte{wsFrame(false, wsOpPing, []byte("a"), false)}},
+		{"long ping", [][]byte{wsFrame(true, wsOpPing, bytes.Repeat([]byte{'a'}, 126), false)}},
+		{"message inside a message", [][]byte{
+			wsFrame(false, wsOpText, []byte("a"), false),
+			wsFrame(true, wsOpText, []byte("b"), false),
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &wsTestConn{}
+			ws := &wsConn{conn: conn, r: bufio.NewReader(bytes.NewReader(bytes.Join(tt.in, nil)))}
+			if _, msg, err := ws.readMessage(); err == nil || err == io.EOF {
+				t.Errorf("readMessage = %q, %v, want an error", msg, err)
+			}
+			if conn.out.Len() != 0 {
+				t.Errorf("answered %x", conn.out.Bytes())
+			}
+		})
+	}
+}
+
+func TestWebSocketWriteLength(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0, []byte{0x82, 0}},
+		{125, []byte{0x82, 125}},
+		{126, []byte{0x82, 126, 0, 126}},
+		{0xffff, []byte{0x82, 126, 0xff, 0xff}},
+		{0x10000, []byte{0x82, 127, 0, 0, 0, 0, 0, 1, 0, 0}},
+	}
+	for _, tt := range tests {
+		conn := &wsTestConn{}
+		ws := &wsConn{conn: conn}
+		if _, err := ws.Write(make([]byte, tt.n)); err != nil {
+			t.Fatal(err)
+		}
+		if got := conn.out.Bytes(); !bytes.HasPrefix(got, tt.want) || len(got) != len(tt.want)+tt.n {
+			t.Errorf("frame of %d bytes starts with %x, want %x", tt.n, got[:len(tt.want)], tt.want)
+		}
+	}
+	ws := &wsConn{conn: &wsTestConn{}}
+	ws.close()
+	if _, err := ws.Write([]byte("a")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Write after close = %v, want %v", err, net.ErrClosed)
+	}
+}
+
+func TestParseWebResize(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    webResize
+		wantErr bool
+	}{
+		{`{"cols":80,"rows":24}`, webResize{80, 24}, false},
+		{`{"cols":1000,"rows":1000}`, webResize{1000, 1000}, false},
+		{`{"cols":1001,"rows":24}`, webResize{}, true},
+		{`{"cols":80,"rows":99999999}`, webResize{}, true},
+		{`{"cols":0,"rows":24}`, webResize{}, true},
+		{`{"cols":-1,"rows":24}`, webResize{}, true},
+		{`{"cols":80}`, webResize{}, true},
+		{`{"cols":80,"rows":`, webResize{}, true},
+		{``, webResize{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseWebResize([]byte(tt.in))
+		if (err != nil) != tt.wantErr || err == nil && got != tt.want {
+			t.Errorf("parseWebResize(%s) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}